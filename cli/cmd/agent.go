@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/coverport/cli/internal/manifest"
+	coverageclient "github.com/konflux-ci/coverport/cli/pkg/client"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Receive coverage pushed by pods before they terminate",
+	Long: `Run a collector that receives the final coverage of pods that are rolled,
+evicted or scaled down during tests.
+
+Instrumented applications push their coverage when the /coverage/push endpoint is
+called (e.g. from a preStop hook) or on SIGTERM (COVERAGE_DUMP_ON_SIGTERM=1), to the
+URL in COVERAGE_PUSH_URL. Run the agent in the cluster behind a Service, or locally,
+and point COVERAGE_PUSH_URL at http://<agent>:<port>/push.
+
+Each push is saved to <output>/<component>/<pod>-<pid>/ and recorded in
+<output>/metadata.json. Include the agent output in a collection with
+'coverport collect --include-pushed=<output>'.`,
+	Example: `  # Receive pushes until interrupted
+  coverport agent --listen=:53800 --output=./pushed-coverage
+
+  # Receive pushes for the duration of a test run
+  coverport agent --listen=:53800 --output=./pushed-coverage --duration=2h`,
+	Run: runAgent,
+}
+
+var (
+	agentListen   string
+	agentOutput   string
+	agentTestName string
+	agentDuration time.Duration
+)
+
+const (
+	// maxPushSize limits the size of a pushed coverage payload
+	maxPushSize = 1 << 30
+)
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+
+	agentCmd.Flags().StringVar(&agentListen, "listen", ":53800", "Address to listen on")
+	agentCmd.Flags().StringVarP(&agentOutput, "output", "o", "./pushed-coverage", "Output directory for pushed coverage")
+	agentCmd.Flags().StringVar(&agentTestName, "test-name", "", "Test name recorded in the manifest (default: auto-generated)")
+	agentCmd.Flags().DurationVar(&agentDuration, "duration", 0, "Stop after this duration (default: until interrupted)")
+}
+
+// pushCollector saves pushed coverage and records it in the manifest
+type pushCollector struct {
+	outputDir string
+	manifest  *manifest.CollectionManifest
+	mu        sync.Mutex
+}
+
+func runAgent(cmd *cobra.Command, args []string) {
+	if agentTestName == "" {
+		agentTestName = fmt.Sprintf("pushed-%s", time.Now().Format("20060102-150405"))
+	}
+
+	collector, err := newPushCollector(agentOutput, agentTestName)
+	if err != nil {
+		exitWithError("Failed to initialize agent: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/push", collector.handlePush)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "coverport agent healthy")
+	})
+
+	server := &http.Server{Addr: agentListen, Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if agentDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, agentDuration)
+		defer cancel()
+	}
+
+	fmt.Println("coverport - Coverage Push Agent")
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("Listen:        %s (POST /push)\n", agentListen)
+	fmt.Printf("Output Dir:    %s\n", agentOutput)
+	fmt.Println(strings.Repeat("=", 60))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			exitWithError("Agent server failed: %v", err)
+		}
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			printWarning("Failed to shut down agent cleanly: %v", err)
+		}
+	}
+
+	printSuccess("\nAgent stopped, received coverage from %d pod(s)", len(collector.manifest.Components))
+	fmt.Printf("Coverage data saved to: %s\n", agentOutput)
+}
+
+// newPushCollector creates a collector writing to outputDir, appending to an
+// existing manifest if present
+func newPushCollector(outputDir, testName string) (*pushCollector, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("create output directory: %w", err)
+	}
+
+	collectionManifest := manifest.NewCollectionManifest(testName, manifest.CollectionParameters{})
+	if manifest.Exists(outputDir) {
+		existing, err := manifest.Load(outputDir)
+		if err != nil {
+			return nil, fmt.Errorf("load existing manifest: %w", err)
+		}
+		collectionManifest = existing
+	}
+
+	return &pushCollector{outputDir: outputDir, manifest: collectionManifest}, nil
+}
+
+// handlePush saves a pushed coverage payload. The component and pod are taken
+// from the X-Art-Coverage-* headers set by the instrumentation.
+func (p *pushCollector) handlePush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	component := sanitizePathElement(r.Header.Get("X-Art-Coverage-Component"))
+	if component == "" {
+		component = sanitizePathElement(r.Header.Get("X-Art-Coverage-Binary"))
+	}
+	if component == "" {
+		component = "unknown"
+	}
+	pod := sanitizePathElement(r.Header.Get("X-Art-Coverage-Pod"))
+	pushDir := sanitizePathElement(fmt.Sprintf("%s-%s", pod, r.Header.Get("X-Art-Coverage-Pid")))
+
+	fmt.Printf("\nReceived coverage push: component=%s pod=%s\n", component, pod)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	client, err := coverageclient.NewClientForURL(filepath.Join(p.outputDir, component))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create coverage client: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// The format header of the server is only missing for old servers
+	format := coverageclient.ParseCoverageFormat(r.Header.Get("X-Art-Coverage-Format"))
+	if err := client.SaveCoverage(body, pushDir, format); err != nil {
+		printWarning("Failed to save pushed coverage from %s: %v", pod, err)
+		http.Error(w, fmt.Sprintf("Failed to save coverage: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	p.recordPush(manifest.ComponentInfo{
+		Name:        component,
+		CoverageDir: filepath.Join(component, pushDir),
+		Namespace:   r.Header.Get("X-Art-Coverage-Namespace"),
+		PodName:     pod,
+		CollectedAt: time.Now().Format(time.RFC3339),
+		Format:      string(client.DetectedFormat()),
+	})
+	if err := p.manifest.Save(p.outputDir); err != nil {
+		printWarning("Failed to save manifest: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Coverage received")
+}
+
+// recordPush adds the push to the manifest, updating the entry if the same
+// process pushed before (e.g. preStop hook followed by SIGTERM)
+func (p *pushCollector) recordPush(info manifest.ComponentInfo) {
+	for i := range p.manifest.Components {
+		if p.manifest.Components[i].CoverageDir == info.CoverageDir {
+			p.manifest.Components[i].CollectedAt = info.CollectedAt
+			return
+		}
+	}
+	p.manifest.AddComponent(info)
+}
+
+// sanitizePathElement turns an untrusted header value into a safe single path element
+func sanitizePathElement(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return strings.Trim(b.String(), ".")
+}
+
+// pushedCoverageFile is the /coverage JSON the Python, Node.js and Rust
+// instrumentation writes to COVERAGE_DUMP_DIR
+const pushedCoverageFile = "coverage.json"
+
+// includePushedCoverage copies coverage pushed by terminated pods into the output
+// directory and returns its manifest entries. dir is either an agent output
+// directory (with metadata.json) or a COVERAGE_DUMP_DIR volume laid out as
+// <component>/<hostname>-<pid>/. Dumps holding the /coverage JSON
+// (coverage.json) are saved like collected coverage; Go dumps are copied.
+func includePushedCoverage(dir, outputDir string) ([]manifest.ComponentInfo, error) {
+	if manifest.Exists(dir) {
+		pushedManifest, err := manifest.Load(dir)
+		if err != nil {
+			return nil, fmt.Errorf("load pushed manifest: %w", err)
+		}
+		for _, component := range pushedManifest.Components {
+			if err := copyDir(filepath.Join(dir, component.CoverageDir), filepath.Join(outputDir, component.CoverageDir)); err != nil {
+				return nil, fmt.Errorf("copy pushed coverage of %s: %w", component.Name, err)
+			}
+		}
+		return pushedManifest.Components, nil
+	}
+
+	dumps, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	var pushed []manifest.ComponentInfo
+	for _, dump := range dumps {
+		info, err := os.Stat(dump)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(dir, dump)
+		if err != nil {
+			return nil, err
+		}
+		component := manifest.ComponentInfo{
+			Name:        filepath.Base(filepath.Dir(dump)),
+			CoverageDir: rel,
+			PodName:     trimPid(filepath.Base(dump)),
+			CollectedAt: info.ModTime().Format(time.RFC3339),
+		}
+
+		if body, err := os.ReadFile(filepath.Join(dump, pushedCoverageFile)); err == nil {
+			client, err := coverageclient.NewClientForURL(filepath.Join(outputDir, component.Name))
+			if err != nil {
+				return nil, fmt.Errorf("create coverage client: %w", err)
+			}
+			if err := client.SaveCoverage(body, filepath.Base(dump), ""); err != nil {
+				return nil, fmt.Errorf("save pushed coverage of %s: %w", component.Name, err)
+			}
+			component.Format = string(client.DetectedFormat())
+		} else {
+			if files, _ := filepath.Glob(filepath.Join(dump, "cov*.*")); len(files) == 0 {
+				continue
+			}
+			if err := copyDir(dump, filepath.Join(outputDir, rel)); err != nil {
+				return nil, fmt.Errorf("copy pushed coverage of %s: %w", component.Name, err)
+			}
+			component.Format = string(coverageclient.FormatGo)
+		}
+		pushed = append(pushed, component)
+	}
+
+	return pushed, nil
+}
+
+// trimPid strips the "-<pid>" suffix from a dump directory name
+func trimPid(name string) string {
+	i := strings.LastIndex(name, "-")
+	if i <= 0 {
+		return name
+	}
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return name
+	}
+	return name[:i]
+}
+
+// copyDir copies the regular files of a directory tree
+func copyDir(srcDir, dstDir string) error {
+	return filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dstDir, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/konflux-ci/coverport/cli/internal/discovery"
+	"github.com/konflux-ci/coverport/cli/internal/snapshot"
+)
+
+// defaultKubeconfigSecretKey is the Secret key read by --kubeconfig-secret when none is given
+const defaultKubeconfigSecretKey = "kubeconfig"
+
+var (
+	// Cluster options, shared by collect and discover
+	kubeContexts      []string
+	kubeconfigs       []string
+	kubeconfigSecrets []string
+
+	// kubeClusters are the clusters set up by setupKubeClusters
+	kubeClusters []kubeCluster
+)
+
+// kubeCluster is a cluster pods are discovered and collected in
+type kubeCluster struct {
+	name       string // Kubeconfig context or Secret; empty for the default cluster
+	clientset  kubernetes.Interface
+	restConfig *rest.Config
+}
+
+// setupKubeClusters creates a client for each --context, --kubeconfig and
+// --kubeconfig-secret, or for the default cluster when none is given
+func setupKubeClusters(ctx context.Context) []kubeCluster {
+	if len(kubeContexts) == 0 && len(kubeconfigs) == 0 && len(kubeconfigSecrets) == 0 {
+		clientset, config := setupKubeClient()
+		kubeClusters = []kubeCluster{{clientset: clientset, restConfig: config}}
+		return kubeClusters
+	}
+
+	clusters, err := loadKubeClusters(ctx)
+	if err != nil {
+		exitWithError("Failed to set up clusters: %v", err)
+	}
+	kubeClusters = clusters
+	return clusters
+}
+
+// loadKubeClusters builds the clusters given by the cluster options. A cluster
+// given twice (the same server and context) is an error. When clusters only
+// share a name, e.g. the "admin" context of installer kubeconfigs, the later
+// one is named by its fallback name (the kubeconfig path) instead.
+func loadKubeClusters(ctx context.Context) ([]kubeCluster, error) {
+	var clusters []kubeCluster
+	names := make(map[string]bool)
+	seen := make(map[string]bool) // Server and context of each cluster
+	add := func(name, fallbackName, kubeContext string, kubeConfig clientcmd.ClientConfig) error {
+		config, err := kubeConfig.ClientConfig()
+		if err != nil {
+			return fmt.Errorf("build config for cluster %s: %w", name, err)
+		}
+
+		identity := config.Host + " " + kubeContext
+		if seen[identity] {
+			return fmt.Errorf("cluster %q (context %q at %s) given more than once", name, kubeContext, config.Host)
+		}
+		seen[identity] = true
+		if names[name] {
+			if fallbackName == "" || names[fallbackName] {
+				return fmt.Errorf("cluster name %q is used by more than one cluster", name)
+			}
+			name = fallbackName
+		}
+		names[name] = true
+
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("create client for cluster %s: %w", name, err)
+		}
+		clusters = append(clusters, kubeCluster{name: name, clientset: clientset, restConfig: config})
+		return nil
+	}
+
+	// Contexts of the default kubeconfig (KUBECONFIG or ~/.kube/config)
+	for _, kubeContext := range kubeContexts {
+		kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(),
+			&clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+		if err := add(kubeContext, "", kubeContext, kubeConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	// Kubeconfig files, each with its current context
+	for _, path := range kubeconfigs {
+		kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+			&clientcmd.ConfigOverrides{})
+		raw, err := kubeConfig.RawConfig()
+		if err != nil {
+			return nil, fmt.Errorf("load kubeconfig %s: %w", path, err)
+		}
+		name := raw.CurrentContext
+		if name == "" {
+			name = path
+		}
+		if err := add(name, path, raw.CurrentContext, kubeConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	// Kubeconfigs stored in Secrets of the default cluster (e.g. of spoke clusters)
+	if len(kubeconfigSecrets) > 0 {
+		clientset, _ := setupKubeClient()
+		for _, ref := range kubeconfigSecrets {
+			secretNamespace, name, key, err := parseKubeconfigSecret(ref)
+			if err != nil {
+				return nil, err
+			}
+			secret, err := clientset.CoreV1().Secrets(secretNamespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("get kubeconfig secret %s/%s: %w", secretNamespace, name, err)
+			}
+			data, ok := secret.Data[key]
+			if !ok {
+				return nil, fmt.Errorf("secret %s/%s has no key %q", secretNamespace, name, key)
+			}
+			kubeConfig, err := clientcmd.NewClientConfigFromBytes(data)
+			if err != nil {
+				return nil, fmt.Errorf("parse kubeconfig from secret %s/%s: %w", secretNamespace, name, err)
+			}
+			raw, err := kubeConfig.RawConfig()
+			if err != nil {
+				return nil, fmt.Errorf("load kubeconfig from secret %s/%s: %w", secretNamespace, name, err)
+			}
+			if err := add(secretNamespace+"/"+name, "", raw.CurrentContext, kubeConfig); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return clusters, nil
+}
+
+// parseKubeconfigSecret parses a --kubeconfig-secret value of the form
+// <namespace>/<name>[/<key>]
+func parseKubeconfigSecret(ref string) (namespace, name, key string, err error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", fmt.Errorf("invalid kubeconfig secret %q (expected <namespace>/<name>[/<key>])", ref)
+	}
+	key = defaultKubeconfigSecretKey
+	if len(parts) == 3 && parts[2] != "" {
+		key = parts[2]
+	}
+	return parts[0], parts[1], key, nil
+}
+
+// clusterRestConfig returns the REST config of the named cluster
+func clusterRestConfig(name string) *rest.Config {
+	for _, cluster := range kubeClusters {
+		if cluster.name == name {
+			return cluster.restConfig
+		}
+	}
+	return nil
+}
+
+// clusterDirName turns a cluster name (e.g. an OpenShift context such as
+// default/api-hub:6443/admin) into a name usable in paths
+func clusterDirName(name string) string {
+	return sanitizePathElement(name)
+}
+
+// clusterPrefix returns "<cluster>: " for display, or "" for the default cluster
+func clusterPrefix(name string) string {
+	if name == "" {
+		return ""
+	}
+	return name + ": "
+}
+
+// discoverPodsInClusters runs the selected discovery method in each cluster.
+// Konflux resources (--snapshot-name, --resolve-components) are read from the
+// first cluster.
+func discoverPodsInClusters(ctx context.Context, clusters []kubeCluster, verbose bool) ([]discovery.PodInfo, error) {
+	var snap *snapshot.Snapshot
+	if snapshotJSON != "" || snapshotFile != "" || snapshotName != "" {
+		var err error
+		if snap, err = loadSnapshot(ctx, clusters[0].restConfig, verbose); err != nil {
+			return nil, err
+		}
+	}
+
+	var pods []discovery.PodInfo
+	for _, cluster := range clusters {
+		if cluster.name != "" {
+			fmt.Printf("\nDiscovering pods in cluster %s\n", cluster.name)
+		}
+
+		clusterPods, err := discoverPods(ctx, cluster.clientset, snap, verbose)
+		if err != nil {
+			if cluster.name == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("cluster %s: %w", cluster.name, err)
+		}
+		for i := range clusterPods {
+			clusterPods[i].Cluster = cluster.name
+		}
+		pods = append(pods, clusterPods...)
+	}
+	return pods, nil
+}
+
+// discoverPods runs the selected discovery method in one cluster
+func discoverPods(ctx context.Context, clientset kubernetes.Interface, snap *snapshot.Snapshot, verbose bool) ([]discovery.PodInfo, error) {
+	switch {
+	case snap != nil:
+		return discoverPodsFromSnapshot(ctx, clientset, snap, verbose)
+	case len(images) > 0:
+		return discoverPodsFromImages(ctx, clientset, images, verbose)
+	case labelSelector != "":
+		return discoverPodsFromLabelSelector(ctx, clientset, verbose)
+	case len(podNames) > 0:
+		return discoverPodsFromNames(ctx, clientset, verbose)
+	case len(workloads) > 0:
+		return discoverPodsFromWorkloads(ctx, clientset, verbose)
+	case annotated:
+		return discoverPodsFromAnnotations(ctx, clientset, verbose)
+	}
+	return nil, nil
+}
@@ -12,10 +12,12 @@ import (
 
 	"github.com/konflux-ci/coverport/cli/internal/discovery"
 	"github.com/konflux-ci/coverport/cli/internal/manifest"
+	"github.com/konflux-ci/coverport/cli/internal/rbac"
 	"github.com/konflux-ci/coverport/cli/internal/snapshot"
 	coverageclient "github.com/konflux-ci/coverport/cli/pkg/client"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -32,6 +34,11 @@ This command can discover pods in multiple ways:
   3. By explicit list of container images
   4. By label selector
   5. By explicit pod names
+  6. By workload (Deployment, StatefulSet, DaemonSet or Job)
+  7. By coverport.io/enabled=true pod annotations
+
+Pods can be collected from several clusters at once with --context, --kubeconfig
+or --kubeconfig-secret.
 
 Coverage data is organized by component and can be automatically pushed to an OCI registry.`,
 	Example: `  # Collect from localhost (for local development)
@@ -40,15 +47,33 @@ Coverage data is organized by component and can be automatically pushed to an OC
   # Collect using Konflux snapshot
   coverport collect --snapshot='{"components":[{"name":"app","containerImage":"quay.io/user/app@sha256:abc"}]}'
 
+  # Collect using the Konflux Snapshot resource in the cluster
+  coverport collect --snapshot-name=my-tenant/my-app-snapshot-abc12 --resolve-components
+
   # Collect from specific images
   coverport collect --images=quay.io/user/app1:latest,quay.io/user/app2:latest
 
   # Collect from pods with label selector
   coverport collect --namespace=default --label-selector=app=myapp
 
+  # Collect from the pods of workloads
+  coverport collect --namespace=default --workload=deployment/api,statefulset/db
+
+  # Collect from pods annotated with coverport.io/enabled=true
+  coverport collect --annotated
+
+  # Collect from a hub and a spoke cluster
+  coverport collect --images=quay.io/user/app:latest --context=hub,spoke-1
+
   # Collect and push to OCI registry
   coverport collect --snapshot="$SNAPSHOT" --push \
-    --registry=quay.io --repository=user/coverage-artifacts`,
+    --registry=quay.io --repository=user/coverage-artifacts
+
+  # Watch mode: snapshot coverage every 5 minutes during a 2 hour soak test
+  coverport collect --images=quay.io/user/app:latest --interval=5m --duration=2h
+
+  # Include coverage of pods that terminated during the tests (see 'coverport agent')
+  coverport collect --images=quay.io/user/app:latest --include-pushed=./pushed-coverage`,
 	Run: runCollect,
 }
 
@@ -57,10 +82,20 @@ var (
 	coverageURL   string
 	snapshotJSON  string
 	snapshotFile  string
+	snapshotName  string
+	resolveComps  bool
 	images        []string
 	namespace     string
 	labelSelector string
 	podNames      []string
+	workloads     []string
+	annotated     bool
+	imageMatch    string
+
+	// Namespace scope of cluster-wide discovery
+	includeNamespaces []string
+	excludeNamespaces []string
+	namespaceSelector string
 
 	// Coverage options
 	coveragePort int
@@ -69,6 +104,17 @@ var (
 	sourceDir    string
 	enableRemap  bool
 	filters      []string
+	pathPrefix   string
+	socketPath   string
+	transport    string
+
+	// Authentication options
+	coverageToken       string
+	coverageTokenSecret string
+	tlsCAFile           string
+	tlsCertFile         string
+	tlsKeyFile          string
+	tlsInsecure         bool
 
 	// Processing options
 	autoProcess  bool
@@ -83,8 +129,16 @@ var (
 	expiresAfter  string
 	artifactTitle string
 
+	// Watch options
+	watchInterval time.Duration
+	watchDuration time.Duration
+
+	// Pushed coverage options
+	includePushed string
+
 	// Advanced options
-	timeout int
+	timeout       int
+	skipPreflight bool
 )
 
 func init() {
@@ -94,10 +148,23 @@ func init() {
 	collectCmd.Flags().StringVar(&coverageURL, "url", "", "Direct HTTP URL to coverage server (e.g., http://localhost:53700)")
 	collectCmd.Flags().StringVar(&snapshotJSON, "snapshot", "", "Konflux/Tekton snapshot JSON")
 	collectCmd.Flags().StringVar(&snapshotFile, "snapshot-file", "", "Path to snapshot JSON file")
+	collectCmd.Flags().StringVar(&snapshotName, "snapshot-name", "", "Read the Konflux Snapshot resource <namespace>/<name> from the cluster")
+	collectCmd.Flags().BoolVar(&resolveComps, "resolve-components", false, "Fill in missing snapshot git sources from Konflux Component resources")
 	collectCmd.Flags().StringSliceVar(&images, "images", nil, "Comma-separated list of container images")
-	collectCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (empty = search all non-system namespaces)")
+	collectCmd.Flags().StringVar(&imageMatch, "image-match", "tag", "How strictly pods must match --images/--snapshot images: digest, tag or repository")
+	collectCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (empty = search all namespaces of the namespace scope)")
+	collectCmd.Flags().StringSliceVar(&includeNamespaces, "include-namespaces", nil, "Namespace globs to search when --namespace is not set (default: all; explicitly matched system namespaces are searched)")
+	collectCmd.Flags().StringSliceVar(&excludeNamespaces, "exclude-namespaces", nil, "Namespace globs to skip when --namespace is not set (default: system namespaces)")
+	collectCmd.Flags().StringVar(&namespaceSelector, "namespace-selector", "", "Only search namespaces with matching labels when --namespace is not set")
 	collectCmd.Flags().StringVarP(&labelSelector, "label-selector", "l", "", "Label selector to find pods")
 	collectCmd.Flags().StringSliceVar(&podNames, "pods", nil, "Comma-separated list of pod names (requires --namespace)")
+	collectCmd.Flags().BoolVar(&annotated, "annotated", false, "Discover pods annotated with coverport.io/enabled=true")
+	collectCmd.Flags().StringSliceVar(&workloads, "workload", nil, "Comma-separated list of workloads, e.g. deployment/foo,statefulset/bar (requires --namespace)")
+
+	// Cluster options
+	collectCmd.Flags().StringSliceVar(&kubeContexts, "context", nil, "Comma-separated list of kubeconfig contexts to collect from (default: current context)")
+	collectCmd.Flags().StringSliceVar(&kubeconfigs, "kubeconfig", nil, "Comma-separated list of kubeconfig files to collect from, each with its current context")
+	collectCmd.Flags().StringSliceVar(&kubeconfigSecrets, "kubeconfig-secret", nil, "Comma-separated list of Secrets holding kubeconfigs (<namespace>/<name>[/<key>], default key: kubeconfig)")
 
 	// Coverage options
 	collectCmd.Flags().IntVar(&coveragePort, "port", 53700, "Coverage server port (tries 53700 then 9095 when not set)")
@@ -106,6 +173,17 @@ func init() {
 	collectCmd.Flags().StringVar(&sourceDir, "source-dir", ".", "Source directory for path remapping")
 	collectCmd.Flags().BoolVar(&enableRemap, "remap-paths", true, "Enable automatic path remapping")
 	collectCmd.Flags().StringSliceVar(&filters, "filters", []string{"coverage_server"}, "File patterns to filter from coverage")
+	collectCmd.Flags().StringVar(&pathPrefix, "path-prefix", "", "Path prefix of the coverage endpoints when mounted on the application's server (e.g. /debug)")
+	collectCmd.Flags().StringVar(&transport, "transport", coverageclient.TransportAuto, "How to reach coverage servers in pods: auto (direct when running in a cluster, else port-forward, then proxy), direct, port-forward or proxy (API server pods/proxy)")
+	collectCmd.Flags().StringVar(&socketPath, "socket", "", "Collect via exec from the coverage server's Unix socket at this path in the container (requires curl in the image)")
+
+	// Authentication options
+	collectCmd.Flags().StringVar(&coverageToken, "coverage-token", "", "Bearer token for coverage servers (can also use COVERAGE_TOKEN env var)")
+	collectCmd.Flags().StringVar(&tlsCAFile, "tls-ca", "", "CA bundle to verify HTTPS coverage servers (--url)")
+	collectCmd.Flags().StringVar(&tlsCertFile, "tls-cert", "", "Client certificate for coverage servers requiring mTLS (--url)")
+	collectCmd.Flags().StringVar(&tlsKeyFile, "tls-key", "", "Client certificate key (--url)")
+	collectCmd.Flags().BoolVar(&tlsInsecure, "tls-insecure-skip-verify", false, "Skip HTTPS certificate verification of coverage servers (--url, testing only)")
+	collectCmd.Flags().StringVar(&coverageTokenSecret, "coverage-token-secret", "", "Read the coverage server token from a Secret in the pod's namespace (<name>[/<key>], default key: token)")
 
 	// Processing options
 	collectCmd.Flags().BoolVar(&autoProcess, "auto-process", true, "Automatically process coverage reports")
@@ -121,8 +199,16 @@ func init() {
 	collectCmd.Flags().StringVar(&expiresAfter, "expires-after", "30d", "Artifact expiration (e.g., '30d', '1y')")
 	collectCmd.Flags().StringVar(&artifactTitle, "artifact-title", "", "Artifact title")
 
+	// Watch options
+	collectCmd.Flags().DurationVar(&watchInterval, "interval", 0, "Collect periodic snapshots at this interval (watch mode, e.g. 5m)")
+	collectCmd.Flags().DurationVar(&watchDuration, "duration", 0, "How long to watch (default: until interrupted; requires --interval)")
+
+	// Pushed coverage options
+	collectCmd.Flags().StringVar(&includePushed, "include-pushed", "", "Include coverage pushed by terminated pods (agent output or COVERAGE_DUMP_DIR volume)")
+
 	// Advanced options
 	collectCmd.Flags().IntVar(&timeout, "timeout", 120, "Timeout in seconds for operations")
+	collectCmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "Skip checking Kubernetes permissions before discovery and collection")
 }
 
 func runCollect(cmd *cobra.Command, args []string) {
@@ -142,6 +228,9 @@ func runCollect(cmd *cobra.Command, args []string) {
 	if snapshotFile != "" {
 		discoveryMethods++
 	}
+	if snapshotName != "" {
+		discoveryMethods++
+	}
 	if len(images) > 0 {
 		discoveryMethods++
 	}
@@ -151,12 +240,18 @@ func runCollect(cmd *cobra.Command, args []string) {
 	if len(podNames) > 0 {
 		discoveryMethods++
 	}
+	if len(workloads) > 0 {
+		discoveryMethods++
+	}
+	if annotated {
+		discoveryMethods++
+	}
 
 	if discoveryMethods == 0 {
-		exitWithError("No discovery method specified. Use --url, --snapshot, --images, --label-selector, or --pods")
+		exitWithError("No discovery method specified. Use --url, --snapshot, --snapshot-name, --images, --label-selector, --pods, --workload, or --annotated")
 	}
 	if discoveryMethods > 1 {
-		exitWithError("Multiple discovery methods specified. Use only one of: --url, --snapshot, --images, --label-selector, or --pods")
+		exitWithError("Multiple discovery methods specified. Use only one of: --url, --snapshot, --snapshot-name, --images, --label-selector, --pods, --workload, or --annotated")
 	}
 
 	if push && repository == "" {
@@ -167,6 +262,34 @@ func runCollect(cmd *cobra.Command, args []string) {
 		exitWithError("--namespace is required when using --pods")
 	}
 
+	if len(workloads) > 0 && namespace == "" {
+		exitWithError("--namespace is required when using --workload")
+	}
+
+	if err := namespaceScope().Validate(); err != nil {
+		exitWithError("%v", err)
+	}
+
+	switch transport {
+	case coverageclient.TransportAuto, coverageclient.TransportDirect, coverageclient.TransportPortForward, coverageclient.TransportProxy:
+	default:
+		exitWithError("Invalid --transport %q (expected auto, direct, port-forward or proxy)", transport)
+	}
+	if transport == coverageclient.TransportProxy && (coverageToken != "" || coverageTokenSecret != "" || os.Getenv(coverageclient.TokenEnvVar) != "") {
+		exitWithError("--transport=proxy cannot send the coverage token (the API server drops the Authorization header); use port-forward or direct")
+	}
+
+	if socketPath != "" && (coverageURL != "" || watchInterval > 0) {
+		exitWithError("--socket cannot be used with --url or --interval")
+	}
+
+	if watchInterval < 0 || watchDuration < 0 {
+		exitWithError("--interval and --duration must not be negative")
+	}
+	if watchDuration > 0 && watchInterval == 0 {
+		exitWithError("--duration requires --interval")
+	}
+
 	// Generate test name if not provided
 	if testName == "" {
 		testName = fmt.Sprintf("coverage-%s", time.Now().Format("20060102-150405"))
@@ -199,70 +322,105 @@ func runCollect(cmd *cobra.Command, args []string) {
 
 	// Handle direct URL collection (bypass Kubernetes)
 	if coverageURL != "" {
-		collectFromURL(ctx, verbose)
+		snapshotsDir := ""
+		if watchInterval > 0 {
+			snapshotsDir = watchURL()
+		}
+		collectFromURL(ctx, verbose, snapshotsDir)
 		return
 	}
 
-	// Setup Kubernetes client
-	clientset, restConfig := setupKubeClient()
-
-	// Discover pods
-	var podsToCollect []discovery.PodInfo
-	var err error
-
-	if snapshotJSON != "" || snapshotFile != "" {
-		podsToCollect, err = discoverPodsFromSnapshot(ctx, clientset, verbose)
-	} else if len(images) > 0 {
-		podsToCollect, err = discoverPodsFromImages(ctx, clientset, images, verbose)
-	} else if labelSelector != "" {
-		podsToCollect, err = discoverPodsFromLabelSelector(ctx, clientset, verbose)
-	} else if len(podNames) > 0 {
-		podsToCollect, err = discoverPodsFromNames(ctx, clientset, verbose)
+	// Setup Kubernetes clients and discover pods
+	clusters := setupKubeClusters(ctx)
+	if !skipPreflight {
+		preflight(ctx, clusters, func(cluster kubeCluster, hub bool) []rbac.Requirement {
+			return discoveryRequirements(ctx, cluster, hub)
+		})
 	}
-
+	podsToCollect, err := discoverPodsInClusters(ctx, clusters, verbose)
 	if err != nil {
 		exitWithError("Pod discovery failed: %v", err)
 	}
 
-	if len(podsToCollect) == 0 {
+	// Check collection permissions in the namespaces of the discovered pods
+	if !skipPreflight {
+		preflight(ctx, clusters, func(cluster kubeCluster, hub bool) []rbac.Requirement {
+			var reqs []rbac.Requirement
+			for _, ns := range podNamespaces(podsToCollect, cluster.name) {
+				reqs = append(reqs, collectionRequirements(ns)...)
+			}
+			return reqs
+		})
+	}
+
+	if len(podsToCollect) == 0 && includePushed == "" {
 		exitWithError("No running pods found matching the criteria")
 	}
 
 	fmt.Printf("\nDiscovered %d pod(s) for coverage collection:\n", len(podsToCollect))
 	for i, pod := range podsToCollect {
-		fmt.Printf("  %d. %s/%s (component: %s, image: %s)\n",
-			i+1, pod.Namespace, pod.Name, pod.ComponentName, truncateImage(pod.Image))
+		fmt.Printf("  %d. %s%s/%s (component: %s, image: %s)\n",
+			i+1, clusterPrefix(pod.Cluster), pod.Namespace, pod.Name, pod.ComponentName, truncateImage(pod.Image))
 	}
 	fmt.Println()
 
+	// In watch mode, snapshot coverage periodically; the regular collection
+	// below then takes the final snapshot
+	var snapshotDirs map[string]string
+	if watchInterval > 0 {
+		snapshotDirs = watchPods(podsToCollect, coveragePorts, portExplicit, verbose)
+
+		// The timeout applies to the final collection, not to the watch
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
 	// Create collection manifest
 	collectionManifest := manifest.NewCollectionManifest(testName, manifest.CollectionParameters{
 		CoveragePort: coveragePort,
 		Filters:      filters,
-		Format:       "go", // TODO: support auto-detection
 		Namespace:    namespace,
 	})
 
 	// Collect coverage from each pod
 	successCount := 0
 	for _, podInfo := range podsToCollect {
-		componentInfo, err := collectFromPod(ctx, restConfig, podInfo, coveragePorts, portExplicit, verbose)
+		componentInfos, err := collectFromPod(ctx, clusterRestConfig(podInfo.Cluster), podInfo, coveragePorts, portExplicit, verbose)
 		if err != nil {
-			printWarning("Failed to collect from %s/%s: %v", podInfo.Namespace, podInfo.Name, err)
+			printWarning("Failed to collect from %s%s/%s: %v", clusterPrefix(podInfo.Cluster), podInfo.Namespace, podInfo.Name, err)
 		} else {
 			successCount++
 			// Add successful collection to manifest
-			if componentInfo != nil {
-				collectionManifest.AddComponent(*componentInfo)
+			for _, componentInfo := range componentInfos {
+				componentInfo.SnapshotsDir = snapshotDirs[podKey(podInfo)]
+				collectionManifest.AddComponent(componentInfo)
 			}
 		}
 	}
 
-	if successCount == 0 {
+	// Include coverage pushed by pods that terminated before collection
+	pushedCount := 0
+	if includePushed != "" {
+		pushed, err := includePushedCoverage(includePushed, outputDir)
+		if err != nil {
+			printWarning("Failed to include pushed coverage from %s: %v", includePushed, err)
+		}
+		for _, componentInfo := range pushed {
+			processPushedCoverage(componentInfo, verbose)
+			collectionManifest.AddComponent(componentInfo)
+		}
+		pushedCount = len(pushed)
+	}
+
+	if successCount == 0 && pushedCount == 0 {
 		exitWithError("Failed to collect coverage from any pods")
 	}
+	collectionManifest.CollectionParams.Format = commonFormat(collectionManifest.Components)
 
 	printSuccess("Collected coverage from %d/%d pod(s)", successCount, len(podsToCollect))
+	if includePushed != "" {
+		printSuccess("Included pushed coverage from %d terminated pod(s)", pushedCount)
+	}
 
 	// Save collection manifest
 	if err := collectionManifest.Save(outputDir); err != nil {
@@ -304,11 +462,32 @@ func setupKubeClient() (kubernetes.Interface, *rest.Config) {
 	return clientset, config
 }
 
-func discoverPodsFromSnapshot(ctx context.Context, clientset kubernetes.Interface, verbose bool) ([]discovery.PodInfo, error) {
+// loadSnapshot reads the snapshot given by --snapshot, --snapshot-file or
+// --snapshot-name, reading Konflux resources from the cluster behind restConfig
+func loadSnapshot(ctx context.Context, restConfig *rest.Config, verbose bool) (*snapshot.Snapshot, error) {
 	var snap *snapshot.Snapshot
 	var err error
 
-	if snapshotFile != "" {
+	var dynamicClient dynamic.Interface
+	if snapshotName != "" || resolveComps {
+		if dynamicClient, err = dynamic.NewForConfig(restConfig); err != nil {
+			return nil, fmt.Errorf("create dynamic client: %w", err)
+		}
+	}
+
+	// Namespace of the Konflux resources
+	snapshotNamespace := namespace
+	if snapshotName != "" {
+		var name string
+		snapshotNamespace, name, err = snapshot.ParseSnapshotName(snapshotName, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if verbose {
+			fmt.Printf("Reading snapshot %s/%s from the cluster\n", snapshotNamespace, name)
+		}
+		snap, err = snapshot.FetchSnapshot(ctx, dynamicClient, snapshotNamespace, name)
+	} else if snapshotFile != "" {
 		if verbose {
 			fmt.Printf("Reading snapshot from file: %s\n", snapshotFile)
 		}
@@ -324,13 +503,38 @@ func discoverPodsFromSnapshot(ctx context.Context, clientset kubernetes.Interfac
 		return nil, fmt.Errorf("parse snapshot: %w", err)
 	}
 
+	if resolveComps {
+		if snapshotNamespace == "" {
+			return nil, fmt.Errorf("--resolve-components requires --namespace or --snapshot-name")
+		}
+		snap.ResolveComponentSources(ctx, dynamicClient, snapshotNamespace)
+	}
+
 	fmt.Printf("Snapshot contains %d component(s):\n", len(snap.Components))
 	for i, comp := range snap.Components {
 		fmt.Printf("  %d. %s: %s\n", i+1, comp.Name, truncateImage(comp.ContainerImage))
 	}
 
+	return snap, nil
+}
+
+func discoverPodsFromSnapshot(ctx context.Context, clientset kubernetes.Interface, snap *snapshot.Snapshot, verbose bool) ([]discovery.PodInfo, error) {
 	images := snap.GetImages()
-	return discoverPodsFromImages(ctx, clientset, images, verbose)
+	pods, err := discoverPodsFromImages(ctx, clientset, images, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pass the resolved git sources on, so processing doesn't need image metadata
+	if resolveComps {
+		for i := range pods {
+			if comp := snap.GetComponentByImage(pods[i].Image); comp != nil {
+				pods[i].SourceRepo = comp.Source.Git.URL
+				pods[i].SourceCommit = comp.Source.Git.Revision
+			}
+		}
+	}
+	return pods, nil
 }
 
 func discoverPodsFromImages(ctx context.Context, clientset kubernetes.Interface, images []string, verbose bool) ([]discovery.PodInfo, error) {
@@ -341,8 +545,29 @@ func discoverPodsFromImages(ctx context.Context, clientset kubernetes.Interface,
 		}
 	}
 
-	disco := discovery.NewImageDiscovery(clientset)
-	return disco.DiscoverPodsByImages(ctx, images, namespace)
+	mode, err := discovery.ParseMatchMode(imageMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	disco := newImageDiscovery(clientset)
+	disco.SetMatchMode(mode)
+	pods, err := disco.DiscoverPodsByImages(ctx, images, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if nearMisses := disco.NearMisses(); len(nearMisses) > 0 {
+		fmt.Printf("Skipped %d pod(s) running other versions of the images (--image-match=%s):\n", len(nearMisses), mode)
+		for _, miss := range nearMisses {
+			fmt.Printf("  - %s/%s (container %s): %s\n", miss.Namespace, miss.Name, miss.ContainerName, miss.Reason)
+			if verbose {
+				fmt.Printf("      running: %s\n      wanted:  %s\n", miss.Image, miss.Wanted)
+			}
+		}
+	}
+
+	return pods, nil
 }
 
 func discoverPodsFromLabelSelector(ctx context.Context, clientset kubernetes.Interface, verbose bool) ([]discovery.PodInfo, error) {
@@ -358,6 +583,61 @@ func discoverPodsFromLabelSelector(ctx context.Context, clientset kubernetes.Int
 	return disco.DiscoverPodsByLabelSelector(ctx, namespace, labelSelector)
 }
 
+func discoverPodsFromWorkloads(ctx context.Context, clientset kubernetes.Interface, verbose bool) ([]discovery.PodInfo, error) {
+	if namespace == "" {
+		return nil, fmt.Errorf("--namespace is required when using --workload")
+	}
+
+	disco := discovery.NewImageDiscovery(clientset)
+	var pods []discovery.PodInfo
+	for _, ref := range workloads {
+		workload, err := discovery.ParseWorkload(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if verbose {
+			fmt.Printf("Searching for pods of %s (namespace: %s)\n", workload, namespace)
+		}
+
+		workloadPods, err := disco.DiscoverPodsByWorkload(ctx, namespace, workload)
+		if err != nil {
+			return nil, err
+		}
+		if len(workloadPods) == 0 {
+			printWarning("No running pods found for %s", workload)
+		}
+		pods = append(pods, workloadPods...)
+	}
+
+	return pods, nil
+}
+
+// namespaceScope returns the namespaces searched by cluster-wide discovery
+func namespaceScope() discovery.NamespaceScope {
+	return discovery.NamespaceScope{
+		Include:       includeNamespaces,
+		Exclude:       excludeNamespaces,
+		LabelSelector: namespaceSelector,
+	}
+}
+
+// newImageDiscovery creates pod discovery for the namespace scope of the flags
+func newImageDiscovery(clientset kubernetes.Interface) *discovery.ImageDiscovery {
+	disco := discovery.NewImageDiscovery(clientset)
+	disco.SetNamespaceScope(namespaceScope())
+	return disco
+}
+
+func discoverPodsFromAnnotations(ctx context.Context, clientset kubernetes.Interface, verbose bool) ([]discovery.PodInfo, error) {
+	if verbose {
+		fmt.Printf("Searching for pods annotated with %s=true\n", discovery.EnabledAnnotation)
+	}
+
+	disco := newImageDiscovery(clientset)
+	return disco.DiscoverPodsByAnnotation(ctx, namespace)
+}
+
 func discoverPodsFromNames(ctx context.Context, clientset kubernetes.Interface, verbose bool) ([]discovery.PodInfo, error) {
 	if verbose {
 		fmt.Printf("Using explicitly specified pods: %v\n", podNames)
@@ -396,8 +676,8 @@ func discoverPodsFromNames(ctx context.Context, clientset kubernetes.Interface,
 	return pods, nil
 }
 
-func collectFromPod(ctx context.Context, restConfig *rest.Config, podInfo discovery.PodInfo, fallbackPorts []int, portExplicit bool, verbose bool) (*manifest.ComponentInfo, error) {
-	fmt.Printf("\nCollecting from: %s/%s (component: %s)\n", podInfo.Namespace, podInfo.Name, podInfo.ComponentName)
+func collectFromPod(ctx context.Context, restConfig *rest.Config, podInfo discovery.PodInfo, fallbackPorts []int, portExplicit bool, verbose bool) ([]manifest.ComponentInfo, error) {
+	fmt.Printf("\nCollecting from: %s%s/%s (component: %s)\n", clusterPrefix(podInfo.Cluster), podInfo.Namespace, podInfo.Name, podInfo.ComponentName)
 
 	// Create component-specific output directory
 	componentDir := filepath.Join(outputDir, podInfo.ComponentName)
@@ -406,7 +686,7 @@ func collectFromPod(ctx context.Context, restConfig *rest.Config, podInfo discov
 	}
 
 	// Create coverage client for this pod's namespace
-	client, err := coverageclient.NewClient(podInfo.Namespace, componentDir)
+	client, err := coverageclient.NewClientForConfig(restConfig, podInfo.Namespace, componentDir)
 	if err != nil {
 		return nil, fmt.Errorf("create coverage client: %w", err)
 	}
@@ -417,24 +697,56 @@ func collectFromPod(ctx context.Context, restConfig *rest.Config, podInfo discov
 	if len(filters) > 0 {
 		client.SetDefaultFilters(filters)
 	}
+	if err := configureCoverageToken(ctx, client); err != nil {
+		return nil, err
+	}
+	if err := configureTransport(client); err != nil {
+		return nil, err
+	}
+	client.SetPathPrefix(pathPrefix)
+
+	componentTestName := fmt.Sprintf("%s-%s", testName, podInfo.ComponentName)
+	if podInfo.Cluster != "" {
+		// The same component may run in several clusters
+		componentTestName += "-" + clusterDirName(podInfo.Cluster)
+	}
+	if socketPath != "" {
+		if err := client.CollectCoverageViaSocket(ctx, podInfo.Name, podInfo.ContainerName, socketPath, componentTestName); err != nil {
+			return nil, fmt.Errorf("collect coverage via socket: %w", err)
+		}
+		return processPodCoverage(client, podInfo, componentTestName, nil, verbose)
+	}
 
 	// Determine which port(s) to try.
 	// When --port is explicit, use only that port.
-	// Otherwise, exec into the target container to detect the listening port;
+	// Otherwise, exec into the pod to find every coverage server;
 	// fall back to the static list if detection fails.
 	ports := fallbackPorts
-	if !portExplicit && podInfo.ContainerName != "" {
-		detected, err := client.DetectCoveragePort(ctx, podInfo.Name, podInfo.ContainerName)
+	var servers []coverageclient.CoverageServer
+	if !portExplicit {
+		servers, err = client.DiscoverCoverageServers(ctx, podInfo.Name, podInfo.ContainerName)
 		if err == nil {
-			fmt.Printf("  Detected coverage port %d in container %s\n", detected, podInfo.ContainerName)
-			ports = []int{detected}
+			for _, server := range servers {
+				fmt.Printf("  Detected coverage server on port %d (%s)\n", server.Port, server.DirName())
+			}
 		} else if verbose {
 			fmt.Printf("  Warning: Port detection failed (%v), falling back to %v\n", err, fallbackPorts)
 		}
 	}
 
+	// Several servers (processes or containers) in the pod: collect each into
+	// its own subdirectory
+	if len(servers) > 1 {
+		return collectFromServers(ctx, client, podInfo, componentTestName, servers, verbose)
+	}
+
+	var server *coverageclient.CoverageServer
+	if len(servers) == 1 {
+		server = &servers[0]
+		ports = []int{server.Port}
+	}
+
 	// Collect coverage, trying each port in order
-	componentTestName := fmt.Sprintf("%s-%s", testName, podInfo.ComponentName)
 	var lastErr error
 	for _, port := range ports {
 		lastErr = client.CollectCoverageFromPodWithContainer(ctx, podInfo.Name, podInfo.ContainerName, componentTestName, port)
@@ -449,6 +761,39 @@ func collectFromPod(ctx context.Context, restConfig *rest.Config, podInfo discov
 		return nil, fmt.Errorf("collect coverage (tried ports %v): %w", ports, lastErr)
 	}
 
+	return processPodCoverage(client, podInfo, componentTestName, server, verbose)
+}
+
+// collectFromServers collects coverage from each coverage server of a pod into
+// <componentTestName>/<binary>-<pid>, returning one manifest entry per server
+func collectFromServers(ctx context.Context, client *coverageclient.CoverageClient, podInfo discovery.PodInfo, componentTestName string, servers []coverageclient.CoverageServer, verbose bool) ([]manifest.ComponentInfo, error) {
+	var componentInfos []manifest.ComponentInfo
+	var lastErr error
+	for i := range servers {
+		server := &servers[i]
+		serverTestName := filepath.Join(componentTestName, server.DirName())
+		if err := client.CollectCoverageFromPodWithContainer(ctx, podInfo.Name, podInfo.ContainerName, serverTestName, server.Port); err != nil {
+			printWarning("Failed to collect from %s on port %d: %v", server.DirName(), server.Port, err)
+			lastErr = err
+			continue
+		}
+
+		infos, err := processPodCoverage(client, podInfo, serverTestName, server, verbose)
+		if err != nil {
+			return nil, err
+		}
+		componentInfos = append(componentInfos, infos...)
+	}
+
+	if len(componentInfos) == 0 {
+		return nil, fmt.Errorf("collect coverage from %d server(s): %w", len(servers), lastErr)
+	}
+	return componentInfos, nil
+}
+
+// processPodCoverage generates the reports of coverage collected from a pod and
+// returns its manifest entry. server is nil when the server is unknown.
+func processPodCoverage(client *coverageclient.CoverageClient, podInfo discovery.PodInfo, componentTestName string, server *coverageclient.CoverageServer, verbose bool) ([]manifest.ComponentInfo, error) {
 	// Note: Component metadata is now stored in the top-level manifest, not as separate files
 
 	// Process reports if enabled
@@ -468,15 +813,82 @@ func collectFromPod(ctx context.Context, restConfig *rest.Config, podInfo discov
 	}
 
 	// Return component info for manifest
-	return &manifest.ComponentInfo{
+	componentInfo := manifest.ComponentInfo{
 		Name:          podInfo.ComponentName,
 		Image:         podInfo.Image,
 		CoverageDir:   filepath.Join(podInfo.ComponentName, componentTestName),
 		Namespace:     podInfo.Namespace,
 		PodName:       podInfo.Name,
 		ContainerName: podInfo.ContainerName,
+		Cluster:       podInfo.Cluster,
 		CollectedAt:   time.Now().Format(time.RFC3339),
-	}, nil
+		Format:        componentFormat(podInfo, client.DetectedFormat()),
+		RepoURL:       podInfo.SourceRepo,
+		CommitSHA:     podInfo.SourceCommit,
+	}
+	if server != nil {
+		componentInfo.Binary = server.Binary
+		componentInfo.Pid = server.Pid
+		componentInfo.Port = server.Port
+	}
+	return []manifest.ComponentInfo{componentInfo}, nil
+}
+
+// componentFormat returns the format to record for coverage collected from a
+// pod. The format detected from the payload wins; the coverport.io/format
+// annotation is only used when nothing was detected, and a mismatching or
+// unknown annotation is reported.
+func componentFormat(podInfo discovery.PodInfo, detected coverageclient.CoverageFormat) string {
+	if podInfo.Format == "" {
+		return string(detected)
+	}
+
+	annotated := coverageclient.ParseCoverageFormat(podInfo.Format)
+	switch {
+	case annotated == "":
+		printWarning("Ignoring unknown %s=%q on pod %s", discovery.FormatAnnotation, podInfo.Format, podInfo.Name)
+	case detected == "":
+		return string(annotated)
+	case annotated != detected:
+		printWarning("Pod %s is annotated with %s=%s but serves %s coverage, using %s", podInfo.Name, discovery.FormatAnnotation, podInfo.Format, detected, detected)
+	}
+	return string(detected)
+}
+
+// processPushedCoverage generates the text reports of pushed Go coverage, like
+// collectFromPod does for live pods
+func processPushedCoverage(componentInfo manifest.ComponentInfo, verbose bool) {
+	if !autoProcess || skipGenerate {
+		return
+	}
+	coverageDir := filepath.Join(outputDir, componentInfo.CoverageDir)
+	if metaFiles, _ := filepath.Glob(filepath.Join(coverageDir, "covmeta.*")); len(metaFiles) == 0 {
+		return
+	}
+
+	if verbose {
+		fmt.Printf("  📝 Processing pushed coverage of %s/%s...\n", componentInfo.Name, componentInfo.PodName)
+	}
+
+	client, err := coverageclient.NewClientForURL(filepath.Dir(coverageDir))
+	if err != nil {
+		printWarning("Failed to create coverage client: %v", err)
+		return
+	}
+	client.SetSourceDirectory(sourceDir)
+	client.SetPathRemapping(enableRemap)
+	if len(filters) > 0 {
+		client.SetDefaultFilters(filters)
+	}
+
+	name := filepath.Base(coverageDir)
+	if err := client.GenerateCoverageReport(name); err != nil {
+		printWarning("Failed to generate report: %v", err)
+	} else if !skipFilter {
+		if err := client.FilterCoverageReport(name); err != nil {
+			printWarning("Failed to filter report: %v", err)
+		}
+	}
 }
 
 func pushCoverageArtifact(ctx context.Context, pods []discovery.PodInfo) error {
@@ -539,7 +951,7 @@ func pushCoverageArtifact(ctx context.Context, pods []discovery.PodInfo) error {
 	return nil
 }
 
-func collectFromURL(ctx context.Context, verbose bool) {
+func collectFromURL(ctx context.Context, verbose bool, snapshotsDir string) {
 	fmt.Printf("\n📡 Collecting coverage from URL: %s\n", coverageURL)
 
 	// Create coverage client (without Kubernetes)
@@ -550,6 +962,12 @@ func collectFromURL(ctx context.Context, verbose bool) {
 
 	// Set filters on the client
 	client.SetDefaultFilters(filters)
+	if err := configureCoverageToken(ctx, client); err != nil {
+		exitWithError("Failed to configure coverage token: %v", err)
+	}
+	if err := configureCoverageTLS(client); err != nil {
+		exitWithError("Failed to configure TLS: %v", err)
+	}
 
 	// Collect coverage from URL
 	fmt.Printf("  Sending coverage collection request...\n")
@@ -562,21 +980,24 @@ func collectFromURL(ctx context.Context, verbose bool) {
 
 	// Create a simple manifest for URL-based collection
 	componentName := "direct-url"
+	format := string(client.DetectedFormat())
 	collectionManifest := manifest.NewCollectionManifest(testName, manifest.CollectionParameters{
 		CoveragePort: 0, // Not applicable for URL collection
 		Filters:      filters,
-		Format:       "go",
+		Format:       format,
 		Namespace:    "",
 	})
 
 	// Add component info for the URL collection
 	collectionManifest.AddComponent(manifest.ComponentInfo{
-		Name:        componentName,
-		Image:       coverageURL, // Store the URL in the image field for reference
-		CoverageDir: testName,    // Store relative path, will be joined with coverageDir during processing
-		Namespace:   "",
-		PodName:     "",
-		CollectedAt: time.Now().Format(time.RFC3339),
+		Name:         componentName,
+		Image:        coverageURL, // Store the URL in the image field for reference
+		CoverageDir:  testName,    // Store relative path, will be joined with coverageDir during processing
+		Namespace:    "",
+		PodName:      "",
+		CollectedAt:  time.Now().Format(time.RFC3339),
+		Format:       format,
+		SnapshotsDir: snapshotsDir,
 	})
 
 	// Save manifest
@@ -592,6 +1013,19 @@ func collectFromURL(ctx context.Context, verbose bool) {
 	fmt.Printf("   coverport process --coverage-dir=%s\n", outputDir)
 }
 
+// commonFormat returns the coverage format shared by all components, or an
+// empty string if they differ or it is unknown
+func commonFormat(components []manifest.ComponentInfo) string {
+	format := ""
+	for i, component := range components {
+		if i > 0 && component.Format != format {
+			return ""
+		}
+		format = component.Format
+	}
+	return format
+}
+
 func truncateImage(image string) string {
 	if len(image) <= 60 {
 		return image
@@ -625,3 +1059,50 @@ func extractComponentNameFromLabels(labels map[string]string, image string) stri
 
 	return "unknown"
 }
+
+// configureTransport sets how the client reaches coverage servers in pods (--transport)
+func configureTransport(client *coverageclient.CoverageClient) error {
+	podTransport, err := client.TransportByName(transport)
+	if err != nil {
+		return err
+	}
+	client.SetTransport(podTransport)
+	return nil
+}
+
+// configureCoverageToken sets the coverage server token from --coverage-token,
+// or from --coverage-token-secret in the client's namespace. Without either,
+// the client keeps the COVERAGE_TOKEN environment variable.
+func configureCoverageToken(ctx context.Context, client *coverageclient.CoverageClient) error {
+	if coverageToken != "" {
+		client.SetToken(coverageToken)
+		return nil
+	}
+	if coverageTokenSecret == "" {
+		return nil
+	}
+
+	name, key, _ := strings.Cut(coverageTokenSecret, "/")
+	token, err := client.TokenFromSecret(ctx, name, key)
+	if err != nil {
+		return fmt.Errorf("read coverage token: %w", err)
+	}
+	client.SetToken(token)
+	return nil
+}
+
+// configureCoverageTLS applies the --tls-* flags to a client collecting from --url
+func configureCoverageTLS(client *coverageclient.CoverageClient) error {
+	if tlsCAFile == "" && tlsCertFile == "" && tlsKeyFile == "" && !tlsInsecure {
+		return nil
+	}
+	if tlsInsecure {
+		printWarning("TLS certificate verification of the coverage server is disabled")
+	}
+	return client.SetTLSConfig(coverageclient.TLSOptions{
+		CAFile:             tlsCAFile,
+		CertFile:           tlsCertFile,
+		KeyFile:            tlsKeyFile,
+		InsecureSkipVerify: tlsInsecure,
+	})
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -26,7 +27,16 @@ This is useful for:
   coverport discover --images=quay.io/user/app:latest
 
   # Discover pods by label selector
-  coverport discover --namespace=default --label-selector=app=myapp`,
+  coverport discover --namespace=default --label-selector=app=myapp
+
+  # Discover the pods of a deployment
+  coverport discover --namespace=default --workload=deployment/api
+
+  # Discover pods annotated with coverport.io/enabled=true
+  coverport discover --annotated
+
+  # Discover pods in several clusters
+  coverport discover --annotated --context=hub,spoke-1`,
 	Run: runDiscover,
 }
 
@@ -40,10 +50,21 @@ func init() {
 	// Reuse the same flags as collect command for discovery
 	discoverCmd.Flags().StringVar(&snapshotJSON, "snapshot", "", "Konflux/Tekton snapshot JSON")
 	discoverCmd.Flags().StringVar(&snapshotFile, "snapshot-file", "", "Path to snapshot JSON file")
+	discoverCmd.Flags().StringVar(&snapshotName, "snapshot-name", "", "Read the Konflux Snapshot resource <namespace>/<name> from the cluster")
+	discoverCmd.Flags().BoolVar(&resolveComps, "resolve-components", false, "Fill in missing snapshot git sources from Konflux Component resources")
 	discoverCmd.Flags().StringSliceVar(&images, "images", nil, "Comma-separated list of container images")
-	discoverCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (empty = search all non-system namespaces)")
+	discoverCmd.Flags().StringVar(&imageMatch, "image-match", "tag", "How strictly pods must match --images/--snapshot images: digest, tag or repository")
+	discoverCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (empty = search all namespaces of the namespace scope)")
+	discoverCmd.Flags().StringSliceVar(&includeNamespaces, "include-namespaces", nil, "Namespace globs to search when --namespace is not set (default: all; explicitly matched system namespaces are searched)")
+	discoverCmd.Flags().StringSliceVar(&excludeNamespaces, "exclude-namespaces", nil, "Namespace globs to skip when --namespace is not set (default: system namespaces)")
+	discoverCmd.Flags().StringVar(&namespaceSelector, "namespace-selector", "", "Only search namespaces with matching labels when --namespace is not set")
 	discoverCmd.Flags().StringVarP(&labelSelector, "label-selector", "l", "", "Label selector to find pods")
 	discoverCmd.Flags().StringSliceVar(&podNames, "pods", nil, "Comma-separated list of pod names (requires --namespace)")
+	discoverCmd.Flags().BoolVar(&annotated, "annotated", false, "Discover pods annotated with coverport.io/enabled=true")
+	discoverCmd.Flags().StringSliceVar(&workloads, "workload", nil, "Comma-separated list of workloads, e.g. deployment/foo,statefulset/bar (requires --namespace)")
+	discoverCmd.Flags().StringSliceVar(&kubeContexts, "context", nil, "Comma-separated list of kubeconfig contexts to discover pods in")
+	discoverCmd.Flags().StringSliceVar(&kubeconfigs, "kubeconfig", nil, "Comma-separated list of kubeconfig files to discover pods in")
+	discoverCmd.Flags().StringSliceVar(&kubeconfigSecrets, "kubeconfig-secret", nil, "Comma-separated list of Secrets holding kubeconfigs (<namespace>/<name>[/<key>], default key: kubeconfig)")
 	discoverCmd.Flags().BoolVar(&discoverVerbose, "verbose", false, "Enable verbose output")
 }
 
@@ -59,6 +80,9 @@ func runDiscover(cmd *cobra.Command, args []string) {
 	if snapshotFile != "" {
 		discoveryMethods++
 	}
+	if snapshotName != "" {
+		discoveryMethods++
+	}
 	if len(images) > 0 {
 		discoveryMethods++
 	}
@@ -68,34 +92,30 @@ func runDiscover(cmd *cobra.Command, args []string) {
 	if len(podNames) > 0 {
 		discoveryMethods++
 	}
+	if len(workloads) > 0 {
+		discoveryMethods++
+	}
+	if annotated {
+		discoveryMethods++
+	}
 
 	if discoveryMethods == 0 {
-		exitWithError("No discovery method specified. Use --snapshot, --images, --label-selector, or --pods")
+		exitWithError("No discovery method specified. Use --snapshot, --snapshot-name, --images, --label-selector, --pods, --workload, or --annotated")
 	}
 	if discoveryMethods > 1 {
-		exitWithError("Multiple discovery methods specified. Use only one of: --snapshot, --images, --label-selector, or --pods")
+		exitWithError("Multiple discovery methods specified. Use only one of: --snapshot, --snapshot-name, --images, --label-selector, --pods, --workload, or --annotated")
+	}
+
+	if err := namespaceScope().Validate(); err != nil {
+		exitWithError("%v", err)
 	}
 
 	fmt.Println("coverport - Pod Discovery")
 	fmt.Println("─────────────────────────────")
 
-	// Setup Kubernetes client
-	clientset, _ := setupKubeClient()
-
-	// Discover pods
-	var podsToCollect []discovery.PodInfo
-	var err error
-
-	if snapshotJSON != "" || snapshotFile != "" {
-		podsToCollect, err = discoverPodsFromSnapshot(ctx, clientset, discoverVerbose)
-	} else if len(images) > 0 {
-		podsToCollect, err = discoverPodsFromImages(ctx, clientset, images, discoverVerbose)
-	} else if labelSelector != "" {
-		podsToCollect, err = discoverPodsFromLabelSelector(ctx, clientset, discoverVerbose)
-	} else if len(podNames) > 0 {
-		podsToCollect, err = discoverPodsFromNames(ctx, clientset, discoverVerbose)
-	}
-
+	// Setup Kubernetes clients and discover pods
+	clusters := setupKubeClusters(ctx)
+	podsToCollect, err := discoverPodsInClusters(ctx, clusters, discoverVerbose)
 	if err != nil {
 		exitWithError("Pod discovery failed: %v", err)
 	}
@@ -116,7 +136,7 @@ func runDiscover(cmd *cobra.Command, args []string) {
 	for component, pods := range componentPods {
 		fmt.Printf("Component: %s\n", component)
 		for _, pod := range pods {
-			fmt.Printf("   • Pod: %s/%s\n", pod.Namespace, pod.Name)
+			fmt.Printf("   • Pod: %s%s/%s\n", clusterPrefix(pod.Cluster), pod.Namespace, pod.Name)
 			fmt.Printf("     Container: %s\n", pod.ContainerName)
 			if discoverVerbose {
 				fmt.Printf("     Image: %s\n", pod.Image)
@@ -132,10 +152,16 @@ func runDiscover(cmd *cobra.Command, args []string) {
 		fmt.Printf("   coverport collect --snapshot='%s'\n", truncateForDisplay(snapshotJSON, 50))
 	} else if snapshotFile != "" {
 		fmt.Printf("   coverport collect --snapshot-file=%s\n", snapshotFile)
+	} else if snapshotName != "" {
+		fmt.Printf("   coverport collect --snapshot-name=%s\n", snapshotName)
 	} else if len(images) > 0 {
 		fmt.Printf("   coverport collect --images=%s\n", images[0])
 	} else if labelSelector != "" {
 		fmt.Printf("   coverport collect --namespace=%s --label-selector=%s\n", namespace, labelSelector)
+	} else if len(workloads) > 0 {
+		fmt.Printf("   coverport collect --namespace=%s --workload=%s\n", namespace, strings.Join(workloads, ","))
+	} else if annotated {
+		fmt.Printf("   coverport collect --annotated\n")
 	}
 }
 
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/coverport/cli/internal/rbac"
+	coverageclient "github.com/konflux-ci/coverport/cli/pkg/client"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment for coverage collection",
+	Long: `Check that coverage can be collected in the current environment without
+collecting it.
+
+With --rbac, the Kubernetes permissions the discovery and collection options need
+are checked with SelfSubjectAccessReviews and printed as a matrix. 'coverport
+collect' runs the same checks before it starts (unless --skip-preflight is set).
+Without options, all checks run.`,
+	Example: `  # Check permissions for cluster-wide discovery and collection
+  coverport doctor --rbac
+
+  # Check permissions for collection from a namespace through the API server proxy
+  coverport doctor --rbac --namespace=team-a --transport=proxy
+
+  # Check permissions in several clusters
+  coverport doctor --rbac --namespace=team-a --context=hub,spoke-1`,
+	Run: runDoctor,
+}
+
+var (
+	doctorRBAC bool
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().BoolVar(&doctorRBAC, "rbac", false, "Check the Kubernetes permissions needed for discovery and collection")
+
+	// Reuse the collect flags that determine the permissions needed
+	doctorCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (empty = all namespaces)")
+	doctorCmd.Flags().StringVar(&snapshotName, "snapshot-name", "", "Check reading the Konflux Snapshot resource <namespace>/<name>")
+	doctorCmd.Flags().BoolVar(&resolveComps, "resolve-components", false, "Check reading Konflux Component resources")
+	doctorCmd.Flags().StringVarP(&labelSelector, "label-selector", "l", "", "Check discovery by label selector")
+	doctorCmd.Flags().StringSliceVar(&podNames, "pods", nil, "Check discovery by pod names (requires --namespace)")
+	doctorCmd.Flags().StringSliceVar(&workloads, "workload", nil, "Check discovery of workloads, e.g. deployment/foo (requires --namespace)")
+	doctorCmd.Flags().StringSliceVar(&includeNamespaces, "include-namespaces", nil, "Namespace globs to check when --namespace is not set (default: all)")
+	doctorCmd.Flags().StringSliceVar(&excludeNamespaces, "exclude-namespaces", nil, "Namespace globs to skip when --namespace is not set (default: system namespaces)")
+	doctorCmd.Flags().StringVar(&namespaceSelector, "namespace-selector", "", "Check discovery in namespaces with matching labels")
+	doctorCmd.Flags().StringVar(&transport, "transport", coverageclient.TransportAuto, "Transport to check: auto, direct, port-forward or proxy")
+	doctorCmd.Flags().StringVar(&socketPath, "socket", "", "Check collection via exec from the coverage server's Unix socket")
+	doctorCmd.Flags().StringVar(&coverageTokenSecret, "coverage-token-secret", "", "Check reading the coverage token Secret")
+	doctorCmd.Flags().StringSliceVar(&kubeContexts, "context", nil, "Comma-separated list of kubeconfig contexts to check")
+	doctorCmd.Flags().StringSliceVar(&kubeconfigs, "kubeconfig", nil, "Comma-separated list of kubeconfig files to check")
+	doctorCmd.Flags().StringSliceVar(&kubeconfigSecrets, "kubeconfig-secret", nil, "Comma-separated list of Secrets holding kubeconfigs (<namespace>/<name>[/<key>], default key: kubeconfig)")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// --rbac is the only check so far, so it also runs without options
+	doctorRBAC = true
+
+	if len(podNames) > 0 && namespace == "" {
+		exitWithError("--pods requires --namespace")
+	}
+	if len(workloads) > 0 && namespace == "" {
+		exitWithError("--workload requires --namespace")
+	}
+	if err := namespaceScope().Validate(); err != nil {
+		exitWithError("%v", err)
+	}
+	switch transport {
+	case coverageclient.TransportAuto, coverageclient.TransportDirect, coverageclient.TransportPortForward, coverageclient.TransportProxy:
+	default:
+		exitWithError("Invalid --transport %q (expected auto, direct, port-forward or proxy)", transport)
+	}
+
+	fmt.Println("coverport - Doctor")
+	fmt.Println("─────────────────────────────")
+
+	if doctorRBAC {
+		missing := missingPermissions(ctx, setupKubeClusters(ctx), func(cluster kubeCluster, hub bool) []rbac.Requirement {
+			return append(discoveryRequirements(ctx, cluster, hub), collectionRequirements(namespace)...)
+		})
+		if len(missing) > 0 {
+			reportMissing(missing)
+			exitWithError("%d required permission(s) missing", len(missing))
+		}
+		fmt.Println()
+		printSuccess("All required permissions are granted")
+	}
+}
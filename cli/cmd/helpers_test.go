@@ -1,8 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/konflux-ci/coverport/cli/internal/discovery"
+	"github.com/konflux-ci/coverport/cli/internal/manifest"
+	"github.com/konflux-ci/coverport/cli/internal/rbac"
+	coverageclient "github.com/konflux-ci/coverport/cli/pkg/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestTruncateImage(t *testing.T) {
@@ -339,3 +354,508 @@ func TestCopyCoverageToRepo(t *testing.T) {
 		t.Error("file content mismatch")
 	}
 }
+
+func TestParseFileLine(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantFile string
+		wantLine int
+	}{
+		{"pkg/users/users.go:42", "pkg/users/users.go", 42},
+		{"pkg/users/users.go", "pkg/users/users.go", 0},
+		{"C:/src/app.js", "C:/src/app.js", 0},
+		{"main.go:0", "main.go:0", 0},
+	}
+
+	for _, tt := range tests {
+		file, line := parseFileLine(tt.input)
+		if file != tt.wantFile || line != tt.wantLine {
+			t.Errorf("parseFileLine(%q) = (%q, %d), want (%q, %d)", tt.input, file, line, tt.wantFile, tt.wantLine)
+		}
+	}
+}
+
+func TestMatchesAnyPattern(t *testing.T) {
+	patterns := []string{"*.md", "docs/*", ".github/*"}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"README.md", true},
+		{"cli/README.md", true},
+		{"docs/guide/setup.txt", true},
+		{".github/workflows/ci.yaml", true},
+		{"cli/cmd/collect.go", false},
+		{"pkg/docs.go", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesAnyPattern(tt.path, patterns); got != tt.expected {
+			t.Errorf("matchesAnyPattern(%q) = %v, want %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestCopyMetaFiles(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(srcDir, "covmeta.abc"), []byte("meta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "covcounters.abc.1.2"), []byte("counters"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyMetaFiles(srcDir, dstDir); err != nil {
+		t.Fatalf("copyMetaFiles failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dstDir, "covmeta.abc"))
+	if err != nil || string(data) != "meta" {
+		t.Errorf("Expected metadata file to be copied, got %q (err: %v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "covcounters.abc.1.2")); !os.IsNotExist(err) {
+		t.Error("Expected counters file not to be copied")
+	}
+}
+
+func TestSanitizePathElement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"api-server", "api-server"},
+		{"app_v1.2", "app_v1.2"},
+		{"../../etc", "_.._etc"},
+		{"a/b", "a_b"},
+		{"..", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if result := sanitizePathElement(tt.input); result != tt.expected {
+			t.Errorf("sanitizePathElement(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestTrimPid(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"app-7d9f8-xk2p4-42", "app-7d9f8-xk2p4"},
+		{"app-7d9f8-xk2p4", "app-7d9f8-xk2p4"},
+		{"host", "host"},
+	}
+
+	for _, tt := range tests {
+		if result := trimPid(tt.input); result != tt.expected {
+			t.Errorf("trimPid(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestPushCollectorHandlePush(t *testing.T) {
+	tmpDir := t.TempDir()
+	collector, err := newPushCollector(tmpDir, "pushed")
+	if err != nil {
+		t.Fatalf("newPushCollector failed: %v", err)
+	}
+
+	body := `{"meta_filename":"covmeta.abc","meta_data":"bWV0YQ==","counters_filename":"covcounters.abc.1.1","counters_data":"Y291bnRlcnM=","timestamp":1}`
+	push := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/push", strings.NewReader(body))
+		req.Header.Set("X-Art-Coverage-Component", "../api")
+		req.Header.Set("X-Art-Coverage-Pod", "api-xk2p4")
+		req.Header.Set("X-Art-Coverage-Pid", "7")
+		req.Header.Set("X-Art-Coverage-Namespace", "e2e")
+		rec := httptest.NewRecorder()
+		collector.handlePush(rec, req)
+		return rec
+	}
+
+	// A preStop push followed by a SIGTERM push of the same process is recorded once
+	for i := 0; i < 2; i++ {
+		if rec := push(); rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "_api", "api-xk2p4-7", "covcounters.abc.1.1")); err != nil {
+		t.Errorf("Expected counters file to be saved: %v", err)
+	}
+
+	loaded, err := manifest.Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	if len(loaded.Components) != 1 {
+		t.Fatalf("Expected 1 component, got %d", len(loaded.Components))
+	}
+	component := loaded.Components[0]
+	if component.Name != "_api" || component.PodName != "api-xk2p4" || component.Namespace != "e2e" {
+		t.Errorf("Unexpected component: %+v", component)
+	}
+
+	// The announced format is used rather than detected: Istanbul data that is
+	// not an object would be taken for a Python coverage database
+	req := httptest.NewRequest(http.MethodPost, "/push", strings.NewReader(`{"coverage_data":"W10="}`))
+	req.Header.Set("X-Art-Coverage-Component", "ui")
+	req.Header.Set("X-Art-Coverage-Format", "nodejs")
+	rec := httptest.NewRecorder()
+	collector.handlePush(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for Node.js push, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if loaded, _ := manifest.Load(tmpDir); len(loaded.Components) != 2 || loaded.Components[1].Format != string(coverageclient.FormatNYC) {
+		t.Errorf("Expected the Node.js push to be recorded as %s: %+v", coverageclient.FormatNYC, loaded.Components)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/push", nil)
+	rec = httptest.NewRecorder()
+	collector.handlePush(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", rec.Code)
+	}
+}
+
+func TestIncludePushedCoverage(t *testing.T) {
+	t.Run("dump directory", func(t *testing.T) {
+		dumpDir := t.TempDir()
+		outDir := t.TempDir()
+		dump := filepath.Join(dumpDir, "api", "api-xk2p4-7")
+		if err := os.MkdirAll(dump, 0755); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(dump, "covmeta.abc"), []byte("meta"), 0644)
+		os.MkdirAll(filepath.Join(dumpDir, "api", "empty"), 0755)
+
+		pushed, err := includePushedCoverage(dumpDir, outDir)
+		if err != nil {
+			t.Fatalf("includePushedCoverage failed: %v", err)
+		}
+		if len(pushed) != 1 {
+			t.Fatalf("Expected 1 pushed component, got %d", len(pushed))
+		}
+		if pushed[0].Name != "api" || pushed[0].PodName != "api-xk2p4" {
+			t.Errorf("Unexpected component: %+v", pushed[0])
+		}
+		if _, err := os.Stat(filepath.Join(outDir, pushed[0].CoverageDir, "covmeta.abc")); err != nil {
+			t.Errorf("Expected coverage to be copied: %v", err)
+		}
+	})
+
+	t.Run("dump directory with coverage JSON", func(t *testing.T) {
+		dumpDir := t.TempDir()
+		outDir := t.TempDir()
+		dump := filepath.Join(dumpDir, "web", "web-7d9f-12")
+		if err := os.MkdirAll(dump, 0755); err != nil {
+			t.Fatal(err)
+		}
+		istanbul := base64.StdEncoding.EncodeToString([]byte(`{"/app/index.js":{"path":"/app/index.js"}}`))
+		body := fmt.Sprintf(`{"label":"push","format":"istanbul","protocol_version":1,"coverage_data":%q}`, istanbul)
+		os.WriteFile(filepath.Join(dump, "coverage.json"), []byte(body), 0644)
+
+		pushed, err := includePushedCoverage(dumpDir, outDir)
+		if err != nil {
+			t.Fatalf("includePushedCoverage failed: %v", err)
+		}
+		if len(pushed) != 1 {
+			t.Fatalf("Expected 1 pushed component, got %d", len(pushed))
+		}
+		if pushed[0].Name != "web" || pushed[0].PodName != "web-7d9f" || pushed[0].Format != "nyc" {
+			t.Errorf("Unexpected component: %+v", pushed[0])
+		}
+		if _, err := os.Stat(filepath.Join(outDir, pushed[0].CoverageDir, "coverage-final.json")); err != nil {
+			t.Errorf("Expected coverage to be saved: %v", err)
+		}
+	})
+
+	t.Run("agent output", func(t *testing.T) {
+		agentDir := t.TempDir()
+		outDir := t.TempDir()
+		os.MkdirAll(filepath.Join(agentDir, "api", "api-xk2p4-7"), 0755)
+		os.WriteFile(filepath.Join(agentDir, "api", "api-xk2p4-7", "covcounters.abc"), []byte("counters"), 0644)
+
+		agentManifest := manifest.NewCollectionManifest("pushed", manifest.CollectionParameters{})
+		agentManifest.AddComponent(manifest.ComponentInfo{Name: "api", CoverageDir: filepath.Join("api", "api-xk2p4-7"), Namespace: "e2e"})
+		if err := agentManifest.Save(agentDir); err != nil {
+			t.Fatal(err)
+		}
+
+		pushed, err := includePushedCoverage(agentDir, outDir)
+		if err != nil {
+			t.Fatalf("includePushedCoverage failed: %v", err)
+		}
+		if len(pushed) != 1 || pushed[0].Namespace != "e2e" {
+			t.Fatalf("Unexpected pushed components: %+v", pushed)
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "api", "api-xk2p4-7", "covcounters.abc")); err != nil {
+			t.Errorf("Expected coverage to be copied: %v", err)
+		}
+	})
+}
+
+func TestCommonFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		formats  []string
+		expected string
+	}{
+		{"single component", []string{"nyc"}, "nyc"},
+		{"all go", []string{"go", "go"}, "go"},
+		{"mixed", []string{"go", "nyc"}, ""},
+		{"unknown format", []string{"go", ""}, ""},
+		{"no components", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var components []manifest.ComponentInfo
+			for _, format := range tt.formats {
+				components = append(components, manifest.ComponentInfo{Format: format})
+			}
+			if result := commonFormat(components); result != tt.expected {
+				t.Errorf("commonFormat(%v) = %q, want %q", tt.formats, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestComponentFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		detected   coverageclient.CoverageFormat
+		expected   string
+	}{
+		{"no annotation", "", coverageclient.FormatGo, "go"},
+		{"matching annotation", "go", coverageclient.FormatGo, "go"},
+		{"annotation alias", "istanbul", coverageclient.FormatNYC, "nyc"},
+		{"mismatching annotation", "python", coverageclient.FormatGo, "go"},
+		{"unknown annotation", "golang", coverageclient.FormatGo, "go"},
+		{"annotation without detection", "nodejs", "", "nyc"},
+		{"unknown annotation without detection", "golang", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podInfo := discovery.PodInfo{Name: "api-xk2p4", Format: tt.annotation}
+			if result := componentFormat(podInfo, tt.detected); result != tt.expected {
+				t.Errorf("componentFormat(%q, %q) = %q, want %q", tt.annotation, tt.detected, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseKubeconfigSecret(t *testing.T) {
+	tests := []struct {
+		ref                              string
+		wantNamespace, wantName, wantKey string
+		wantErr                          bool
+	}{
+		{ref: "clusters/spoke-1", wantNamespace: "clusters", wantName: "spoke-1", wantKey: "kubeconfig"},
+		{ref: "clusters/spoke-1/value", wantNamespace: "clusters", wantName: "spoke-1", wantKey: "value"},
+		{ref: "spoke-1", wantErr: true},
+		{ref: "/spoke-1", wantErr: true},
+		{ref: "a/b/c/d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		namespace, name, key, err := parseKubeconfigSecret(tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseKubeconfigSecret(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			continue
+		}
+		if namespace != tt.wantNamespace || name != tt.wantName || key != tt.wantKey {
+			t.Errorf("parseKubeconfigSecret(%q) = %q, %q, %q", tt.ref, namespace, name, key)
+		}
+	}
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: hub
+clusters:
+- name: hub
+  cluster:
+    server: https://hub.example.com:6443
+- name: spoke
+  cluster:
+    server: https://spoke.example.com:6443
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: hub
+  context: {cluster: hub, user: admin}
+- name: spoke
+  context: {cluster: spoke, user: admin}
+`
+
+func TestLoadKubeClusters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", path)
+
+	defer func() { kubeContexts, kubeconfigs, kubeconfigSecrets = nil, nil, nil }()
+
+	t.Run("contexts", func(t *testing.T) {
+		kubeContexts, kubeconfigs = []string{"hub", "spoke"}, nil
+		clusters, err := loadKubeClusters(context.Background())
+		if err != nil {
+			t.Fatalf("loadKubeClusters failed: %v", err)
+		}
+		if len(clusters) != 2 {
+			t.Fatalf("got %d clusters, want 2", len(clusters))
+		}
+		if clusters[1].name != "spoke" || clusters[1].restConfig.Host != "https://spoke.example.com:6443" {
+			t.Errorf("unexpected cluster %s at %s", clusters[1].name, clusters[1].restConfig.Host)
+		}
+	})
+
+	t.Run("kubeconfig file uses its current context", func(t *testing.T) {
+		kubeContexts, kubeconfigs = nil, []string{path}
+		clusters, err := loadKubeClusters(context.Background())
+		if err != nil {
+			t.Fatalf("loadKubeClusters failed: %v", err)
+		}
+		if len(clusters) != 1 || clusters[0].name != "hub" || clusters[0].restConfig.Host != "https://hub.example.com:6443" {
+			t.Errorf("unexpected clusters %+v", clusters)
+		}
+	})
+
+	t.Run("duplicate cluster", func(t *testing.T) {
+		kubeContexts, kubeconfigs = []string{"hub"}, []string{path}
+		if _, err := loadKubeClusters(context.Background()); err == nil {
+			t.Error("expected error for cluster given twice")
+		}
+	})
+
+	t.Run("kubeconfig files with the same context name", func(t *testing.T) {
+		// Installer kubeconfigs of different clusters share the "admin" context
+		admin := func(server string) string {
+			path := filepath.Join(t.TempDir(), "kubeconfig")
+			config := strings.NewReplacer("current-context: hub", "current-context: admin", "- name: hub\n  context:", "- name: admin\n  context:",
+				"https://hub.example.com:6443", server).Replace(testKubeconfig)
+			if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		}
+		hubPath, spokePath := admin("https://hub.example.com:6443"), admin("https://spoke-1.example.com:6443")
+
+		kubeContexts, kubeconfigs = nil, []string{hubPath, spokePath}
+		clusters, err := loadKubeClusters(context.Background())
+		if err != nil {
+			t.Fatalf("loadKubeClusters failed: %v", err)
+		}
+		if len(clusters) != 2 || clusters[0].name != "admin" || clusters[1].name != spokePath {
+			t.Errorf("unexpected cluster names %s and %s", clusters[0].name, clusters[len(clusters)-1].name)
+		}
+
+		kubeconfigs = []string{hubPath, hubPath}
+		if _, err := loadKubeClusters(context.Background()); err == nil {
+			t.Error("expected error for kubeconfig given twice")
+		}
+	})
+
+	t.Run("unknown context", func(t *testing.T) {
+		kubeContexts, kubeconfigs = []string{"missing"}, nil
+		if _, err := loadKubeClusters(context.Background()); err == nil {
+			t.Error("expected error for unknown context")
+		}
+	})
+}
+
+func TestCollectionRequirements(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("COVERAGE_TOKEN", "")
+	defer func(s, tr, tok string) { socketPath, transport, coverageTokenSecret = s, tr, tok }(socketPath, transport, coverageTokenSecret)
+
+	describe := func(reqs []rbac.Requirement) string {
+		var parts []string
+		for _, req := range reqs {
+			var perms []string
+			for _, perm := range req.AnyOf {
+				perms = append(perms, perm.Verb+" "+perm.ResourceName())
+			}
+			part := strings.Join(perms, "|")
+			if !req.Required {
+				part += "?"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	tests := []struct {
+		name        string
+		socket      string
+		transport   string
+		tokenSecret string
+		expected    string
+	}{
+		{"auto outside the cluster", "", "auto", "", "get pods,create pods/portforward|get pods/proxy,create pods/exec?"},
+		{"direct makes API server transports optional", "", "direct", "", "get pods,create pods/portforward|get pods/proxy?,create pods/exec?"},
+		{"port-forward", "", "port-forward", "", "get pods,create pods/portforward,create pods/exec?"},
+		{"proxy", "", "proxy", "", "get pods,get pods/proxy,create pods/exec?"},
+		{"socket requires exec", "/tmp/coverage.sock", "auto", "", "get pods,create pods/exec"},
+		{"token secret", "", "port-forward", "coverage-token", "get pods,create pods/portforward,create pods/exec?,get secrets"},
+		{"token leaves out the proxy fallback", "", "auto", "coverage-token", "get pods,create pods/portforward,create pods/exec?,get secrets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socketPath, transport, coverageTokenSecret = tt.socket, tt.transport, tt.tokenSecret
+			reqs := collectionRequirements("team-a")
+			if result := describe(reqs); result != tt.expected {
+				t.Errorf("collectionRequirements() = %s, want %s", result, tt.expected)
+			}
+			for _, req := range reqs {
+				for _, perm := range req.AnyOf {
+					if perm.Namespace != "team-a" {
+						t.Errorf("%s: got namespace %q", perm, perm.Namespace)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoveryRequirements_AllNamespaces(t *testing.T) {
+	defer func(ns, selector, nsSelector, snap string, pods, wls, include, exclude []string) {
+		namespace, labelSelector, namespaceSelector, snapshotName = ns, selector, nsSelector, snap
+		podNames, workloads, includeNamespaces, excludeNamespaces = pods, wls, include, exclude
+	}(namespace, labelSelector, namespaceSelector, snapshotName, podNames, workloads, includeNamespaces, excludeNamespaces)
+	namespace, labelSelector, namespaceSelector, snapshotName = "", "", "", ""
+	podNames, workloads, includeNamespaces, excludeNamespaces = nil, nil, nil, nil
+
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	)
+	reqs := discoveryRequirements(context.Background(), kubeCluster{clientset: clientset}, true)
+	if len(reqs) != 1 {
+		t.Fatalf("got %d requirements, want 1", len(reqs))
+	}
+
+	var allOf []string
+	for _, perm := range reqs[0].AllOf {
+		allOf = append(allOf, perm.String())
+	}
+	expected := []string{"list namespaces in all namespaces", "list pods in team-a", "list pods in team-b"}
+	if strings.Join(allOf, ",") != strings.Join(expected, ",") {
+		t.Errorf("AllOf = %v, want %v", allOf, expected)
+	}
+	if len(reqs[0].AnyOf) != 1 || reqs[0].AnyOf[0].String() != "list pods in all namespaces" {
+		t.Errorf("AnyOf = %v, want list pods in all namespaces", reqs[0].AnyOf)
+	}
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/konflux-ci/coverport/cli/internal/discovery"
+	"github.com/konflux-ci/coverport/cli/internal/rbac"
+	"github.com/konflux-ci/coverport/cli/internal/snapshot"
+	coverageclient "github.com/konflux-ci/coverport/cli/pkg/client"
+)
+
+// workloadResources maps workload kinds to their API group and resource
+var workloadResources = map[string][2]string{
+	"deployment":  {"apps", "deployments"},
+	"statefulset": {"apps", "statefulsets"},
+	"daemonset":   {"apps", "daemonsets"},
+	"job":         {"batch", "jobs"},
+}
+
+// discoveryRequirements returns the permissions the selected discovery method
+// needs in the cluster. Konflux resources are only read from the hub (first)
+// cluster.
+func discoveryRequirements(ctx context.Context, cluster kubeCluster, hub bool) []rbac.Requirement {
+	var reqs []rbac.Requirement
+
+	if hub && snapshotName != "" {
+		if snapshotNamespace, _, err := snapshot.ParseSnapshotName(snapshotName, namespace); err == nil {
+			reqs = append(reqs, rbac.Requirement{
+				Purpose:  "read the Konflux Snapshot",
+				Required: true,
+				AnyOf:    []rbac.Permission{{Verb: "get", Group: "appstudio.redhat.com", Resource: "snapshots", Namespace: snapshotNamespace}},
+			})
+			if resolveComps {
+				reqs = append(reqs, rbac.Requirement{
+					Purpose: "resolve component sources",
+					AnyOf:   []rbac.Permission{{Verb: "get", Group: "appstudio.redhat.com", Resource: "components", Namespace: snapshotNamespace}},
+				})
+			}
+		}
+	}
+
+	switch {
+	case len(podNames) > 0:
+		reqs = append(reqs, rbac.Requirement{
+			Purpose:  "get the pods",
+			Required: true,
+			AnyOf:    []rbac.Permission{{Verb: "get", Resource: "pods", Namespace: namespace}},
+		})
+	case len(workloads) > 0:
+		for _, ref := range workloads {
+			workload, err := discovery.ParseWorkload(ref)
+			if err != nil {
+				continue
+			}
+			resource := workloadResources[workload.Kind]
+			reqs = append(reqs, rbac.Requirement{
+				Purpose:  "read " + workload.String(),
+				Required: true,
+				AnyOf:    []rbac.Permission{{Verb: "get", Group: resource[0], Resource: resource[1], Namespace: namespace}},
+			})
+			if workload.Kind == "deployment" {
+				reqs = append(reqs, rbac.Requirement{
+					Purpose:  "find the ReplicaSets of " + workload.String(),
+					Required: true,
+					AnyOf:    []rbac.Permission{{Verb: "list", Group: "apps", Resource: "replicasets", Namespace: namespace}},
+				})
+			}
+		}
+		reqs = append(reqs, listPodsRequirement(namespace))
+	case namespace != "" || labelSelector != "":
+		reqs = append(reqs, listPodsRequirement(namespace))
+	default:
+		// Cluster-wide pods, or else the pods of each namespace in the scope
+		if namespaceSelector != "" {
+			reqs = append(reqs, rbac.Requirement{
+				Purpose:  "select namespaces by label",
+				Required: true,
+				AnyOf:    []rbac.Permission{{Verb: "list", Resource: "namespaces"}},
+			})
+		}
+		reqs = append(reqs, rbac.Requirement{
+			Purpose:  "discover pods in all namespaces",
+			Required: true,
+			AnyOf:    []rbac.Permission{{Verb: "list", Resource: "pods"}},
+			AllOf:    namespaceFallbackPermissions(ctx, cluster),
+		})
+	}
+	return reqs
+}
+
+// namespaceFallbackPermissions returns the permissions discovery needs to list
+// the pods of each namespace in the scope when it cannot list pods in all
+// namespaces: list namespaces, and list pods in each namespace. Without access
+// to namespaces, only that permission is returned.
+func namespaceFallbackPermissions(ctx context.Context, cluster kubeCluster) []rbac.Permission {
+	perms := []rbac.Permission{{Verb: "list", Resource: "namespaces"}}
+	namespaces, err := newImageDiscovery(cluster.clientset).SearchNamespaces(ctx)
+	if err != nil {
+		return perms
+	}
+	for _, ns := range namespaces {
+		perms = append(perms, rbac.Permission{Verb: "list", Resource: "pods", Namespace: ns})
+	}
+	return perms
+}
+
+func listPodsRequirement(namespace string) rbac.Requirement {
+	return rbac.Requirement{
+		Purpose:  "list pods",
+		Required: true,
+		AnyOf:    []rbac.Permission{{Verb: "list", Resource: "pods", Namespace: namespace}},
+	}
+}
+
+// collectionRequirements returns the permissions collection from pods in the
+// namespace (all namespaces when empty) needs with the selected transport
+func collectionRequirements(namespace string) []rbac.Requirement {
+	pod := func(verb, subresource string) rbac.Permission {
+		return rbac.Permission{Verb: verb, Resource: "pods", Subresource: subresource, Namespace: namespace}
+	}
+
+	reqs := []rbac.Requirement{{
+		Purpose:  "read pod metadata",
+		Required: true,
+		AnyOf:    []rbac.Permission{pod("get", "")},
+	}}
+
+	if socketPath != "" {
+		reqs = append(reqs, rbac.Requirement{
+			Purpose:  "collect via the coverage socket",
+			Required: true,
+			AnyOf:    []rbac.Permission{pod("create", "exec")},
+		})
+	} else {
+		switch transport {
+		case coverageclient.TransportPortForward:
+			reqs = append(reqs, rbac.Requirement{
+				Purpose:  "port-forward to coverage servers",
+				Required: true,
+				AnyOf:    []rbac.Permission{pod("create", "portforward")},
+			})
+		case coverageclient.TransportProxy:
+			reqs = append(reqs, rbac.Requirement{
+				Purpose:  "reach coverage servers through the API server proxy",
+				Required: true,
+				AnyOf:    []rbac.Permission{pod("get", "proxy")},
+			})
+		default:
+			// Pod IPs need no permissions; the API server transports are fallbacks
+			direct := transport == coverageclient.TransportDirect || coverageclient.InCluster()
+			purpose := "reach coverage servers"
+			if direct {
+				purpose = "reach coverage servers when pod IPs are unreachable"
+			}
+			fallbacks := []rbac.Permission{pod("create", "portforward")}
+			// The proxy transport cannot send the coverage token
+			if coverageToken == "" && coverageTokenSecret == "" && os.Getenv(coverageclient.TokenEnvVar) == "" {
+				fallbacks = append(fallbacks, pod("get", "proxy"))
+			}
+			reqs = append(reqs, rbac.Requirement{
+				Purpose:  purpose,
+				Required: !direct,
+				AnyOf:    fallbacks,
+			})
+		}
+		reqs = append(reqs, rbac.Requirement{
+			Purpose: "detect ports and save Python coverage via exec",
+			AnyOf:   []rbac.Permission{pod("create", "exec")},
+		})
+	}
+
+	if coverageTokenSecret != "" {
+		reqs = append(reqs, rbac.Requirement{
+			Purpose:  "read the coverage token secret",
+			Required: true,
+			AnyOf:    []rbac.Permission{{Verb: "get", Resource: "secrets", Namespace: namespace}},
+		})
+	}
+	return reqs
+}
+
+// podNamespaces returns the namespaces of the pods in the cluster
+func podNamespaces(pods []discovery.PodInfo, cluster string) []string {
+	seen := make(map[string]bool)
+	var namespaces []string
+	for _, pod := range pods {
+		if pod.Cluster == cluster && !seen[pod.Namespace] {
+			seen[pod.Namespace] = true
+			namespaces = append(namespaces, pod.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// checkPermissions reviews the requirements in the cluster and prints the
+// permission matrix. It returns the required steps that are not allowed.
+// When the review itself fails, a warning is printed and no step is reported.
+func checkPermissions(ctx context.Context, cluster kubeCluster, reqs []rbac.Requirement) []rbac.Result {
+	results, err := rbac.Check(ctx, cluster.clientset, reqs)
+	if err != nil {
+		printWarning("Skipping permission checks%s: %v", clusterSuffix(cluster.name), err)
+		return nil
+	}
+
+	fmt.Printf("\nPermissions%s:\n", clusterSuffix(cluster.name))
+	rbac.PrintMatrix(os.Stdout, results)
+	return rbac.Missing(results)
+}
+
+// preflight checks the permissions in each cluster and exits when a required
+// one is missing
+func preflight(ctx context.Context, clusters []kubeCluster, requirements func(cluster kubeCluster, hub bool) []rbac.Requirement) {
+	if missing := missingPermissions(ctx, clusters, requirements); len(missing) > 0 {
+		reportMissing(missing)
+		exitWithError("Missing required permissions (use --skip-preflight to try anyway)")
+	}
+}
+
+// missingPermissions checks the requirements in each cluster and describes the
+// required steps that are not allowed
+func missingPermissions(ctx context.Context, clusters []kubeCluster, requirements func(cluster kubeCluster, hub bool) []rbac.Requirement) []string {
+	var missing []string
+	for i, cluster := range clusters {
+		reqs := requirements(cluster, i == 0)
+		if len(reqs) == 0 {
+			continue
+		}
+		for _, result := range checkPermissions(ctx, cluster, reqs) {
+			missing = append(missing, clusterPrefix(cluster.name)+rbac.Describe(result))
+		}
+	}
+	return missing
+}
+
+// reportMissing prints the missing permissions
+func reportMissing(missing []string) {
+	fmt.Println()
+	for _, m := range missing {
+		fmt.Fprintf(os.Stderr, "Missing permission: %s\n", m)
+	}
+}
+
+// clusterSuffix returns " in cluster <name>" for display, or "" for the default cluster
+func clusterSuffix(name string) string {
+	if name == "" {
+		return ""
+	}
+	return " in cluster " + name
+}
@@ -207,6 +207,13 @@ func processComponent(ctx context.Context, component manifest.ComponentInfo, cov
 			CommitSHA: overrideCommitSHA,
 		}
 		printInfo("Using provided git metadata: %s @ %s", overrideRepoURL, overrideCommitSHA[:8])
+	} else if component.RepoURL != "" && component.CommitSHA != "" {
+		// Recorded at collection (pod annotations or Konflux Component resources)
+		gitMeta = &metadata.GitMetadata{
+			RepoURL:   component.RepoURL,
+			CommitSHA: component.CommitSHA,
+		}
+		printInfo("Using git metadata recorded at collection: %s @ %s", component.RepoURL, component.CommitSHA)
 	} else if isHTTPURL(component.Image) {
 		// Image is a URL (from --url collection), not a container image
 		return fmt.Errorf("image is a URL (%s), not a container image. Please provide --repo-url and --commit-sha", component.Image)
@@ -226,9 +233,13 @@ func processComponent(ctx context.Context, component manifest.ComponentInfo, cov
 		}
 	}
 
-	// Process coverage
+	// Process coverage, using the format recorded at collection unless --format is set
+	format := coverageFormat
+	if format == "auto" && component.Format != "" {
+		format = component.Format
+	}
 	coverageFile := filepath.Join(workspace, "coverage.out")
-	if err := processCoverage(ctx, coverageDir, coverageFile, repoDir, verbose); err != nil {
+	if err := processCoverage(ctx, coverageDir, coverageFile, repoDir, format, verbose); err != nil {
 		return fmt.Errorf("process coverage: %w", err)
 	}
 
@@ -357,7 +368,7 @@ func runProcess(cmd *cobra.Command, args []string) {
 
 	// Step 4: Process coverage
 	coverageFile := filepath.Join(workspace, "coverage.out")
-	if err := processCoverage(ctx, rawCoverageDir, coverageFile, repoDir, verbose); err != nil {
+	if err := processCoverage(ctx, rawCoverageDir, coverageFile, repoDir, coverageFormat, verbose); err != nil {
 		exitWithError("Failed to process coverage: %v", err)
 	}
 
@@ -487,10 +498,10 @@ func cloneRepository(ctx context.Context, gitMeta *metadata.GitMetadata, targetD
 }
 
 // processCoverage processes the coverage data
-func processCoverage(ctx context.Context, inputDir, outputFile, repoRoot string, verbose bool) error {
+func processCoverage(ctx context.Context, inputDir, outputFile, repoRoot, formatName string, verbose bool) error {
 	// Detect or use specified format
 	var format processor.CoverageFormat
-	switch coverageFormat {
+	switch formatName {
 	case "go":
 		format = processor.FormatGo
 	case "python":
@@ -502,7 +513,7 @@ func processCoverage(ctx context.Context, inputDir, outputFile, repoRoot string,
 	case "auto":
 		format = processor.FormatAuto
 	default:
-		return fmt.Errorf("unsupported coverage format: %s", coverageFormat)
+		return fmt.Errorf("unsupported coverage format: %s", formatName)
 	}
 
 	proc := processor.NewCoverageProcessor(format)
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/coverport/cli/internal/git"
+	"github.com/konflux-ci/coverport/cli/internal/trace"
+)
+
+var selectTestsCmd = &cobra.Command{
+	Use:   "select-tests",
+	Short: "Select the e2e tests impacted by a change",
+	Long: `Select the e2e tests impacted by a change using a traceability index
+(see 'coverport trace') and a git diff.
+
+The repository is diffed between --base and --head, and the changed lines are
+matched against the index. The output is a minimal set of tests that covers
+every changed line executed by at least one test. When none of a file's changed
+lines were executed, the tests touching that file are used instead.
+
+Changed files without any coverage data in the index (new files, files of
+components that were not instrumented) are reported, and every test is selected
+since their impact is unknown. Use --ignore-uncovered to select only the tests
+found in the index instead.
+
+Only the selection is printed to stdout, so it can be passed to a test runner;
+progress and warnings go to stderr.
+
+The index should be built from coverage of the base commit, since changed lines
+are numbered in the base version of each file.`,
+	Example: `  # Select tests for a PR using an existing checkout
+  coverport select-tests \
+    --index=./trace-index.json \
+    --repo-dir=. \
+    --base=origin/main
+
+  # Clone the repository at the PR head and write the selection as JSON
+  coverport select-tests \
+    --index=./trace-index.json \
+    --repo-url=https://github.com/org/repo \
+    --base=abc123 --head=def456 \
+    --json --output=selected-tests.json`,
+	Run: runSelectTests,
+}
+
+var (
+	selectIndexFile       string
+	selectBase            string
+	selectHead            string
+	selectRepoDir         string
+	selectRepoURL         string
+	selectIgnore          []string
+	selectIgnoreUncovered bool
+	selectJSON            bool
+	selectOutput          string
+)
+
+func init() {
+	rootCmd.AddCommand(selectTestsCmd)
+
+	selectTestsCmd.Flags().StringVar(&selectIndexFile, "index", "", "Traceability index file (from 'coverport trace')")
+	selectTestsCmd.Flags().StringVar(&selectBase, "base", "", "Base commit to diff against")
+	selectTestsCmd.Flags().StringVar(&selectHead, "head", "HEAD", "Head commit to diff (empty compares against the working tree)")
+	selectTestsCmd.Flags().StringVar(&selectRepoDir, "repo-dir", "", "Existing repository checkout")
+	selectTestsCmd.Flags().StringVar(&selectRepoURL, "repo-url", "", "Repository URL to clone (alternative to --repo-dir)")
+	selectTestsCmd.Flags().StringVar(&workspaceDir, "workspace", "", "Workspace directory for cloning (default: temp directory)")
+	selectTestsCmd.Flags().StringSliceVar(&selectIgnore, "ignore", []string{"*.md", "docs/*", ".github/*"}, "Changed file patterns to ignore")
+	selectTestsCmd.Flags().BoolVar(&selectIgnoreUncovered, "ignore-uncovered", false, "Do not select all tests when a changed file has no coverage data")
+	selectTestsCmd.Flags().BoolVar(&selectJSON, "json", false, "Print the selection as JSON")
+	selectTestsCmd.Flags().StringVar(&selectOutput, "output", "", "Write the selection to a file instead of stdout")
+
+	selectTestsCmd.MarkFlagRequired("index")
+	selectTestsCmd.MarkFlagRequired("base")
+}
+
+func runSelectTests(cmd *cobra.Command, args []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if selectRepoDir == "" && selectRepoURL == "" {
+		exitWithError("Either --repo-dir or --repo-url is required")
+	}
+	if selectRepoDir != "" && selectRepoURL != "" {
+		exitWithError("Use only one of --repo-dir or --repo-url")
+	}
+
+	idx, err := trace.Load(selectIndexFile)
+	if err != nil {
+		exitWithError("Failed to load index: %v", err)
+	}
+
+	cloner, err := git.NewRepositoryCloner()
+	if err != nil {
+		exitWithError("Failed to initialize git: %v", err)
+	}
+	// Keep stdout for the selection, e.g. for 'select-tests --json | jq'
+	cloner.SetOutput(os.Stderr)
+
+	fileChanges, err := changedFiles(ctx, cloner)
+	if err != nil {
+		exitWithError("%v", err)
+	}
+
+	var changes []trace.Change
+	ignored := 0
+	for _, fc := range fileChanges {
+		if matchesAnyPattern(fc.Path, selectIgnore) {
+			ignored++
+			continue
+		}
+		file := fc.Path
+		if fc.OldPath != "" {
+			file = fc.OldPath
+		}
+		changes = append(changes, trace.Change{File: file, Lines: fc.Lines})
+	}
+
+	selection := idx.SelectTests(changes)
+	if !selectIgnoreUncovered && len(selection.UncoveredFiles) > 0 {
+		var all []string
+		for _, test := range idx.Tests {
+			all = append(all, test.Name)
+		}
+		selection.Tests = all
+	}
+
+	// Diagnostics go to stderr, keeping stdout for the selection
+	fmt.Fprintf(os.Stderr, "Changed files: %d (%d ignored)\n", len(fileChanges), ignored)
+	fmt.Fprintf(os.Stderr, "Impacted tests: %d of %d\n", len(selection.Impacted), len(idx.Tests))
+	for _, file := range selection.UncoveredFiles {
+		fmt.Fprintf(os.Stderr, "Warning: No coverage data for changed file: %s\n", file)
+	}
+	if !selectIgnoreUncovered && len(selection.UncoveredFiles) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: Selecting all tests because some changed files have no coverage data\n")
+	}
+
+	if err := writeSelection(selection); err != nil {
+		exitWithError("Failed to write selection: %v", err)
+	}
+}
+
+// changedFiles diffs the repository (--repo-dir, or --repo-url cloned into the
+// workspace) between --base and --head. A temporary workspace is removed before
+// returning, also on failure.
+func changedFiles(ctx context.Context, cloner *git.RepositoryCloner) ([]git.FileChange, error) {
+	repoDir := selectRepoDir
+	if selectRepoURL != "" {
+		workspace := workspaceDir
+		if workspace == "" {
+			var err error
+			workspace, err = os.MkdirTemp("", "coverport-select-*")
+			if err != nil {
+				return nil, fmt.Errorf("create workspace: %w", err)
+			}
+			defer os.RemoveAll(workspace)
+		}
+
+		repoDir = filepath.Join(workspace, "repo")
+		if err := cloner.Clone(ctx, git.CloneOptions{
+			RepoURL:   selectRepoURL,
+			CommitSHA: selectHead,
+			TargetDir: repoDir,
+		}); err != nil {
+			return nil, fmt.Errorf("clone repository: %w", err)
+		}
+	}
+
+	fileChanges, err := cloner.Diff(ctx, repoDir, selectBase, selectHead)
+	if err != nil {
+		return nil, fmt.Errorf("diff repository: %w", err)
+	}
+	return fileChanges, nil
+}
+
+// writeSelection prints the selection to stdout (or writes it to --output) as
+// JSON or as one test name per line
+func writeSelection(selection trace.Selection) error {
+	var content string
+	if selectJSON {
+		data, err := json.MarshalIndent(selection, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal selection: %w", err)
+		}
+		content = string(data) + "\n"
+	} else if len(selection.Tests) > 0 {
+		content = strings.Join(selection.Tests, "\n") + "\n"
+	}
+
+	if selectOutput == "" {
+		fmt.Fprintf(os.Stderr, "Selected %d test(s)\n", len(selection.Tests))
+		fmt.Print(content)
+		return nil
+	}
+
+	if err := os.WriteFile(selectOutput, []byte(content), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Selected %d test(s), written to %s\n", len(selection.Tests), selectOutput)
+	return nil
+}
+
+// matchesAnyPattern reports whether the path or its base name matches any of the glob patterns.
+// A pattern ending in "/*" matches everything under that directory.
+func matchesAnyPattern(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if dir, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+			return true
+		}
+	}
+	return false
+}
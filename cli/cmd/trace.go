@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/konflux-ci/coverport/cli/internal/manifest"
+	"github.com/konflux-ci/coverport/cli/internal/trace"
+)
+
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Build and query a test-to-code traceability index",
+	Long: `Build an index mapping each covered source line and function to the tests that
+executed it, using the per-test coverage recorded in a collection manifest
+(see the testhook package), and query it.
+
+Queries:
+  • --file=path:line  Which tests cover this line
+  • --file=path       Which tests cover any line of this file
+  • --test=name       Which files does this test touch
+
+File paths may be repository-relative; they are matched against the indexed
+paths by suffix. Go, NYC/Istanbul and LCOV per-test coverage is supported.`,
+	Example: `  # Build the index from per-test coverage (writes trace-index.json)
+  coverport trace --coverage-dir=./coverage-output
+
+  # Which tests cover a line
+  coverport trace --index=./coverage-output/trace-index.json --file=pkg/users/users.go:42
+
+  # Which files does a test touch, as JSON
+  coverport trace --index=./coverage-output/trace-index.json --test="Users creates a user" --json`,
+	Run: runTrace,
+}
+
+var (
+	traceCoverageDir string
+	traceOutput      string
+	traceIndexFile   string
+	traceFile        string
+	traceTest        string
+	traceJSON        bool
+)
+
+func init() {
+	rootCmd.AddCommand(traceCmd)
+
+	traceCmd.Flags().StringVar(&traceCoverageDir, "coverage-dir", "", "Directory containing per-test coverage and metadata.json")
+	traceCmd.Flags().StringVar(&traceOutput, "output", "", "Index output file (default: <coverage-dir>/trace-index.json)")
+	traceCmd.Flags().StringVar(&traceIndexFile, "index", "", "Use an existing index instead of building one")
+	traceCmd.Flags().StringVar(&traceFile, "file", "", "Query tests covering a file or file:line")
+	traceCmd.Flags().StringVar(&traceTest, "test", "", "Query files touched by a test")
+	traceCmd.Flags().BoolVar(&traceJSON, "json", false, "Print query results as JSON")
+}
+
+func runTrace(cmd *cobra.Command, args []string) {
+	if traceCoverageDir == "" && traceIndexFile == "" {
+		exitWithError("Either --coverage-dir or --index is required")
+	}
+	if traceFile != "" && traceTest != "" {
+		exitWithError("Use only one of --file or --test")
+	}
+
+	var idx *trace.Index
+	var err error
+
+	if traceIndexFile != "" {
+		idx, err = trace.Load(traceIndexFile)
+		if err != nil {
+			exitWithError("Failed to load index: %v", err)
+		}
+	} else {
+		idx = buildTraceIndex(context.Background())
+	}
+
+	switch {
+	case traceFile != "":
+		queryTraceFile(idx)
+	case traceTest != "":
+		files := idx.FilesForTest(traceTest)
+		printTraceResult(map[string]interface{}{"test": traceTest, "files": nonNil(files)},
+			fmt.Sprintf("Files touched by %q", traceTest), files)
+	}
+}
+
+// buildTraceIndex builds the index from the per-test coverage listed in the manifest and saves it
+func buildTraceIndex(ctx context.Context) *trace.Index {
+	collectionManifest, err := manifest.Load(traceCoverageDir)
+	if err != nil {
+		exitWithError("Failed to load collection manifest: %v", err)
+	}
+	if len(collectionManifest.Tests) == 0 {
+		exitWithError("No per-test coverage found in manifest (collect it with the testhook package)")
+	}
+
+	if !traceJSON {
+		printInfo("Building traceability index from %d per-test collection(s)...", len(collectionManifest.Tests))
+	}
+
+	idx := trace.NewIndex(collectionManifest.TestName)
+	failed := 0
+	for _, test := range collectionManifest.Tests {
+		testDir := filepath.Join(traceCoverageDir, test.CoverageDir)
+		if err := idx.AddTestCoverage(ctx, test, testDir); err != nil {
+			printWarning("Skipping %s (%s): %v", test.Name, test.Component, err)
+			failed++
+		}
+	}
+
+	if failed == len(collectionManifest.Tests) {
+		exitWithError("Failed to read coverage for any test")
+	}
+
+	output := traceOutput
+	if output == "" {
+		output = filepath.Join(traceCoverageDir, trace.DefaultIndexFile)
+	}
+	if err := idx.Save(output); err != nil {
+		exitWithError("Failed to save index: %v", err)
+	}
+
+	if !traceJSON {
+		printSuccess("Indexed %d test(s) across %d file(s): %s", len(idx.Tests), len(idx.Files), output)
+	}
+
+	return idx
+}
+
+// queryTraceFile answers a --file query (file or file:line)
+func queryTraceFile(idx *trace.Index) {
+	file, line := parseFileLine(traceFile)
+
+	resolved, ok := idx.ResolveFile(file)
+	if !ok {
+		if traceJSON {
+			printTraceResult(map[string]interface{}{"file": file, "tests": []string{}}, "", nil)
+			return
+		}
+		printWarning("File %s not found in index (not covered by any test)", file)
+		return
+	}
+
+	if line > 0 {
+		tests := idx.TestsForLine(resolved, line)
+		printTraceResult(map[string]interface{}{"file": resolved, "line": line, "tests": nonNil(tests)},
+			fmt.Sprintf("Tests covering %s:%d", resolved, line), tests)
+		return
+	}
+
+	tests := idx.TestsForFile(resolved)
+	printTraceResult(map[string]interface{}{"file": resolved, "tests": nonNil(tests)},
+		fmt.Sprintf("Tests covering %s", resolved), tests)
+}
+
+// parseFileLine splits "path:line" into its parts. A path without a numeric
+// line suffix returns line 0.
+func parseFileLine(s string) (string, int) {
+	idx := strings.LastIndex(s, ":")
+	if idx == -1 {
+		return s, 0
+	}
+	line, err := strconv.Atoi(s[idx+1:])
+	if err != nil || line <= 0 {
+		return s, 0
+	}
+	return s[:idx], line
+}
+
+// printTraceResult prints a query result as JSON or as a titled list
+func printTraceResult(result interface{}, title string, items []string) {
+	if traceJSON {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			exitWithError("Failed to marshal result: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("\n%s (%d):\n", title, len(items))
+	for _, item := range items {
+		fmt.Printf("  - %s\n", item)
+	}
+}
+
+// nonNil returns an empty slice instead of nil so JSON output is [] rather than null
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/konflux-ci/coverport/cli/internal/discovery"
+	"github.com/konflux-ci/coverport/cli/internal/processor"
+	coverageclient "github.com/konflux-ci/coverport/cli/pkg/client"
+)
+
+const (
+	// snapshotsDirName is the directory (per component) holding watch mode snapshots
+	snapshotsDirName = "snapshots"
+
+	// seriesFileName is the coverage-over-time series written next to the snapshots
+	seriesFileName = "series.json"
+)
+
+// watchTarget is a coverage server polled periodically in watch mode
+type watchTarget struct {
+	name        string // Display name (component or pod)
+	component   string
+	pod         *discovery.PodInfo // nil for --url collection
+	client      *coverageclient.CoverageClient
+	coverageURL string
+	port        int
+	stop        func()
+
+	metaSnapshot string // Snapshot holding the Go metadata, copied into later snapshots
+	startedAt    time.Time
+	series       coverageSeries
+	lastCovered  int
+}
+
+// coverageSeries records coverage over time for one target
+type coverageSeries struct {
+	Component string        `json:"component"`
+	Interval  string        `json:"interval"`
+	StartedAt string        `json:"started_at"`
+	Points    []seriesPoint `json:"points"`
+}
+
+// seriesPoint is a single snapshot in the series. Statement counts and percentage
+// are only computed for Go coverage.
+type seriesPoint struct {
+	Timestamp      string  `json:"timestamp"`
+	ElapsedSeconds int64   `json:"elapsed_seconds"`
+	Snapshot       string  `json:"snapshot"`
+	Covered        int     `json:"covered_statements,omitempty"`
+	Total          int     `json:"total_statements,omitempty"`
+	Percent        float64 `json:"percent,omitempty"`
+	NewlyCovered   int     `json:"newly_covered,omitempty"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// watchPods keeps port-forwards to the pods open and snapshots their coverage
+// every --interval until --duration elapses or the command is interrupted.
+// It returns the snapshots directory (relative to the output directory) per pod.
+func watchPods(pods []discovery.PodInfo, fallbackPorts []int, portExplicit bool, verbose bool) map[string]string {
+	var targets []*watchTarget
+
+	for i := range pods {
+		podInfo := &pods[i]
+		target, err := connectWatchPod(podInfo, fallbackPorts, portExplicit, verbose)
+		if err != nil {
+			printWarning("Failed to watch %s%s/%s: %v", clusterPrefix(podInfo.Cluster), podInfo.Namespace, podInfo.Name, err)
+			continue
+		}
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		exitWithError("Failed to connect to any pods for watching")
+	}
+
+	runWatch(targets)
+
+	snapshotDirs := make(map[string]string)
+	for _, t := range targets {
+		t.disconnect()
+		snapshotDirs[podKey(*t.pod)] = filepath.Join(t.component, t.snapshotsDir())
+	}
+	return snapshotDirs
+}
+
+// watchURL snapshots the coverage of --url every --interval until --duration
+// elapses or the command is interrupted. It returns the snapshots directory
+// relative to the output directory.
+func watchURL() string {
+	client, err := coverageclient.NewClientForURL(outputDir)
+	if err != nil {
+		exitWithError("Failed to create coverage client: %v", err)
+	}
+	client.SetDefaultFilters(filters)
+	if err := configureCoverageToken(context.Background(), client); err != nil {
+		exitWithError("Failed to configure coverage token: %v", err)
+	}
+	if err := configureCoverageTLS(client); err != nil {
+		exitWithError("Failed to configure TLS: %v", err)
+	}
+
+	target := &watchTarget{
+		name:        coverageURL,
+		component:   "direct-url",
+		client:      client,
+		coverageURL: coverageURL,
+	}
+	if err := target.snapshot(); err != nil {
+		exitWithError("Failed to collect coverage from URL: %v", err)
+	}
+
+	runWatch([]*watchTarget{target})
+	return snapshotsDirName
+}
+
+// connectWatchPod opens a port-forward to the pod's coverage server and takes the
+// first (full) snapshot, trying each candidate port in order
+func connectWatchPod(podInfo *discovery.PodInfo, fallbackPorts []int, portExplicit bool, verbose bool) (*watchTarget, error) {
+	fmt.Printf("\nConnecting to: %s%s/%s (component: %s)\n", clusterPrefix(podInfo.Cluster), podInfo.Namespace, podInfo.Name, podInfo.ComponentName)
+
+	component := podInfo.ComponentName
+	componentDir := filepath.Join(outputDir, component)
+	if err := os.MkdirAll(componentDir, 0755); err != nil {
+		return nil, fmt.Errorf("create component directory: %w", err)
+	}
+
+	client, err := coverageclient.NewClientForConfig(clusterRestConfig(podInfo.Cluster), podInfo.Namespace, componentDir)
+	if err != nil {
+		return nil, fmt.Errorf("create coverage client: %w", err)
+	}
+	client.SetSourceDirectory(sourceDir)
+	client.SetPathRemapping(enableRemap)
+	if len(filters) > 0 {
+		client.SetDefaultFilters(filters)
+	}
+	client.SetPathPrefix(pathPrefix)
+	tokenCtx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	err = configureCoverageToken(tokenCtx, client)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if err := configureTransport(client); err != nil {
+		return nil, err
+	}
+
+	ports := fallbackPorts
+	if !portExplicit {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		servers, err := client.DiscoverCoverageServers(ctx, podInfo.Name, podInfo.ContainerName)
+		cancel()
+		if err == nil && len(servers) > 0 {
+			// Watch mode follows a single server per pod
+			fmt.Printf("  Detected coverage server on port %d (%s)\n", servers[0].Port, servers[0].DirName())
+			ports = []int{servers[0].Port}
+		} else if err != nil && verbose {
+			fmt.Printf("  Warning: Port detection failed (%v), falling back to %v\n", err, fallbackPorts)
+		}
+	}
+
+	var lastErr error
+	for _, port := range ports {
+		target := &watchTarget{
+			name:      fmt.Sprintf("%s%s/%s", clusterPrefix(podInfo.Cluster), podInfo.Namespace, podInfo.Name),
+			component: component,
+			pod:       podInfo,
+			client:    client,
+			port:      port,
+		}
+		if lastErr = target.connect(); lastErr != nil {
+			continue
+		}
+		if lastErr = target.snapshot(); lastErr != nil {
+			target.disconnect()
+			if len(ports) > 1 {
+				fmt.Printf("  Warning: Port %d failed, trying next...\n", port)
+			}
+			continue
+		}
+		return target, nil
+	}
+
+	return nil, fmt.Errorf("collect coverage (tried ports %v): %w", ports, lastErr)
+}
+
+// runWatch snapshots all targets every --interval until --duration elapses or
+// the command receives SIGINT/SIGTERM. The first snapshot must already be taken.
+func runWatch(targets []*watchTarget) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if watchDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, watchDuration)
+		defer cancel()
+		fmt.Printf("\nWatching %d target(s) every %s for %s\n", len(targets), watchInterval, watchDuration)
+	} else {
+		fmt.Printf("\nWatching %d target(s) every %s until interrupted\n", len(targets), watchInterval)
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Printf("\nWatch finished after %s\n", time.Since(start).Round(time.Second))
+			return
+		case <-ticker.C:
+			for _, t := range targets {
+				if err := t.snapshot(); err != nil {
+					printWarning("Snapshot of %s failed: %v", t.name, err)
+				}
+			}
+		}
+	}
+}
+
+// connect opens the connection to the target pod
+func (t *watchTarget) connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	coverageURL, stop, err := t.client.ConnectPod(ctx, t.pod.Name, t.port)
+	if err != nil {
+		return fmt.Errorf("connect to pod: %w", err)
+	}
+	t.stop = stop
+	t.coverageURL = coverageURL
+	return nil
+}
+
+// disconnect closes the connection to the target pod, if open. Stop functions
+// of transports may only be called once.
+func (t *watchTarget) disconnect() {
+	if t.stop != nil {
+		t.stop()
+		t.stop = nil
+	}
+}
+
+// snapshot collects the target's coverage into a new time-stamped snapshot and
+// appends it to the series. Only the first snapshot fetches the Go metadata;
+// later ones fetch counters only and reuse it. A dropped port-forward is
+// re-established once per snapshot; failed snapshots are recorded with the error.
+func (t *watchTarget) snapshot() error {
+	now := time.Now()
+	if t.startedAt.IsZero() {
+		t.startedAt = now
+	}
+	snapshotDir := filepath.Join(t.snapshotsDir(), now.UTC().Format("20060102T150405Z"))
+
+	fmt.Printf("\n[%s] Snapshot of %s\n", now.Format("15:04:05"), t.name)
+
+	err := t.collect(snapshotDir)
+	if err != nil && t.pod != nil {
+		fmt.Printf("  Reconnecting...\n")
+		t.disconnect()
+		if connErr := t.connect(); connErr != nil {
+			err = fmt.Errorf("%w (reconnect failed: %v)", err, connErr)
+		} else {
+			err = t.collect(snapshotDir)
+		}
+	}
+
+	point := seriesPoint{
+		Timestamp:      now.Format(time.RFC3339),
+		ElapsedSeconds: int64(now.Sub(t.startedAt).Seconds()),
+		Snapshot:       snapshotDir,
+	}
+	if err != nil {
+		point.Error = err.Error()
+	} else {
+		t.addCoverage(&point, snapshotDir)
+	}
+
+	t.series.Component = t.component
+	t.series.Interval = watchInterval.String()
+	t.series.StartedAt = t.startedAt.Format(time.RFC3339)
+	t.series.Points = append(t.series.Points, point)
+	if writeErr := t.writeSeries(); writeErr != nil {
+		printWarning("Failed to write coverage series: %v", writeErr)
+	}
+
+	return err
+}
+
+// collect fetches coverage into the snapshot directory
+func (t *watchTarget) collect(snapshotDir string) error {
+	if t.metaSnapshot == "" {
+		if err := t.client.CollectCoverageFromURL(t.coverageURL, snapshotDir); err != nil {
+			return err
+		}
+		t.metaSnapshot = snapshotDir
+		return nil
+	}
+
+	if err := t.client.CollectCountersFromURL(t.coverageURL, snapshotDir); err != nil {
+		return err
+	}
+	return copyMetaFiles(t.snapshotPath(t.metaSnapshot), t.snapshotPath(snapshotDir))
+}
+
+// addCoverage computes statement coverage of a Go snapshot for the series
+func (t *watchTarget) addCoverage(point *seriesPoint, snapshotDir string) {
+	metaFiles, _ := filepath.Glob(filepath.Join(t.snapshotPath(snapshotDir), "covmeta.*"))
+	if len(metaFiles) == 0 {
+		return
+	}
+
+	if err := t.client.GenerateCoverageReport(snapshotDir); err != nil {
+		point.Error = fmt.Sprintf("generate report: %v", err)
+		return
+	}
+	profile := filepath.Join(t.snapshotPath(snapshotDir), "coverage.out")
+	if err := t.client.FilterCoverageReport(snapshotDir); err == nil {
+		profile = filepath.Join(t.snapshotPath(snapshotDir), "coverage_filtered.out")
+	}
+
+	covered, total, err := processor.GoStatementCoverage(profile)
+	if err != nil {
+		point.Error = err.Error()
+		return
+	}
+
+	point.Covered = covered
+	point.Total = total
+	if total > 0 {
+		point.Percent = float64(covered) * 100 / float64(total)
+	}
+	if len(t.series.Points) > 0 {
+		point.NewlyCovered = covered - t.lastCovered
+	}
+	t.lastCovered = covered
+
+	fmt.Printf("  Coverage: %.1f%% (%d/%d statements, %+d since last snapshot)\n",
+		point.Percent, covered, total, point.NewlyCovered)
+}
+
+// snapshotsDir returns the directory holding the target's snapshots, relative to
+// its component directory. Like the test directories of a regular collection,
+// it is suffixed with the cluster, as the same component may run in several.
+func (t *watchTarget) snapshotsDir() string {
+	if t.pod != nil && t.pod.Cluster != "" {
+		return snapshotsDirName + "-" + clusterDirName(t.pod.Cluster)
+	}
+	return snapshotsDirName
+}
+
+// snapshotPath returns the absolute path of a snapshot directory
+func (t *watchTarget) snapshotPath(snapshotDir string) string {
+	if t.pod != nil {
+		return filepath.Join(outputDir, t.component, snapshotDir)
+	}
+	return filepath.Join(outputDir, snapshotDir)
+}
+
+// writeSeries saves the coverage-over-time series next to the snapshots
+func (t *watchTarget) writeSeries() error {
+	data, err := json.MarshalIndent(t.series, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal series: %w", err)
+	}
+	return os.WriteFile(filepath.Join(t.snapshotPath(t.snapshotsDir()), seriesFileName), data, 0644)
+}
+
+// copyMetaFiles copies the Go coverage metadata files (covmeta.*) between directories
+func copyMetaFiles(srcDir, dstDir string) error {
+	metaFiles, err := filepath.Glob(filepath.Join(srcDir, "covmeta.*"))
+	if err != nil {
+		return err
+	}
+
+	for _, src := range metaFiles {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("read metadata file: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dstDir, filepath.Base(src)), data, 0644); err != nil {
+			return fmt.Errorf("write metadata file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// podKey identifies a pod across discovery and collection
+func podKey(pod discovery.PodInfo) string {
+	return clusterPrefix(pod.Cluster) + pod.Namespace + "/" + pod.Name
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konflux-ci/coverport/cli/internal/discovery"
+	coverageclient "github.com/konflux-ci/coverport/cli/pkg/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// watchServer is a Rust coverage server reached through numbered connections
+// (http://host/conn-N), which stop working when the connection is dropped
+type watchServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	open     map[string]bool
+	requests []string // Query of each coverage request
+}
+
+func newWatchServer(t *testing.T) *watchServer {
+	s := &watchServer{open: make(map[string]bool)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		s.mu.Lock()
+		open := s.open[conn]
+		if open && path == "coverage" {
+			s.requests = append(s.requests, r.URL.RawQuery)
+		}
+		s.mu.Unlock()
+
+		switch {
+		case !open:
+			http.Error(w, "connection lost", http.StatusBadGateway)
+		case path == "coverage":
+			w.Header().Set("X-Art-Coverage-Server", "rust")
+			w.Header().Set("X-Art-Coverage-Format", "rust")
+			w.Header().Set("Content-Type", "application/x-tar")
+			tw := tar.NewWriter(w)
+			tw.WriteHeader(&tar.Header{Name: "default.profraw", Mode: 0644, Size: 7})
+			tw.Write([]byte("profraw"))
+			tw.Close()
+		default:
+			// Legacy server without /coverage/info
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// dropAll breaks all connections, as when the pod restarts
+func (s *watchServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.open)
+}
+
+// coverageRequests returns the query of each coverage request served so far
+func (s *watchServer) coverageRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// watchTransport connects to a watchServer, failing the next failures
+// connections. Like the port-forward transport, its stop closes a channel.
+type watchTransport struct {
+	server   *watchServer
+	failures int
+	connects int
+}
+
+func (t *watchTransport) Name() string { return "watch-test" }
+
+func (t *watchTransport) Connect(ctx context.Context, pod *corev1.Pod, ports []int) (map[int]string, func(), error) {
+	if t.failures > 0 {
+		t.failures--
+		return nil, nil, errors.New("pod not running")
+	}
+	t.connects++
+	conn := fmt.Sprintf("conn-%d", t.connects)
+	t.server.mu.Lock()
+	t.server.open[conn] = true
+	t.server.mu.Unlock()
+
+	stopChan := make(chan struct{})
+	urls := make(map[int]string)
+	for _, port := range ports {
+		urls[port] = t.server.URL + "/" + conn
+	}
+	return urls, func() {
+		close(stopChan)
+		t.server.mu.Lock()
+		delete(t.server.open, conn)
+		t.server.mu.Unlock()
+	}, nil
+}
+
+// newWatchPodTarget returns a target for the pod app-pod, reached through the
+// transport, with the output directory set to a temporary directory
+func newWatchPodTarget(t *testing.T, transport coverageclient.Transport) *watchTarget {
+	t.Helper()
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-pod", Namespace: "test-ns"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+	}
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/test-ns/pods/app-pod" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pod)
+	}))
+	t.Cleanup(apiServer.Close)
+
+	dir, interval := outputDir, watchInterval
+	t.Cleanup(func() { outputDir, watchInterval = dir, interval })
+	outputDir = t.TempDir()
+	watchInterval = time.Minute
+
+	client, err := coverageclient.NewClientForConfig(&rest.Config{Host: apiServer.URL}, "test-ns", filepath.Join(outputDir, "app"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.SetTransport(transport)
+
+	return &watchTarget{
+		name:      "test-ns/app-pod",
+		component: "app",
+		pod:       &discovery.PodInfo{Name: "app-pod", Namespace: "test-ns", ComponentName: "app"},
+		client:    client,
+		port:      53700,
+	}
+}
+
+// readSeries reads the series.json of the target
+func readSeries(t *testing.T, target *watchTarget) coverageSeries {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(target.snapshotPath(snapshotsDirName), seriesFileName))
+	if err != nil {
+		t.Fatalf("Failed to read series: %v", err)
+	}
+	var series coverageSeries
+	if err := json.Unmarshal(data, &series); err != nil {
+		t.Fatalf("Failed to parse series: %v", err)
+	}
+	return series
+}
+
+func TestWatchTarget_Reconnect(t *testing.T) {
+	server := newWatchServer(t)
+	transport := &watchTransport{server: server}
+	target := newWatchPodTarget(t, transport)
+
+	if err := target.connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if err := target.snapshot(); err != nil {
+		t.Fatalf("first snapshot failed: %v", err)
+	}
+
+	// The pod restarts and is not back for the next two snapshots
+	server.dropAll()
+	transport.failures = 2
+	for i := 0; i < 2; i++ {
+		if err := target.snapshot(); err == nil || !strings.Contains(err.Error(), "reconnect failed") {
+			t.Errorf("snapshot %d: expected reconnect error, got %v", i+2, err)
+		}
+	}
+	if err := target.snapshot(); err != nil {
+		t.Fatalf("snapshot after the pod came back failed: %v", err)
+	}
+	target.disconnect()
+
+	if transport.connects != 2 {
+		t.Errorf("got %d connections, want 2", transport.connects)
+	}
+
+	// Only the first snapshot fetches the metadata
+	requests := server.coverageRequests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d coverage requests, want 2: %v", len(requests), requests)
+	}
+	if strings.Contains(requests[0], "nometa") {
+		t.Errorf("first snapshot fetched counters only: %q", requests[0])
+	}
+	if !strings.Contains(requests[1], "nometa=1") {
+		t.Errorf("later snapshot fetched the metadata: %q", requests[1])
+	}
+
+	series := readSeries(t, target)
+	if series.Component != "app" || series.Interval != "1m0s" || series.StartedAt == "" {
+		t.Errorf("unexpected series header: %+v", series)
+	}
+	if len(series.Points) != 4 {
+		t.Fatalf("got %d points, want 4: %+v", len(series.Points), series.Points)
+	}
+	for i, point := range series.Points {
+		failed := i == 1 || i == 2
+		if (point.Error != "") != failed {
+			t.Errorf("point %d: unexpected error %q", i, point.Error)
+		}
+		if !strings.HasPrefix(point.Snapshot, snapshotsDirName+"/") {
+			t.Errorf("point %d: unexpected snapshot %q", i, point.Snapshot)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(target.snapshotPath(series.Points[3].Snapshot), "default.profraw")); err != nil {
+		t.Errorf("last snapshot not saved: %v", err)
+	}
+}
+
+func TestRunWatch(t *testing.T) {
+	server := newWatchServer(t)
+	target := newWatchPodTarget(t, &watchTransport{server: server})
+	if err := target.connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer target.disconnect()
+	if err := target.snapshot(); err != nil {
+		t.Fatalf("first snapshot failed: %v", err)
+	}
+
+	defer func(interval, duration time.Duration) { watchInterval, watchDuration = interval, duration }(watchInterval, watchDuration)
+	watchInterval, watchDuration = 50*time.Millisecond, 275*time.Millisecond
+	runWatch([]*watchTarget{target})
+
+	points := readSeries(t, target).Points
+	if len(points) < 3 {
+		t.Fatalf("got %d points, want a snapshot per interval", len(points))
+	}
+	for i, point := range points {
+		if point.Error != "" {
+			t.Errorf("point %d: unexpected error %q", i, point.Error)
+		}
+	}
+	if requests := server.coverageRequests(); len(requests) != len(points) {
+		t.Errorf("got %d coverage requests for %d points", len(requests), len(points))
+	}
+}
+
+func TestWatchTarget_SnapshotsDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      *discovery.PodInfo
+		expected string
+	}{
+		{"url", nil, "snapshots"},
+		{"single cluster", &discovery.PodInfo{Name: "app-pod"}, "snapshots"},
+		{"cluster", &discovery.PodInfo{Name: "app-pod", Cluster: "spoke-1"}, "snapshots-spoke-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &watchTarget{component: "app", pod: tt.pod}
+			if got := target.snapshotsDir(); got != tt.expected {
+				t.Errorf("snapshotsDir() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Pod annotations that declare coverage targets, so that pods can opt in to
+// collection from their manifests
+const (
+	EnabledAnnotation      = "coverport.io/enabled"       // "true" to collect coverage from the pod
+	ComponentAnnotation    = "coverport.io/component"     // Component name (default: from labels or image)
+	ContainerAnnotation    = "coverport.io/container"     // Container running the coverage server
+	FormatAnnotation       = "coverport.io/format"        // Coverage format, e.g. go, python, nyc
+	SourceRepoAnnotation   = "coverport.io/source-repo"   // Git repository URL of the source
+	SourceCommitAnnotation = "coverport.io/source-commit" // Git commit SHA of the source
+)
+
+// DiscoverPodsByAnnotation finds the running pods annotated with
+// coverport.io/enabled=true. It searches the namespaces of the scope unless
+// a specific namespace is provided. The coverage server port is read
+// from the coverport.io/port annotation at collection time.
+func (d *ImageDiscovery) DiscoverPodsByAnnotation(ctx context.Context, namespace string) ([]PodInfo, error) {
+	runningPods, err := d.listPods(ctx, namespace, runningPodsSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []PodInfo
+	for i := range runningPods {
+		pod := &runningPods[i]
+		enabled, _ := strconv.ParseBool(pod.Annotations[EnabledAnnotation])
+		if !enabled {
+			continue
+		}
+
+		podInfo, err := annotatedPodInfo(pod)
+		if err != nil {
+			fmt.Printf("Warning: skipping pod %s/%s: %v\n", pod.Namespace, pod.Name, err)
+			continue
+		}
+		pods = append(pods, podInfo)
+	}
+
+	return pods, nil
+}
+
+// annotatedPodInfo builds the PodInfo of an opted-in pod from its annotations
+func annotatedPodInfo(pod *corev1.Pod) (PodInfo, error) {
+	var container *corev1.Container
+	if name := pod.Annotations[ContainerAnnotation]; name != "" {
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name == name {
+				container = &pod.Spec.Containers[i]
+			}
+		}
+		if container == nil {
+			return PodInfo{}, fmt.Errorf("container %q from %s not found", name, ContainerAnnotation)
+		}
+	} else if len(pod.Spec.Containers) > 0 {
+		container = &pod.Spec.Containers[0]
+	} else {
+		return PodInfo{}, fmt.Errorf("pod has no containers")
+	}
+
+	componentName := pod.Annotations[ComponentAnnotation]
+	if componentName == "" {
+		componentName = extractComponentName(pod, container.Image)
+	}
+
+	return PodInfo{
+		Name:          pod.Name,
+		Namespace:     pod.Namespace,
+		ComponentName: componentName,
+		Image:         container.Image,
+		ContainerName: container.Name,
+		Format:        pod.Annotations[FormatAnnotation],
+		SourceRepo:    pod.Annotations[SourceRepoAnnotation],
+		SourceCommit:  pod.Annotations[SourceCommitAnnotation],
+	}, nil
+}
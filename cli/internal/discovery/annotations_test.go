@@ -0,0 +1,89 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func annotatedPod(name, namespace string, annotations map[string]string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{"app": "labelled"},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "proxy", Image: "quay.io/org/proxy:latest"},
+				{Name: "server", Image: "quay.io/org/server:latest"},
+			},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestDiscoverPodsByAnnotation(t *testing.T) {
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		annotatedPod("declared", "team-a", map[string]string{
+			EnabledAnnotation:      "true",
+			ComponentAnnotation:    "api",
+			ContainerAnnotation:    "server",
+			FormatAnnotation:       "go",
+			SourceRepoAnnotation:   "https://github.com/org/api",
+			SourceCommitAnnotation: "abc123",
+		}, corev1.PodRunning),
+		annotatedPod("defaults", "team-a", map[string]string{EnabledAnnotation: "true"}, corev1.PodRunning),
+		annotatedPod("disabled", "team-a", map[string]string{EnabledAnnotation: "false"}, corev1.PodRunning),
+		annotatedPod("unannotated", "team-a", nil, corev1.PodRunning),
+		annotatedPod("pending", "team-a", map[string]string{EnabledAnnotation: "true"}, corev1.PodPending),
+		annotatedPod("bad-container", "team-a", map[string]string{
+			EnabledAnnotation:   "true",
+			ContainerAnnotation: "missing",
+		}, corev1.PodRunning),
+		annotatedPod("system", "kube-system", map[string]string{EnabledAnnotation: "true"}, corev1.PodRunning),
+	}
+
+	disco := NewImageDiscovery(honorFieldSelectors(fake.NewSimpleClientset(objects...)))
+	result, err := disco.DiscoverPodsByAnnotation(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found := make(map[string]PodInfo)
+	for _, pod := range result {
+		found[pod.Name] = pod
+	}
+	if len(found) != 2 {
+		t.Fatalf("got pods %v, want declared and defaults", result)
+	}
+
+	want := PodInfo{
+		Name:          "declared",
+		Namespace:     "team-a",
+		ComponentName: "api",
+		Image:         "quay.io/org/server:latest",
+		ContainerName: "server",
+		Format:        "go",
+		SourceRepo:    "https://github.com/org/api",
+		SourceCommit:  "abc123",
+	}
+	if found["declared"] != want {
+		t.Errorf("got %+v, want %+v", found["declared"], want)
+	}
+
+	defaults := found["defaults"]
+	if defaults.ContainerName != "proxy" {
+		t.Errorf("got container %q, want first container %q", defaults.ContainerName, "proxy")
+	}
+	if defaults.ComponentName != "labelled" {
+		t.Errorf("got component %q, want %q from labels", defaults.ComponentName, "labelled")
+	}
+}
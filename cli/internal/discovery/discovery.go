@@ -17,91 +17,213 @@ type PodInfo struct {
 	ComponentName string // Derived from labels or image
 	Image         string
 	ContainerName string // Which container has the matching image
+	Cluster       string // Kubeconfig context or cluster name (multi-cluster collection)
+
+	// Declared by coverport.io annotations (see DiscoverPodsByAnnotation)
+	Format       string // Coverage format, e.g. go, python, nyc
+	SourceRepo   string // Git repository URL of the source
+	SourceCommit string // Git commit SHA of the source
+}
+
+// MatchMode controls how strictly a running container must match a requested image
+type MatchMode string
+
+const (
+	// MatchDigest requires the digest pinned in the pod spec or the running
+	// digest (from the container's imageID) to equal the requested digest, and
+	// skips pods whose digest is unknown; images requested without a digest
+	// match by tag
+	MatchDigest MatchMode = "digest"
+	// MatchTag requires the repository and tag to match. Images requested only by
+	// digest match by digest, or by repository when the running digest is unknown.
+	MatchTag MatchMode = "tag"
+	// MatchRepository only requires the repository to match, so any version does
+	MatchRepository MatchMode = "repository"
+)
+
+// ParseMatchMode parses a match mode name
+func ParseMatchMode(mode string) (MatchMode, error) {
+	switch MatchMode(mode) {
+	case MatchDigest, MatchTag, MatchRepository:
+		return MatchMode(mode), nil
+	}
+	return "", fmt.Errorf("invalid image match mode %q (use digest, tag or repository)", mode)
+}
+
+// NearMiss is a pod running a requested image's repository that was skipped
+type NearMiss struct {
+	Name          string
+	Namespace     string
+	ContainerName string
+	Image         string // Image the container runs
+	Wanted        string // Requested image
+	Reason        string
 }
 
 // ImageDiscovery handles pod discovery based on container images
 type ImageDiscovery struct {
-	clientset kubernetes.Interface
+	clientset  kubernetes.Interface
+	matchMode  MatchMode
+	scope      NamespaceScope
+	nearMisses []NearMiss
 }
 
 // NewImageDiscovery creates a new ImageDiscovery instance
 func NewImageDiscovery(clientset kubernetes.Interface) *ImageDiscovery {
 	return &ImageDiscovery{
 		clientset: clientset,
+		matchMode: MatchTag,
 	}
 }
 
+// SetMatchMode sets how strictly DiscoverPodsByImages matches images (default: tag)
+func (d *ImageDiscovery) SetMatchMode(mode MatchMode) {
+	d.matchMode = mode
+}
+
+// NearMisses returns the pods skipped by the last DiscoverPodsByImages call
+// although they run a requested image's repository, with the reason
+func (d *ImageDiscovery) NearMisses() []NearMiss {
+	return d.nearMisses
+}
+
 // DiscoverPodsByImages finds all pods running the specified container images
-// It searches across all namespaces unless a specific namespace is provided
+// It searches the namespaces of the scope unless a specific namespace is provided
 func (d *ImageDiscovery) DiscoverPodsByImages(ctx context.Context, images []string, namespace string) ([]PodInfo, error) {
 	var pods []PodInfo
+	d.nearMisses = nil
 
-	// Normalize images (remove tag/digest variations for comparison)
-	imageSet := make(map[string]string) // normalized -> original
-	for _, img := range images {
-		imageSet[normalizeImageRef(img)] = img
+	// Pods in all phases are listed, so that pods of the wanted images that are
+	// not running are reported as near misses
+	allPods, err := d.listPods(ctx, namespace, "")
+	if err != nil {
+		return nil, err
 	}
 
-	// List options
-	listOpts := metav1.ListOptions{}
+	for i := range allPods {
+		pod := &allPods[i]
 
-	// Get namespaces to search
-	var namespaces []string
-	if namespace != "" {
-		namespaces = []string{namespace}
-	} else {
-		// Search all namespaces
-		nsList, err := d.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("list namespaces: %w", err)
-		}
-		for _, ns := range nsList.Items {
-			// Skip system namespaces
-			if !isSystemNamespace(ns.Name) {
-				namespaces = append(namespaces, ns.Name)
+		// Check each container in the pod
+		for _, container := range pod.Spec.Containers {
+			imageID := containerImageID(pod, container.Name)
+
+			// Check if this container matches any of our target images
+			var misses []NearMiss
+			matched := false
+			for _, target := range images {
+				if !matchesImage(normalizeImageRef(container.Image), normalizeImageRef(target)) {
+					continue
+				}
+
+				reason := d.mismatch(container.Image, imageID, target)
+				if reason == "" && pod.Status.Phase != corev1.PodRunning {
+					reason = fmt.Sprintf("pod is %s", pod.Status.Phase)
+				}
+				if reason != "" {
+					misses = append(misses, NearMiss{
+						Name:          pod.Name,
+						Namespace:     pod.Namespace,
+						ContainerName: container.Name,
+						Image:         container.Image,
+						Wanted:        target,
+						Reason:        reason,
+					})
+					continue
+				}
+
+				// Extract component name from labels or image
+				componentName := extractComponentName(pod, target)
+
+				pods = append(pods, PodInfo{
+					Name:          pod.Name,
+					Namespace:     pod.Namespace,
+					ComponentName: componentName,
+					Image:         target,
+					ContainerName: container.Name,
+				})
+				matched = true
+				break // Found match for this container
+			}
+
+			if !matched {
+				d.nearMisses = append(d.nearMisses, misses...)
 			}
 		}
 	}
 
-	// Search for pods in each namespace
-	for _, ns := range namespaces {
-		podList, err := d.clientset.CoreV1().Pods(ns).List(ctx, listOpts)
-		if err != nil {
-			fmt.Printf("Warning: failed to list pods in namespace %s: %v\n", ns, err)
-			continue
+	return pods, nil
+}
+
+// mismatch returns why a container running image (with the given imageID from
+// its status) does not match the requested image of the same repository under
+// the match mode, or "" when it matches
+func (d *ImageDiscovery) mismatch(image, imageID, wanted string) string {
+	if d.matchMode == MatchRepository {
+		return ""
+	}
+
+	_, tag, digest := parseImageRef(image)
+	_, wantedTag, wantedDigest := parseImageRef(wanted)
+
+	// Compare digests when one was requested, in digest mode or when there is no tag
+	if wantedDigest != "" && (d.matchMode == MatchDigest || wantedTag == "") {
+		// For multi-arch images the spec pins the image index while some runtimes
+		// report the per-platform manifest in imageID, so either digest matches
+		running := imageIDDigest(imageID)
+		if digest == wantedDigest || running == wantedDigest {
+			return ""
+		}
+		if running == "" {
+			running = digest
+		}
+		switch {
+		case running == "" && d.matchMode == MatchDigest:
+			return "running digest unknown (no imageID in pod status)"
+		case running == "":
+			// Nothing to compare, the repository matches
+			return ""
 		}
+		return fmt.Sprintf("runs digest %s, want %s", shortDigest(running), shortDigest(wantedDigest))
+	}
 
-		for _, pod := range podList.Items {
-			// Only consider running pods
-			if pod.Status.Phase != corev1.PodRunning {
-				continue
-			}
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+	if wantedTag == "" {
+		wantedTag = "latest"
+	}
+	if tag != wantedTag {
+		return fmt.Sprintf("runs tag %q, want %q", tag, wantedTag)
+	}
+	return ""
+}
 
-			// Check each container in the pod
-			for _, container := range pod.Spec.Containers {
-				normalizedContainerImage := normalizeImageRef(container.Image)
-
-				// Check if this container matches any of our target images
-				for normalizedTarget, originalTarget := range imageSet {
-					if matchesImage(normalizedContainerImage, normalizedTarget) {
-						// Extract component name from labels or image
-						componentName := extractComponentName(&pod, originalTarget)
-
-						pods = append(pods, PodInfo{
-							Name:          pod.Name,
-							Namespace:     pod.Namespace,
-							ComponentName: componentName,
-							Image:         originalTarget,
-							ContainerName: container.Name,
-						})
-						break // Found match for this container
-					}
-				}
-			}
+// containerImageID returns the imageID reported in the pod status for a container
+func containerImageID(pod *corev1.Pod, containerName string) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName {
+			return status.ImageID
 		}
 	}
+	return ""
+}
 
-	return pods, nil
+// imageIDDigest extracts the manifest digest from a container status imageID,
+// e.g. docker-pullable://quay.io/org/app@sha256:abc -> sha256:abc. Bare image
+// IDs (sha256:... of the image config) are not comparable and yield "".
+func imageIDDigest(imageID string) string {
+	if idx := strings.LastIndex(imageID, "@"); idx != -1 {
+		return imageID[idx+1:]
+	}
+	return ""
+}
+
+// shortDigest shortens a digest for display
+func shortDigest(digest string) string {
+	if algo, hex, ok := strings.Cut(digest, ":"); ok && len(hex) > 12 {
+		return algo + ":" + hex[:12]
+	}
+	return digest
 }
 
 // DiscoverPodsByLabelSelector finds pods matching a label selector
@@ -144,20 +266,25 @@ func (d *ImageDiscovery) DiscoverPodsByLabelSelector(ctx context.Context, namesp
 // Example: quay.io/user/app:tag -> quay.io/user/app
 // Example: quay.io/user/app@sha256:abc -> quay.io/user/app
 func normalizeImageRef(image string) string {
-	// Remove tag
-	if idx := strings.LastIndex(image, ":"); idx != -1 {
-		// Check if this is a digest (contains @sha256:)
-		if !strings.Contains(image[idx:], "@") {
-			image = image[:idx]
-		}
-	}
+	repository, _, _ := parseImageRef(image)
+	return repository
+}
 
-	// Remove digest
+// parseImageRef splits an image reference into repository, tag and digest
+// Example: quay.io/user/app:v1@sha256:abc -> quay.io/user/app, v1, sha256:abc
+func parseImageRef(image string) (repository, tag, digest string) {
 	if idx := strings.Index(image, "@"); idx != -1 {
+		digest = image[idx+1:]
+		image = image[:idx]
+	}
+
+	// A colon after the last slash separates the tag (others belong to a registry port)
+	if idx := strings.LastIndex(image, ":"); idx != -1 && !strings.Contains(image[idx:], "/") {
+		tag = image[idx+1:]
 		image = image[:idx]
 	}
 
-	return image
+	return image, tag, digest
 }
 
 // matchesImage checks if two normalized image references match
@@ -194,23 +321,3 @@ func extractComponentName(pod *corev1.Pod, image string) string {
 
 	return "unknown"
 }
-
-// isSystemNamespace checks if a namespace is a system namespace
-func isSystemNamespace(ns string) bool {
-	systemNamespaces := []string{
-		"kube-system",
-		"kube-public",
-		"kube-node-lease",
-		"openshift",
-		"openshift-.*",
-		"default",
-	}
-
-	for _, sysNs := range systemNamespaces {
-		if strings.HasPrefix(ns, strings.TrimSuffix(sysNs, ".*")) {
-			return true
-		}
-	}
-
-	return false
-}
@@ -2,6 +2,7 @@ package discovery
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
@@ -366,3 +367,135 @@ func TestDiscoverPodsByLabelSelector(t *testing.T) {
 		}
 	}
 }
+
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		input                   string
+		repository, tag, digest string
+	}{
+		{"quay.io/org/app", "quay.io/org/app", "", ""},
+		{"quay.io/org/app:v1", "quay.io/org/app", "v1", ""},
+		{"quay.io/org/app@sha256:abc", "quay.io/org/app", "", "sha256:abc"},
+		{"quay.io/org/app:v1@sha256:abc", "quay.io/org/app", "v1", "sha256:abc"},
+		{"registry.example.com:5000/app", "registry.example.com:5000/app", "", ""},
+		{"registry.example.com:5000/app:v1", "registry.example.com:5000/app", "v1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			repository, tag, digest := parseImageRef(tt.input)
+			if repository != tt.repository || tag != tt.tag || digest != tt.digest {
+				t.Errorf("parseImageRef(%q) = %q, %q, %q, want %q, %q, %q",
+					tt.input, repository, tag, digest, tt.repository, tt.tag, tt.digest)
+			}
+		})
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	const (
+		wantDigest  = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		otherDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+
+	tests := []struct {
+		name      string
+		mode      MatchMode
+		image     string
+		imageID   string
+		wanted    string
+		wantMatch bool
+	}{
+		{"digest from imageID", MatchDigest, "quay.io/org/app:v1", "docker-pullable://quay.io/org/app@" + wantDigest, "quay.io/org/app@" + wantDigest, true},
+		{"stale digest", MatchDigest, "quay.io/org/app:v1", "quay.io/org/app@" + otherDigest, "quay.io/org/app@" + wantDigest, false},
+		{"digest from spec", MatchDigest, "quay.io/org/app@" + wantDigest, "", "quay.io/org/app@" + wantDigest, true},
+		{"unknown digest", MatchDigest, "quay.io/org/app:v1", "sha256:abc", "quay.io/org/app@" + wantDigest, false},
+		{"index digest in spec", MatchDigest, "quay.io/org/app@" + wantDigest, "quay.io/org/app@" + otherDigest, "quay.io/org/app@" + wantDigest, true},
+		{"tag mode compares digests", MatchTag, "quay.io/org/app:v1", "quay.io/org/app@" + otherDigest, "quay.io/org/app@" + wantDigest, false},
+		{"tag mode with unknown digest", MatchTag, "quay.io/org/app:v1", "sha256:abc", "quay.io/org/app@" + wantDigest, true},
+		{"digest mode falls back to tag", MatchDigest, "quay.io/org/app:v1", "", "quay.io/org/app:v1", true},
+		{"tag mode ignores digest", MatchTag, "quay.io/org/app:v1", "quay.io/org/app@" + otherDigest, "quay.io/org/app:v1@" + wantDigest, true},
+		{"tag mismatch", MatchTag, "quay.io/org/app:v1", "", "quay.io/org/app:v2", false},
+		{"implicit latest", MatchTag, "quay.io/org/app", "", "quay.io/org/app:latest", true},
+		{"repository mode", MatchRepository, "quay.io/org/app:v1", "quay.io/org/app@" + otherDigest, "quay.io/org/app@" + wantDigest, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &ImageDiscovery{matchMode: tt.mode}
+			reason := d.mismatch(tt.image, tt.imageID, tt.wanted)
+			if (reason == "") != tt.wantMatch {
+				t.Errorf("mismatch() = %q, want match %v", reason, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestDiscoverPodsByImages_NearMisses(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	pod := func(name, namespace, imageID string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "main", Image: "quay.io/org/app:latest"}},
+			},
+			Status: corev1.PodStatus{
+				Phase:             phase,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "main", ImageID: imageID}},
+			},
+		}
+	}
+
+	clientset := honorFieldSelectors(fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "current"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "previous"}},
+		pod("current-pod", "current", "quay.io/org/app@"+digest, corev1.PodRunning),
+		pod("stale-pod", "previous", "quay.io/org/app@sha256:2222222222222222", corev1.PodRunning),
+		pod("pending-pod", "current", "quay.io/org/app@"+digest, corev1.PodPending),
+	))
+	disco := NewImageDiscovery(clientset)
+
+	result, err := disco.DiscoverPodsByImages(context.Background(), []string{"quay.io/org/app@" + digest}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 1 || result[0].Name != "current-pod" {
+		t.Fatalf("got %+v, want only current-pod", result)
+	}
+
+	misses := make(map[string]string)
+	for _, miss := range disco.NearMisses() {
+		misses[miss.Name] = miss.Reason
+	}
+	if len(misses) != 2 {
+		t.Fatalf("got near misses %v, want stale-pod and pending-pod", misses)
+	}
+	if !strings.Contains(misses["stale-pod"], "runs digest sha256:222222222222") {
+		t.Errorf("unexpected reason for stale-pod: %q", misses["stale-pod"])
+	}
+	if misses["pending-pod"] != "pod is Pending" {
+		t.Errorf("unexpected reason for pending-pod: %q", misses["pending-pod"])
+	}
+
+	// Any version matches by repository
+	disco.SetMatchMode(MatchRepository)
+	result, err = disco.DiscoverPodsByImages(context.Background(), []string{"quay.io/org/app@" + digest}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Errorf("got %d pods in repository mode, want 2", len(result))
+	}
+}
+
+func TestParseMatchMode(t *testing.T) {
+	for _, mode := range []string{"digest", "tag", "repository"} {
+		if got, err := ParseMatchMode(mode); err != nil || string(got) != mode {
+			t.Errorf("ParseMatchMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if _, err := ParseMatchMode("exact"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
@@ -0,0 +1,181 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// podPageSize is the number of pods requested per List call
+const podPageSize = 500
+
+// runningPodsSelector limits pod lists to running pods
+const runningPodsSelector = "status.phase=Running"
+
+// SystemNamespaces are the namespace globs skipped by cluster-wide discovery
+// unless NamespaceScope.Include or NamespaceScope.Exclude is set
+var SystemNamespaces = []string{
+	"kube-system",
+	"kube-public",
+	"kube-node-lease",
+	"openshift",
+	"openshift-*",
+	"default",
+}
+
+// NamespaceScope selects the namespaces searched by cluster-wide discovery
+// (image, snapshot and annotation discovery without a namespace)
+type NamespaceScope struct {
+	// Include are globs of the namespaces to search (default: all). Namespaces
+	// matched explicitly are searched even if they are system namespaces.
+	Include []string
+	// Exclude are globs of namespaces to skip (default: SystemNamespaces when
+	// Include is empty)
+	Exclude []string
+	// LabelSelector limits the search to namespaces with matching labels
+	LabelSelector string
+}
+
+// Validate checks the globs and the label selector of the scope
+func (s NamespaceScope) Validate() error {
+	for _, pattern := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+	}
+	if _, err := labels.Parse(s.LabelSelector); err != nil {
+		return fmt.Errorf("invalid namespace selector %q: %w", s.LabelSelector, err)
+	}
+	return nil
+}
+
+// Matches reports whether the namespace is searched by name; the label
+// selector is applied separately
+func (s NamespaceScope) Matches(namespace string) bool {
+	if len(s.Include) > 0 && !matchesAny(s.Include, namespace) {
+		return false
+	}
+	exclude := s.Exclude
+	if exclude == nil && len(s.Include) == 0 {
+		exclude = SystemNamespaces
+	}
+	return !matchesAny(exclude, namespace)
+}
+
+// matchesAny reports whether the name matches one of the globs
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isSystemNamespace checks if a namespace is a system namespace
+func isSystemNamespace(ns string) bool {
+	return matchesAny(SystemNamespaces, ns)
+}
+
+// SetNamespaceScope sets the namespaces searched when no namespace is given
+func (d *ImageDiscovery) SetNamespaceScope(scope NamespaceScope) {
+	d.scope = scope
+}
+
+// listPods lists the pods matching the field selector (e.g.
+// runningPodsSelector) in the namespace, or in all namespaces of the scope when
+// it is empty. Cluster-wide, pods are listed with a single paginated call when
+// RBAC allows it, and per namespace otherwise.
+func (d *ImageDiscovery) listPods(ctx context.Context, namespace, fieldSelector string) ([]corev1.Pod, error) {
+	if namespace != "" {
+		pods, err := d.listPodPages(ctx, namespace, fieldSelector)
+		if err != nil {
+			return nil, fmt.Errorf("list pods: %w", err)
+		}
+		return pods, nil
+	}
+
+	// Namespace labels are only known from the namespaces themselves
+	var labelled map[string]bool
+	if d.scope.LabelSelector != "" {
+		namespaces, err := d.SearchNamespaces(ctx)
+		if err != nil {
+			return nil, err
+		}
+		labelled = make(map[string]bool, len(namespaces))
+		for _, ns := range namespaces {
+			labelled[ns] = true
+		}
+	}
+
+	allPods, err := d.listPodPages(ctx, metav1.NamespaceAll, fieldSelector)
+	if err == nil {
+		var pods []corev1.Pod
+		for _, pod := range allPods {
+			if d.scope.Matches(pod.Namespace) && (labelled == nil || labelled[pod.Namespace]) {
+				pods = append(pods, pod)
+			}
+		}
+		return pods, nil
+	}
+	if !apierrors.IsForbidden(err) {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+
+	// Without cluster-wide access, list the pods of each namespace
+	namespaces, err := d.SearchNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pods []corev1.Pod
+	for _, ns := range namespaces {
+		nsPods, err := d.listPodPages(ctx, ns, fieldSelector)
+		if err != nil {
+			fmt.Printf("Warning: failed to list pods in namespace %s: %v\n", ns, err)
+			continue
+		}
+		pods = append(pods, nsPods...)
+	}
+	return pods, nil
+}
+
+// listPodPages lists the pods matching the field selector in the namespace (all
+// namespaces when empty) page by page
+func (d *ImageDiscovery) listPodPages(ctx context.Context, namespace, fieldSelector string) ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	opts := metav1.ListOptions{FieldSelector: fieldSelector, Limit: podPageSize}
+	for {
+		podList, err := d.clientset.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, podList.Items...)
+		if podList.Continue == "" {
+			return pods, nil
+		}
+		opts.Continue = podList.Continue
+	}
+}
+
+// SearchNamespaces returns the namespaces of the scope, which cluster-wide
+// discovery searches one by one without access to pods in all namespaces
+func (d *ImageDiscovery) SearchNamespaces(ctx context.Context) ([]string, error) {
+	nsList, err := d.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: d.scope.LabelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list namespaces: %w", err)
+	}
+	var namespaces []string
+	for _, ns := range nsList.Items {
+		if d.scope.Matches(ns.Name) {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	return namespaces, nil
+}
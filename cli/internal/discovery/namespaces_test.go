@@ -0,0 +1,184 @@
+package discovery
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNamespaceScope_Matches(t *testing.T) {
+	tests := []struct {
+		name      string
+		scope     NamespaceScope
+		namespace string
+		expected  bool
+	}{
+		{"default scope searches user namespaces", NamespaceScope{}, "team-a", true},
+		{"default scope skips system namespaces", NamespaceScope{}, "openshift-monitoring", false},
+		{"default scope skips default", NamespaceScope{}, "default", false},
+		{"include glob", NamespaceScope{Include: []string{"team-*"}}, "team-a", true},
+		{"include glob skips others", NamespaceScope{Include: []string{"team-*"}}, "other", false},
+		{"include overrides system namespaces", NamespaceScope{Include: []string{"openshift-operators"}}, "openshift-operators", true},
+		{"exclude glob", NamespaceScope{Exclude: []string{"*-sandbox"}}, "team-sandbox", false},
+		{"exclude replaces system namespaces", NamespaceScope{Exclude: []string{"*-sandbox"}}, "openshift-operators", true},
+		{"include and exclude", NamespaceScope{Include: []string{"team-*"}, Exclude: []string{"team-b"}}, "team-b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.scope.Matches(tt.namespace); result != tt.expected {
+				t.Errorf("Matches(%q) = %v, want %v", tt.namespace, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNamespaceScope_Validate(t *testing.T) {
+	if err := (NamespaceScope{Include: []string{"team-*"}, LabelSelector: "env=test"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (NamespaceScope{Exclude: []string{"team-["}}).Validate(); err == nil {
+		t.Error("expected error for invalid glob")
+	}
+	if err := (NamespaceScope{LabelSelector: "env in (a"}).Validate(); err == nil {
+		t.Error("expected error for invalid label selector")
+	}
+}
+
+// honorFieldSelectors makes pod lists of the fake clientset apply field
+// selectors (e.g. status.phase=Running) like the API server, which the fake
+// ignores otherwise
+func honorFieldSelectors(clientset *fake.Clientset) *fake.Clientset {
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		list := action.(k8stesting.ListActionImpl)
+		selector := list.GetListRestrictions().Fields
+		if selector == nil || selector.Empty() {
+			return false, nil, nil
+		}
+		obj, err := clientset.Tracker().List(corev1.SchemeGroupVersion.WithResource("pods"), corev1.SchemeGroupVersion.WithKind("Pod"), list.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		podList := obj.(*corev1.PodList)
+		var pods []corev1.Pod
+		for _, pod := range podList.Items {
+			if selector.Matches(fields.Set{"status.phase": string(pod.Status.Phase)}) {
+				pods = append(pods, pod)
+			}
+		}
+		podList.Items = pods
+		return true, podList, nil
+	})
+	return clientset
+}
+
+func runningPod(name, namespace string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "quay.io/org/app:latest"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func podNames(pods []corev1.Pod) string {
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestListPods_Paginated(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	pages := [][]corev1.Pod{
+		{*runningPod("a", "team-a"), *runningPod("sys", "kube-system")},
+		{*runningPod("b", "team-b")},
+	}
+	var requests []metav1.ListOptions
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		list := action.(k8stesting.ListActionImpl)
+		if list.GetNamespace() != "" {
+			t.Errorf("expected a cluster-wide list, got namespace %q", list.GetNamespace())
+		}
+		opts := list.ListOptions
+		requests = append(requests, opts)
+		page, next := 0, "page-2"
+		if opts.Continue == "page-2" {
+			page, next = 1, ""
+		}
+		return true, &corev1.PodList{ListMeta: metav1.ListMeta{Continue: next}, Items: pages[page]}, nil
+	})
+
+	disco := NewImageDiscovery(clientset)
+	pods, err := disco.listPods(context.Background(), "", runningPodsSelector)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := podNames(pods); got != "team-a/a,team-b/b" {
+		t.Errorf("got pods %s", got)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("got %d list calls, want 2", len(requests))
+	}
+	if requests[0].FieldSelector != "status.phase=Running" || requests[0].Limit != podPageSize {
+		t.Errorf("unexpected list options %+v", requests[0])
+	}
+}
+
+func TestListPods_ForbiddenFallsBack(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		runningPod("a", "team-a"),
+		runningPod("b", "team-b"),
+		runningPod("sys", "kube-system"),
+	)
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "" {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", nil)
+		}
+		return false, nil, nil
+	})
+
+	disco := NewImageDiscovery(clientset)
+	pods, err := disco.listPods(context.Background(), "", runningPodsSelector)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := podNames(pods); got != "team-a/a,team-b/b" {
+		t.Errorf("got pods %s", got)
+	}
+}
+
+func TestListPods_NamespaceSelector(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"coverage": "on"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "openshift-operators", Labels: map[string]string{"coverage": "on"}}},
+		runningPod("a", "team-a"),
+		runningPod("b", "team-b"),
+		runningPod("operator", "openshift-operators"),
+	)
+
+	disco := NewImageDiscovery(clientset)
+	disco.SetNamespaceScope(NamespaceScope{Include: []string{"*"}, LabelSelector: "coverage=on"})
+	pods, err := disco.listPods(context.Background(), "", runningPodsSelector)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := podNames(pods); got != "openshift-operators/operator,team-a/a" {
+		t.Errorf("got pods %s", got)
+	}
+}
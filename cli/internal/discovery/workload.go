@@ -0,0 +1,204 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// defaultContainerAnnotation is the annotation kubectl uses to pick a pod's container
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// Workload identifies a Deployment, StatefulSet, DaemonSet or Job
+type Workload struct {
+	Kind string // deployment, statefulset, daemonset or job
+	Name string
+}
+
+func (w Workload) String() string {
+	return w.Kind + "/" + w.Name
+}
+
+// workloadKinds maps accepted kind spellings (as in kubectl) to the canonical kind
+var workloadKinds = map[string]string{
+	"deployment":   "deployment",
+	"deployments":  "deployment",
+	"deploy":       "deployment",
+	"statefulset":  "statefulset",
+	"statefulsets": "statefulset",
+	"sts":          "statefulset",
+	"daemonset":    "daemonset",
+	"daemonsets":   "daemonset",
+	"ds":           "daemonset",
+	"job":          "job",
+	"jobs":         "job",
+}
+
+// ParseWorkload parses a workload reference of the form <kind>/<name>,
+// e.g. deployment/foo or sts/bar
+func ParseWorkload(ref string) (Workload, error) {
+	kind, name, ok := strings.Cut(ref, "/")
+	if !ok || name == "" {
+		return Workload{}, fmt.Errorf("invalid workload %q (expected <kind>/<name>)", ref)
+	}
+	canonical, ok := workloadKinds[strings.ToLower(kind)]
+	if !ok {
+		return Workload{}, fmt.Errorf("unsupported workload kind %q (use deployment, statefulset, daemonset or job)", kind)
+	}
+	return Workload{Kind: canonical, Name: name}, nil
+}
+
+// workloadObject is the part of a workload needed to find its pods
+type workloadObject struct {
+	meta     metav1.Object
+	selector *metav1.LabelSelector
+	template corev1.PodTemplateSpec
+}
+
+// DiscoverPodsByWorkload finds the running pods of a workload. Pods are matched by
+// the workload's selector and must be controlled by it (for Deployments, through
+// one of its ReplicaSets). The component is named after the workload.
+func (d *ImageDiscovery) DiscoverPodsByWorkload(ctx context.Context, namespace string, workload Workload) ([]PodInfo, error) {
+	obj, err := d.getWorkload(ctx, namespace, workload)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(obj.selector)
+	if err != nil {
+		return nil, fmt.Errorf("parse selector of %s: %w", workload, err)
+	}
+	if selector.Empty() {
+		return nil, fmt.Errorf("%s has an empty selector", workload)
+	}
+
+	owners, err := d.workloadOwners(ctx, namespace, workload, obj, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	podList, err := d.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+
+	var result []PodInfo
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || !controlledByAny(pod, owners) {
+			continue
+		}
+
+		container := workloadContainer(pod, obj.template)
+		if container == nil {
+			continue
+		}
+
+		result = append(result, PodInfo{
+			Name:          pod.Name,
+			Namespace:     pod.Namespace,
+			ComponentName: workload.Name,
+			Image:         container.Image,
+			ContainerName: container.Name,
+		})
+	}
+
+	return result, nil
+}
+
+// getWorkload fetches the workload's selector and pod template
+func (d *ImageDiscovery) getWorkload(ctx context.Context, namespace string, workload Workload) (*workloadObject, error) {
+	switch workload.Kind {
+	case "deployment":
+		w, err := d.clientset.AppsV1().Deployments(namespace).Get(ctx, workload.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", workload, err)
+		}
+		return &workloadObject{meta: w, selector: w.Spec.Selector, template: w.Spec.Template}, nil
+	case "statefulset":
+		w, err := d.clientset.AppsV1().StatefulSets(namespace).Get(ctx, workload.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", workload, err)
+		}
+		return &workloadObject{meta: w, selector: w.Spec.Selector, template: w.Spec.Template}, nil
+	case "daemonset":
+		w, err := d.clientset.AppsV1().DaemonSets(namespace).Get(ctx, workload.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", workload, err)
+		}
+		return &workloadObject{meta: w, selector: w.Spec.Selector, template: w.Spec.Template}, nil
+	case "job":
+		w, err := d.clientset.BatchV1().Jobs(namespace).Get(ctx, workload.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", workload, err)
+		}
+		return &workloadObject{meta: w, selector: w.Spec.Selector, template: w.Spec.Template}, nil
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %q", workload.Kind)
+	}
+}
+
+// workloadOwners returns the objects controlling the workload's pods: the
+// workload itself, or the ReplicaSets of a Deployment
+func (d *ImageDiscovery) workloadOwners(ctx context.Context, namespace string, workload Workload, obj *workloadObject, selector labels.Selector) ([]metav1.Object, error) {
+	if workload.Kind != "deployment" {
+		return []metav1.Object{obj.meta}, nil
+	}
+
+	rsList, err := d.clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list replicasets of %s: %w", workload, err)
+	}
+
+	var owners []metav1.Object
+	for i := range rsList.Items {
+		if metav1.IsControlledBy(&rsList.Items[i], obj.meta) {
+			owners = append(owners, &rsList.Items[i])
+		}
+	}
+	return owners, nil
+}
+
+// controlledByAny reports whether the pod is controlled by one of the owners
+func controlledByAny(pod *corev1.Pod, owners []metav1.Object) bool {
+	for _, owner := range owners {
+		if metav1.IsControlledBy(pod, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+// workloadContainer picks the pod's coverage container: the one named by the
+// coverport.io/container or kubectl.kubernetes.io/default-container annotation
+// (copied from the pod template), otherwise the first container of the
+// workload's pod template, which skips sidecars injected at admission (e.g.
+// service mesh proxies)
+func workloadContainer(pod *corev1.Pod, template corev1.PodTemplateSpec) *corev1.Container {
+	var candidates []string
+	for _, key := range []string{ContainerAnnotation, defaultContainerAnnotation} {
+		if name := pod.Annotations[key]; name != "" {
+			candidates = append(candidates, name)
+		}
+	}
+	for _, container := range template.Spec.Containers {
+		candidates = append(candidates, container.Name)
+	}
+
+	for _, name := range candidates {
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name == name {
+				return &pod.Spec.Containers[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseWorkload(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Workload
+		wantErr bool
+	}{
+		{input: "deployment/foo", want: Workload{Kind: "deployment", Name: "foo"}},
+		{input: "deploy/foo", want: Workload{Kind: "deployment", Name: "foo"}},
+		{input: "StatefulSet/bar", want: Workload{Kind: "statefulset", Name: "bar"}},
+		{input: "ds/agent", want: Workload{Kind: "daemonset", Name: "agent"}},
+		{input: "job/migrate", want: Workload{Kind: "job", Name: "migrate"}},
+		{input: "foo", wantErr: true},
+		{input: "deployment/", wantErr: true},
+		{input: "cronjob/nightly", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseWorkload(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWorkload(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseWorkload(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// controllerRef returns an owner reference marking owner as the controller
+func controllerRef(kind, name string, uid types.UID) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, UID: uid, Controller: &controller}}
+}
+
+func workloadPod(name string, labels map[string]string, owners []metav1.OwnerReference, phase corev1.PodPhase, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "test-ns",
+			Labels:          labels,
+			OwnerReferences: owners,
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Name:  container,
+			Image: "quay.io/org/" + container + ":latest",
+		})
+	}
+	return pod
+}
+
+func TestDiscoverPodsByWorkload_Deployment(t *testing.T) {
+	labels := map[string]string{"app": "api"}
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "server"}},
+		},
+	}
+
+	objects := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "test-ns", UID: "deploy-uid"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: template,
+			},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "api-abc",
+				Namespace:       "test-ns",
+				UID:             "rs-uid",
+				Labels:          labels,
+				OwnerReferences: controllerRef("Deployment", "api", "deploy-uid"),
+			},
+		},
+		// Sidecar injected at admission comes first in the pod
+		workloadPod("api-abc-1", labels, controllerRef("ReplicaSet", "api-abc", "rs-uid"), corev1.PodRunning, "istio-proxy", "server"),
+		workloadPod("api-abc-2", labels, controllerRef("ReplicaSet", "api-abc", "rs-uid"), corev1.PodPending, "server"),
+		// Same labels, but not created by the deployment
+		workloadPod("api-debug", labels, nil, corev1.PodRunning, "server"),
+	}
+
+	disco := NewImageDiscovery(fake.NewSimpleClientset(objects...))
+	result, err := disco.DiscoverPodsByWorkload(context.Background(), "test-ns", Workload{Kind: "deployment", Name: "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result) != 1 {
+		t.Fatalf("got %d pods, want 1: %+v", len(result), result)
+	}
+	if result[0].Name != "api-abc-1" {
+		t.Errorf("got pod %q, want %q", result[0].Name, "api-abc-1")
+	}
+	if result[0].ContainerName != "server" {
+		t.Errorf("got container %q, want %q", result[0].ContainerName, "server")
+	}
+	if result[0].ComponentName != "api" {
+		t.Errorf("got component %q, want %q", result[0].ComponentName, "api")
+	}
+	if result[0].Image != "quay.io/org/server:latest" {
+		t.Errorf("got image %q, want %q", result[0].Image, "quay.io/org/server:latest")
+	}
+}
+
+func TestDiscoverPodsByWorkload_JobContainerAnnotation(t *testing.T) {
+	labels := map[string]string{"job-name": "migrate"}
+	pod := workloadPod("migrate-xyz", labels, controllerRef("Job", "migrate", "job-uid"), corev1.PodRunning, "setup", "migrate")
+	pod.Annotations = map[string]string{ContainerAnnotation: "migrate"}
+
+	objects := []runtime.Object{
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "test-ns", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "setup"}, {Name: "migrate"}},
+					},
+				},
+			},
+		},
+		pod,
+	}
+
+	disco := NewImageDiscovery(fake.NewSimpleClientset(objects...))
+	result, err := disco.DiscoverPodsByWorkload(context.Background(), "test-ns", Workload{Kind: "job", Name: "migrate"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result) != 1 {
+		t.Fatalf("got %d pods, want 1", len(result))
+	}
+	if result[0].ContainerName != "migrate" {
+		t.Errorf("got container %q, want %q", result[0].ContainerName, "migrate")
+	}
+}
+
+func TestDiscoverPodsByWorkload_NotFound(t *testing.T) {
+	disco := NewImageDiscovery(fake.NewSimpleClientset())
+
+	_, err := disco.DiscoverPodsByWorkload(context.Background(), "test-ns", Workload{Kind: "statefulset", Name: "db"})
+	if err == nil {
+		t.Error("expected error for missing workload")
+	}
+}
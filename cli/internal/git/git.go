@@ -1,16 +1,22 @@
 package git
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 // RepositoryCloner handles cloning git repositories
 type RepositoryCloner struct {
 	gitPath string
+	out     io.Writer // Progress output, including git's (default: stdout)
 }
 
 // NewRepositoryCloner creates a new repository cloner
@@ -23,9 +29,16 @@ func NewRepositoryCloner() (*RepositoryCloner, error) {
 
 	return &RepositoryCloner{
 		gitPath: gitPath,
+		out:     os.Stdout,
 	}, nil
 }
 
+// SetOutput sets where progress messages go (default: stdout), e.g. stderr when
+// stdout carries the command's result
+func (c *RepositoryCloner) SetOutput(w io.Writer) {
+	c.out = w
+}
+
 // CloneOptions contains options for cloning a repository
 type CloneOptions struct {
 	RepoURL   string
@@ -37,13 +50,13 @@ type CloneOptions struct {
 
 // Clone clones a git repository at a specific commit
 func (c *RepositoryCloner) Clone(ctx context.Context, opts CloneOptions) error {
-	fmt.Printf("Cloning repository: %s\n", opts.RepoURL)
-	fmt.Printf("   Commit: %s\n", opts.CommitSHA)
-	fmt.Printf("   Target: %s\n", opts.TargetDir)
+	fmt.Fprintf(c.out, "Cloning repository: %s\n", opts.RepoURL)
+	fmt.Fprintf(c.out, "   Commit: %s\n", opts.CommitSHA)
+	fmt.Fprintf(c.out, "   Target: %s\n", opts.TargetDir)
 
 	// Check if target directory already exists
 	if _, err := os.Stat(opts.TargetDir); err == nil {
-		fmt.Println("   Target directory already exists, removing...")
+		fmt.Fprintln(c.out, "   Target directory already exists, removing...")
 		if err := os.RemoveAll(opts.TargetDir); err != nil {
 			return fmt.Errorf("failed to remove existing directory: %w", err)
 		}
@@ -71,7 +84,7 @@ func (c *RepositoryCloner) Clone(ctx context.Context, opts CloneOptions) error {
 
 	// Execute clone
 	cmd := exec.CommandContext(ctx, c.gitPath, args...)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = c.out
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
@@ -80,19 +93,19 @@ func (c *RepositoryCloner) Clone(ctx context.Context, opts CloneOptions) error {
 
 	// Checkout specific commit if different from HEAD
 	if opts.CommitSHA != "" {
-		fmt.Printf("Checking out commit: %s\n", opts.CommitSHA)
+		fmt.Fprintf(c.out, "Checking out commit: %s\n", opts.CommitSHA)
 
 		// First, we might need to fetch if this is a shallow clone
 		if opts.Depth > 0 {
-			fmt.Println("   Fetching commit (shallow clone)...")
+			fmt.Fprintln(c.out, "   Fetching commit (shallow clone)...")
 			fetchCmd := exec.CommandContext(ctx, c.gitPath, "-C", opts.TargetDir, "fetch", "--depth=1", "origin", opts.CommitSHA)
-			fetchCmd.Stdout = os.Stdout
+			fetchCmd.Stdout = c.out
 			fetchCmd.Stderr = os.Stderr
 			if err := fetchCmd.Run(); err != nil {
 				// If fetch fails, try without depth
-				fmt.Println("   Retrying fetch without depth limit...")
+				fmt.Fprintln(c.out, "   Retrying fetch without depth limit...")
 				fetchCmd = exec.CommandContext(ctx, c.gitPath, "-C", opts.TargetDir, "fetch", "origin", opts.CommitSHA)
-				fetchCmd.Stdout = os.Stdout
+				fetchCmd.Stdout = c.out
 				fetchCmd.Stderr = os.Stderr
 				if err := fetchCmd.Run(); err != nil {
 					return fmt.Errorf("failed to fetch commit: %w", err)
@@ -101,7 +114,7 @@ func (c *RepositoryCloner) Clone(ctx context.Context, opts CloneOptions) error {
 		}
 
 		checkoutCmd := exec.CommandContext(ctx, c.gitPath, "-C", opts.TargetDir, "checkout", opts.CommitSHA)
-		checkoutCmd.Stdout = os.Stdout
+		checkoutCmd.Stdout = c.out
 		checkoutCmd.Stderr = os.Stderr
 
 		if err := checkoutCmd.Run(); err != nil {
@@ -109,11 +122,11 @@ func (c *RepositoryCloner) Clone(ctx context.Context, opts CloneOptions) error {
 		}
 	}
 
-	fmt.Println("Repository cloned successfully")
+	fmt.Fprintln(c.out, "Repository cloned successfully")
 
 	// Show some info about the cloned repo
 	if err := c.ShowInfo(ctx, opts.TargetDir); err != nil {
-		fmt.Printf("Warning: Failed to show repo info: %v\n", err)
+		fmt.Fprintf(c.out, "Warning: Failed to show repo info: %v\n", err)
 	}
 
 	return nil
@@ -128,28 +141,188 @@ func (c *RepositoryCloner) ShowInfo(ctx context.Context, repoDir string) error {
 		return err
 	}
 
-	fmt.Printf("   Current commit: %s", string(output))
+	fmt.Fprintf(c.out, "   Current commit: %s", string(output))
 
 	// Check for go.mod
 	goModPath := filepath.Join(repoDir, "go.mod")
 	if _, err := os.Stat(goModPath); err == nil {
-		fmt.Println("   Language: Go (go.mod found)")
+		fmt.Fprintln(c.out, "   Language: Go (go.mod found)")
 	}
 
 	// Check for package.json
 	packageJSONPath := filepath.Join(repoDir, "package.json")
 	if _, err := os.Stat(packageJSONPath); err == nil {
-		fmt.Println("   Language: Node.js (package.json found)")
+		fmt.Fprintln(c.out, "   Language: Node.js (package.json found)")
 	}
 
 	// Check for requirements.txt or setup.py
 	requirementsPath := filepath.Join(repoDir, "requirements.txt")
 	setupPyPath := filepath.Join(repoDir, "setup.py")
 	if _, err := os.Stat(requirementsPath); err == nil {
-		fmt.Println("   Language: Python (requirements.txt found)")
+		fmt.Fprintln(c.out, "   Language: Python (requirements.txt found)")
 	} else if _, err := os.Stat(setupPyPath); err == nil {
-		fmt.Println("   Language: Python (setup.py found)")
+		fmt.Fprintln(c.out, "   Language: Python (setup.py found)")
 	}
 
 	return nil
 }
+
+// FileChange describes the lines changed in a file between two commits.
+// Lines are numbered in the base version of the file, so they can be matched
+// against coverage collected from the base build. For pure insertions, the
+// lines surrounding the insertion point are reported.
+type FileChange struct {
+	Path    string // Path in the head commit (base path for deleted files)
+	OldPath string // Path in the base commit (empty for added files)
+	Lines   []int  // Changed lines in the base version of the file
+	Added   bool   // File does not exist in the base commit
+	Deleted bool   // File does not exist in the head commit
+}
+
+// Diff returns the files and lines changed between base and head in repoDir.
+// An empty head compares base against the working tree.
+func (c *RepositoryCloner) Diff(ctx context.Context, repoDir, base, head string) ([]FileChange, error) {
+	// Shallow clones may not contain the base commit yet
+	if err := exec.CommandContext(ctx, c.gitPath, "-C", repoDir, "cat-file", "-e", base+"^{commit}").Run(); err != nil {
+		fmt.Fprintf(c.out, "Fetching base commit: %s\n", base)
+		fetchCmd := exec.CommandContext(ctx, c.gitPath, "-C", repoDir, "fetch", "origin", base)
+		fetchCmd.Stdout = c.out
+		fetchCmd.Stderr = os.Stderr
+		if err := fetchCmd.Run(); err != nil {
+			return nil, fmt.Errorf("failed to fetch base commit: %w", err)
+		}
+	}
+
+	args := []string{"-C", repoDir, "diff", "-U0", "--no-color", "--no-ext-diff", "-M", base}
+	if head != "" {
+		args = append(args, head)
+	}
+
+	cmd := exec.CommandContext(ctx, c.gitPath, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to diff repository: %w\nOutput: %s", err, exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("failed to diff repository: %w", err)
+	}
+
+	return parseDiff(bytes.NewReader(output))
+}
+
+// parseDiff parses the output of "git diff -U0"
+func parseDiff(r io.Reader) ([]FileChange, error) {
+	var changes []FileChange
+	var current *FileChange
+	inHeader := false
+
+	flush := func() {
+		if current != nil {
+			changes = append(changes, *current)
+			current = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			current = &FileChange{}
+			inHeader = true
+		case current == nil:
+			continue
+		case inHeader && strings.HasPrefix(line, "--- "):
+			if path := diffPath(line[4:], "a/"); path != "" {
+				current.OldPath = path
+				current.Path = path
+			} else {
+				current.Added = true
+			}
+		case inHeader && strings.HasPrefix(line, "+++ "):
+			if path := diffPath(line[4:], "b/"); path != "" {
+				current.Path = path
+			} else {
+				current.Deleted = true
+			}
+		case inHeader && strings.HasPrefix(line, "rename from "):
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+		case inHeader && strings.HasPrefix(line, "rename to "):
+			current.Path = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "@@ "):
+			inHeader = false
+			lines, err := hunkLines(line)
+			if err != nil {
+				return nil, err
+			}
+			if !current.Added {
+				current.Lines = append(current.Lines, lines...)
+			}
+		}
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read diff: %w", err)
+	}
+
+	return changes, nil
+}
+
+// diffPath extracts the path from a "---"/"+++" header value, returning an
+// empty string for /dev/null
+func diffPath(value, prefix string) string {
+	value = strings.TrimSuffix(value, "\t")
+	if value == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(value, prefix)
+}
+
+// hunkLines returns the base-side lines affected by a hunk header such as
+// "@@ -10,3 +10,4 @@"
+func hunkLines(header string) ([]int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		return nil, fmt.Errorf("invalid hunk header: %s", header)
+	}
+
+	start, count, err := parseRange(strings.TrimPrefix(fields[1], "-"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hunk header %q: %w", header, err)
+	}
+
+	// Pure insertion after line "start": report the neighbouring lines
+	if count == 0 {
+		if start == 0 {
+			return []int{1}, nil
+		}
+		return []int{start, start + 1}, nil
+	}
+
+	lines := make([]int, 0, count)
+	for l := start; l < start+count; l++ {
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// parseRange parses a hunk range "start[,count]"
+func parseRange(s string) (int, int, error) {
+	startStr, countStr, found := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return start, 1, nil
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, count, nil
+}
@@ -0,0 +1,173 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/pkg/users/users.go b/pkg/users/users.go
+index 1111111..2222222 100644
+--- a/pkg/users/users.go
++++ b/pkg/users/users.go
+@@ -10,2 +10,3 @@ func Create() {
+-	old := 1
+--- tricky removed line
++	new := 2
++	more := 3
++	evenMore := 4
+@@ -20,0 +22 @@ func Delete() {
++	inserted()
+@@ -30 +32,0 @@ func List() {
+-	removed()
+diff --git a/new.go b/new.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package main
++
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+index 4444444..0000000
+--- a/gone.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-
+diff --git a/old/name.go b/new/name.go
+similarity 90%
+rename from old/name.go
+rename to new/name.go
+index 5555555..6666666 100644
+--- a/old/name.go
++++ b/new/name.go
+@@ -5 +5 @@
+-	a()
++	b()
+`
+
+	changes, err := parseDiff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatalf("parseDiff failed: %v", err)
+	}
+
+	expected := []FileChange{
+		{Path: "pkg/users/users.go", OldPath: "pkg/users/users.go", Lines: []int{10, 11, 20, 21, 30}},
+		{Path: "new.go", Added: true},
+		{Path: "gone.go", OldPath: "gone.go", Lines: []int{1, 2}, Deleted: true},
+		{Path: "new/name.go", OldPath: "old/name.go", Lines: []int{5}},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("parseDiff mismatch:\n got: %+v\nwant: %+v", changes, expected)
+	}
+}
+
+func TestHunkLines(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected []int
+		wantErr  bool
+	}{
+		{"@@ -5,3 +5,3 @@", []int{5, 6, 7}, false},
+		{"@@ -5 +5 @@ func main()", []int{5}, false},
+		{"@@ -5,0 +6,2 @@", []int{5, 6}, false},
+		{"@@ -0,0 +1 @@", []int{1}, false},
+		{"@@ bogus @@", nil, true},
+	}
+
+	for _, tt := range tests {
+		lines, err := hunkLines(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("hunkLines(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(lines, tt.expected) {
+			t.Errorf("hunkLines(%q) = %v, want %v", tt.header, lines, tt.expected)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	cloner, err := NewRepositoryCloner()
+	if err != nil {
+		t.Skip("git not available")
+	}
+
+	repoDir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repoDir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	run("init", "-q")
+	if err := os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\n\nfunc main() {\n\tprintln(1)\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", ".")
+	run("commit", "-q", "-m", "base")
+	base := run("rev-parse", "HEAD")
+
+	if err := os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\n\nfunc main() {\n\tprintln(2)\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("commit", "-q", "-am", "change")
+
+	changes, err := cloner.Diff(context.Background(), repoDir, base, "HEAD")
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	expected := []FileChange{{Path: "main.go", OldPath: "main.go", Lines: []int{4}}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Diff = %+v, want %+v", changes, expected)
+	}
+}
+
+func TestClone_Output(t *testing.T) {
+	cloner, err := NewRepositoryCloner()
+	if err != nil {
+		t.Skip("git not available")
+	}
+
+	repoDir := t.TempDir()
+	cmd := exec.Command("git", "-C", repoDir, "commit", "-q", "--allow-empty", "-m", "base")
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if output, err := exec.Command("git", "-C", repoDir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v\n%s", err, output)
+	}
+
+	// Progress goes to the configured output, e.g. stderr for JSON on stdout
+	var out strings.Builder
+	cloner.SetOutput(&out)
+	if err := cloner.Clone(context.Background(), CloneOptions{
+		RepoURL:   repoDir,
+		CommitSHA: "HEAD",
+		TargetDir: filepath.Join(t.TempDir(), "repo"),
+	}); err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Repository cloned successfully") {
+		t.Errorf("progress not written to the output: %q", out.String())
+	}
+}
@@ -18,6 +18,7 @@ type CollectionManifest struct {
 	CollectedAt      string               `json:"collected_at"`
 	CollectionParams CollectionParameters `json:"collection_params"`
 	Components       []ComponentInfo      `json:"components"`
+	Tests            []TestInfo           `json:"tests,omitempty"`
 }
 
 // CollectionParameters stores the parameters used during collection
@@ -36,7 +37,28 @@ type ComponentInfo struct {
 	Namespace     string `json:"namespace,omitempty"`
 	PodName       string `json:"pod_name,omitempty"`
 	ContainerName string `json:"container_name,omitempty"`
+	Cluster       string `json:"cluster,omitempty"` // Cluster the pod ran in (multi-cluster collection)
 	CollectedAt   string `json:"collected_at"`
+	Format        string `json:"format,omitempty"`        // Coverage format (go, python, nyc, rust) detected at collection
+	SnapshotsDir  string `json:"snapshots_dir,omitempty"` // Periodic snapshots and series.json (watch mode)
+	RepoURL       string `json:"repo_url,omitempty"`      // Source repository from pod annotations or Component resources
+	CommitSHA     string `json:"commit_sha,omitempty"`    // Source commit from pod annotations or Component resources
+
+	// Coverage server the data came from, for pods running several of them
+	Binary string `json:"binary,omitempty"`
+	Pid    int    `json:"pid,omitempty"`
+	Port   int    `json:"port,omitempty"`
+}
+
+// TestInfo represents coverage collected for a single test (e.g. a Ginkgo spec).
+// Counters are reset before the test runs, so the coverage only contains
+// the code exercised by that test.
+type TestInfo struct {
+	Name        string `json:"name"`
+	Component   string `json:"component"`
+	CoverageDir string `json:"coverage_dir"`
+	Failed      bool   `json:"failed,omitempty"`
+	CollectedAt string `json:"collected_at"`
 }
 
 // NewCollectionManifest creates a new collection manifest
@@ -55,6 +77,11 @@ func (m *CollectionManifest) AddComponent(component ComponentInfo) {
 	m.Components = append(m.Components, component)
 }
 
+// AddTest adds a per-test coverage entry to the manifest
+func (m *CollectionManifest) AddTest(test TestInfo) {
+	m.Tests = append(m.Tests, test)
+}
+
 // Save writes the manifest to a file
 func (m *CollectionManifest) Save(outputDir string) error {
 	manifestPath := filepath.Join(outputDir, "metadata.json")
@@ -50,6 +50,33 @@ func TestAddComponent(t *testing.T) {
 	}
 }
 
+func TestAddTest(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	m := NewCollectionManifest("e2e", CollectionParameters{})
+	m.AddTest(TestInfo{Name: "creates a user", Component: "backend", CoverageDir: "backend/tests/creates-a-user", CollectedAt: "now"})
+	m.AddTest(TestInfo{Name: "deletes a user", Component: "backend", CoverageDir: "backend/tests/deletes-a-user", Failed: true, CollectedAt: "now"})
+
+	if err := m.Save(tmpDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(loaded.Tests) != 2 {
+		t.Fatalf("got %d tests, want 2", len(loaded.Tests))
+	}
+	if loaded.Tests[0].Name != "creates a user" || loaded.Tests[0].Failed {
+		t.Errorf("unexpected first test: %+v", loaded.Tests[0])
+	}
+	if loaded.Tests[1].CoverageDir != "backend/tests/deletes-a-user" || !loaded.Tests[1].Failed {
+		t.Errorf("unexpected second test: %+v", loaded.Tests[1])
+	}
+}
+
 func TestSaveAndLoad(t *testing.T) {
 	tmpDir := t.TempDir()
 
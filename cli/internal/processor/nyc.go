@@ -153,6 +153,15 @@ func findNYCCoverageFile(inputDir string) (string, error) {
 	return "", fmt.Errorf("no NYC coverage file found in %s", inputDir)
 }
 
+// LoadNYCCoverage finds and reads the NYC/Istanbul coverage JSON in the input directory
+func LoadNYCCoverage(inputDir string) (NYCCoverageData, error) {
+	coverageFile, err := findNYCCoverageFile(inputDir)
+	if err != nil {
+		return nil, err
+	}
+	return readNYCCoverage(coverageFile)
+}
+
 // readNYCCoverage reads NYC/Istanbul coverage JSON
 func readNYCCoverage(filePath string) (NYCCoverageData, error) {
 	data, err := os.ReadFile(filePath)
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -615,6 +616,62 @@ func (p *CoverageProcessor) showGoCoverageSummary(ctx context.Context, goPath, c
 	return nil
 }
 
+// GoStatementCoverage returns the number of covered and total statements in a
+// Go text coverage profile. Blocks listed more than once (e.g. from several
+// counter files) are counted once.
+func GoStatementCoverage(coverageFile string) (int, int, error) {
+	data, err := os.ReadFile(coverageFile)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read coverage file: %w", err)
+	}
+
+	type block struct {
+		stmts   int
+		covered bool
+	}
+	blocks := make(map[string]*block)
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		// Format: file:startLine.startCol,endLine.endCol numStmts count
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		stmts, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+
+		b, ok := blocks[fields[0]]
+		if !ok {
+			b = &block{stmts: stmts}
+			blocks[fields[0]] = b
+		}
+		if count > 0 {
+			b.covered = true
+		}
+	}
+
+	covered, total := 0, 0
+	for _, b := range blocks {
+		total += b.stmts
+		if b.covered {
+			covered += b.stmts
+		}
+	}
+
+	return covered, total, nil
+}
+
 // generateHTMLReport generates an HTML coverage report
 func (p *CoverageProcessor) generateHTMLReport(ctx context.Context, goPath, coverageFile, repoRoot string) error {
 	fmt.Println("   Generating HTML coverage report...")
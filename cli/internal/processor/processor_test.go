@@ -231,6 +231,30 @@ github.com/test/pkg/main_test.go:40.1,42.2 2 1`
 	}
 }
 
+func TestGoStatementCoverage(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	coverageContent := `mode: atomic
+github.com/test/pkg/main.go:10.1,12.2 2 1
+github.com/test/pkg/main.go:14.1,16.2 3 0
+github.com/test/pkg/handler.go:30.1,32.2 1 0
+github.com/test/pkg/handler.go:30.1,32.2 1 4
+`
+
+	coverageFile := filepath.Join(tmpDir, "coverage.out")
+	if err := os.WriteFile(coverageFile, []byte(coverageContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	covered, total, err := GoStatementCoverage(coverageFile)
+	if err != nil {
+		t.Fatalf("GoStatementCoverage failed: %v", err)
+	}
+	if covered != 3 || total != 6 {
+		t.Errorf("GoStatementCoverage() = (%d, %d), want (3, 6)", covered, total)
+	}
+}
+
 func TestRemapPathsToRelative(t *testing.T) {
 	tmpDir := t.TempDir()
 	repoRoot := filepath.Join(tmpDir, "repo")
@@ -0,0 +1,223 @@
+// Package rbac checks the Kubernetes permissions needed for discovery and
+// collection with SelfSubjectAccessReviews, so missing permissions are
+// reported up front instead of as opaque errors during collection.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is a verb on a resource in a namespace, or in all namespaces
+// when Namespace is empty
+type Permission struct {
+	Verb        string
+	Group       string // API group; empty for the core group
+	Resource    string
+	Subresource string
+	Namespace   string
+}
+
+// ResourceName returns the resource as used in RBAC rules, e.g.
+// pods/portforward or snapshots.appstudio.redhat.com
+func (p Permission) ResourceName() string {
+	name := p.Resource
+	if p.Group != "" {
+		name += "." + p.Group
+	}
+	if p.Subresource != "" {
+		name += "/" + p.Subresource
+	}
+	return name
+}
+
+// String returns e.g. "create pods/portforward in team-a"
+func (p Permission) String() string {
+	namespace := p.Namespace
+	if namespace == "" {
+		namespace = "all namespaces"
+	}
+	return fmt.Sprintf("%s %s in %s", p.Verb, p.ResourceName(), namespace)
+}
+
+// Requirement is a step of discovery or collection, satisfied when any of its
+// AnyOf permissions is allowed, or else when all of its AllOf permissions are
+type Requirement struct {
+	Purpose  string
+	Required bool // Whether the step is needed, or only degrades a feature when missing
+	AnyOf    []Permission
+	AllOf    []Permission // Alternative needing all permissions, e.g. per-namespace access
+}
+
+// Result is the outcome of checking a requirement
+type Result struct {
+	Requirement
+	Allowed    []bool // Per permission of AnyOf
+	AllowedAll []bool // Per permission of AllOf; nil when AnyOf is satisfied
+}
+
+// Satisfied reports whether any permission of AnyOf, or all permissions of
+// AllOf, are allowed
+func (r Result) Satisfied() bool {
+	for _, allowed := range r.Allowed {
+		if allowed {
+			return true
+		}
+	}
+	if len(r.AllOf) == 0 || len(r.AllowedAll) != len(r.AllOf) {
+		return false
+	}
+	for _, allowed := range r.AllowedAll {
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// Check reviews each permission of the requirements for the current user or
+// service account. Each permission is reviewed once, and AllOf permissions only
+// when no AnyOf permission is allowed.
+func Check(ctx context.Context, clientset kubernetes.Interface, requirements []Requirement) ([]Result, error) {
+	reviewed := make(map[Permission]bool)
+	reviewAll := func(perms []Permission) ([]bool, error) {
+		results := make([]bool, len(perms))
+		for i, perm := range perms {
+			allowed, ok := reviewed[perm]
+			if !ok {
+				var err error
+				if allowed, err = review(ctx, clientset, perm); err != nil {
+					return nil, err
+				}
+				reviewed[perm] = allowed
+			}
+			results[i] = allowed
+		}
+		return results, nil
+	}
+
+	results := make([]Result, 0, len(requirements))
+	for _, req := range requirements {
+		result := Result{Requirement: req}
+		var err error
+		if result.Allowed, err = reviewAll(req.AnyOf); err != nil {
+			return nil, err
+		}
+		if len(req.AllOf) > 0 && !result.Satisfied() {
+			if result.AllowedAll, err = reviewAll(req.AllOf); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// review runs a SelfSubjectAccessReview for the permission
+func review(ctx context.Context, clientset kubernetes.Interface, perm Permission) (bool, error) {
+	ssar := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   perm.Namespace,
+				Verb:        perm.Verb,
+				Group:       perm.Group,
+				Resource:    perm.Resource,
+				Subresource: perm.Subresource,
+			},
+		},
+	}
+	resp, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, ssar, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("review %s: %w", perm, err)
+	}
+	return resp.Status.Allowed, nil
+}
+
+// Missing returns the required steps none of whose permissions is allowed
+func Missing(results []Result) []Result {
+	var missing []Result
+	for _, result := range results {
+		if result.Required && !result.Satisfied() {
+			missing = append(missing, result)
+		}
+	}
+	return missing
+}
+
+// PrintMatrix writes a table of the permissions and whether they are allowed.
+// Alternatives of a step are listed below it, prefixed with "or". The AllOf
+// permissions are only listed when they were reviewed.
+func PrintMatrix(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  VERB\tRESOURCE\tNAMESPACE\tALLOWED\tNEEDED FOR")
+	for _, result := range results {
+		printRow := func(perm Permission, allowed bool, purpose string) {
+			namespace := perm.Namespace
+			if namespace == "" {
+				namespace = "(all)"
+			}
+			status := "yes"
+			if !allowed {
+				status = "no"
+				if !result.Satisfied() {
+					if result.Required {
+						status = "NO"
+					} else {
+						status = "no (optional)"
+					}
+				}
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", perm.Verb, perm.ResourceName(), namespace, status, purpose)
+		}
+
+		for i, perm := range result.AnyOf {
+			purpose := result.Purpose
+			if i > 0 {
+				purpose = "  or the above"
+			}
+			printRow(perm, result.Allowed[i], purpose)
+		}
+		for i, perm := range result.AllOf {
+			if i >= len(result.AllowedAll) {
+				break
+			}
+			purpose := "  and the above"
+			if i == 0 {
+				purpose = "  or all of these"
+			}
+			printRow(perm, result.AllowedAll[i], purpose)
+		}
+	}
+	tw.Flush()
+}
+
+// Describe returns a one-line description of a missing step. Of the AllOf
+// alternative, only the permissions that are not allowed are listed.
+func Describe(result Result) string {
+	perms := make([]string, len(result.AnyOf))
+	for i, perm := range result.AnyOf {
+		perms[i] = perm.String()
+	}
+	description := strings.Join(perms, " or ")
+
+	var missing []string
+	for i, perm := range result.AllOf {
+		if i >= len(result.AllowedAll) || !result.AllowedAll[i] {
+			missing = append(missing, perm.String())
+		}
+	}
+	if len(missing) > 0 {
+		if description != "" {
+			description += ", or "
+		}
+		description += strings.Join(missing, " and ")
+	}
+	return fmt.Sprintf("%s: needs %s", result.Purpose, description)
+}
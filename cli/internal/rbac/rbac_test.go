@@ -0,0 +1,182 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeReviews returns a clientset allowing the permissions in allowed, keyed by
+// Permission.String, and counts the reviews
+func fakeReviews(allowed map[string]bool, reviews *int) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		ssar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := ssar.Spec.ResourceAttributes
+		perm := Permission{
+			Verb:        attrs.Verb,
+			Group:       attrs.Group,
+			Resource:    attrs.Resource,
+			Subresource: attrs.Subresource,
+			Namespace:   attrs.Namespace,
+		}
+		ssar.Status.Allowed = allowed[perm.String()]
+		return true, ssar, nil
+	})
+	return clientset
+}
+
+func TestPermission_String(t *testing.T) {
+	tests := []struct {
+		perm     Permission
+		expected string
+	}{
+		{Permission{Verb: "create", Resource: "pods", Subresource: "portforward", Namespace: "team-a"}, "create pods/portforward in team-a"},
+		{Permission{Verb: "get", Group: "appstudio.redhat.com", Resource: "snapshots", Namespace: "team-a"}, "get snapshots.appstudio.redhat.com in team-a"},
+		{Permission{Verb: "list", Resource: "pods"}, "list pods in all namespaces"},
+	}
+
+	for _, tt := range tests {
+		if result := tt.perm.String(); result != tt.expected {
+			t.Errorf("String() = %q, want %q", result, tt.expected)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	portForward := Permission{Verb: "create", Resource: "pods", Subresource: "portforward", Namespace: "team-a"}
+	proxy := Permission{Verb: "get", Resource: "pods", Subresource: "proxy", Namespace: "team-a"}
+	exec := Permission{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "team-a"}
+
+	reviews := 0
+	clientset := fakeReviews(map[string]bool{proxy.String(): true}, &reviews)
+	results, err := Check(context.Background(), clientset, []Requirement{
+		{Purpose: "reach coverage servers", Required: true, AnyOf: []Permission{portForward, proxy}},
+		{Purpose: "collect via the coverage socket", Required: true, AnyOf: []Permission{exec}},
+		{Purpose: "save Python coverage", AnyOf: []Permission{exec}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if !results[0].Satisfied() {
+		t.Error("expected the proxy alternative to satisfy the first requirement")
+	}
+	if results[1].Satisfied() || results[2].Satisfied() {
+		t.Error("expected the exec requirements to be unsatisfied")
+	}
+	if reviews != 3 {
+		t.Errorf("got %d reviews, want 3 (one per distinct permission)", reviews)
+	}
+
+	missing := Missing(results)
+	if len(missing) != 1 || missing[0].Purpose != "collect via the coverage socket" {
+		t.Errorf("unexpected missing requirements: %+v", missing)
+	}
+	if got := Describe(missing[0]); got != "collect via the coverage socket: needs create pods/exec in team-a" {
+		t.Errorf("Describe() = %q", got)
+	}
+}
+
+func TestCheck_AllOf(t *testing.T) {
+	listPods := Permission{Verb: "list", Resource: "pods"}
+	listNamespaces := Permission{Verb: "list", Resource: "namespaces"}
+	teamA := Permission{Verb: "list", Resource: "pods", Namespace: "team-a"}
+	teamB := Permission{Verb: "list", Resource: "pods", Namespace: "team-b"}
+	requirement := Requirement{Purpose: "discover pods in all namespaces", Required: true, AnyOf: []Permission{listPods}, AllOf: []Permission{listNamespaces, teamA, teamB}}
+
+	tests := []struct {
+		name          string
+		allowed       []Permission
+		wantSatisfied bool
+		wantReviews   int
+	}{
+		{"cluster-wide access skips the fallback", []Permission{listPods}, true, 1},
+		{"all of the fallback", []Permission{listNamespaces, teamA, teamB}, true, 4},
+		{"namespaces alone are not enough", []Permission{listNamespaces}, false, 4},
+		{"pods in some namespaces", []Permission{listNamespaces, teamA}, false, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := make(map[string]bool)
+			for _, perm := range tt.allowed {
+				allowed[perm.String()] = true
+			}
+			reviews := 0
+			results, err := Check(context.Background(), fakeReviews(allowed, &reviews), []Requirement{requirement})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := results[0].Satisfied(); got != tt.wantSatisfied {
+				t.Errorf("Satisfied() = %v, want %v", got, tt.wantSatisfied)
+			}
+			if reviews != tt.wantReviews {
+				t.Errorf("got %d reviews, want %d", reviews, tt.wantReviews)
+			}
+		})
+	}
+
+	reviews := 0
+	results, _ := Check(context.Background(), fakeReviews(map[string]bool{listNamespaces.String(): true, teamA.String(): true}, &reviews), []Requirement{requirement})
+	want := "discover pods in all namespaces: needs list pods in all namespaces, or list pods in team-b"
+	if got := Describe(results[0]); got != want {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+}
+
+func TestPrintMatrix(t *testing.T) {
+	results := []Result{
+		{
+			Requirement: Requirement{Purpose: "reach coverage servers", Required: true, AnyOf: []Permission{
+				{Verb: "create", Resource: "pods", Subresource: "portforward", Namespace: "team-a"},
+				{Verb: "get", Resource: "pods", Subresource: "proxy", Namespace: "team-a"},
+			}},
+			Allowed: []bool{false, true},
+		},
+		{
+			Requirement: Requirement{Purpose: "list pods", Required: true, AnyOf: []Permission{{Verb: "list", Resource: "pods"}}},
+			Allowed:     []bool{false},
+		},
+		{
+			Requirement: Requirement{Purpose: "save Python coverage", AnyOf: []Permission{{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "team-a"}}},
+			Allowed:     []bool{false},
+		},
+	}
+
+	var buf bytes.Buffer
+	PrintMatrix(&buf, results)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), buf.String())
+	}
+
+	expected := [][]string{
+		{"VERB", "RESOURCE", "NAMESPACE", "ALLOWED", "NEEDED FOR"},
+		{"create", "pods/portforward", "team-a", "no", "reach coverage servers"},
+		{"get", "pods/proxy", "team-a", "yes", "or the above"},
+		{"list", "pods", "(all)", "NO", "list pods"},
+		{"create", "pods/exec", "team-a", "no (optional)", "save Python coverage"},
+	}
+	for i, fields := range expected {
+		line := lines[i]
+		for _, field := range fields {
+			idx := strings.Index(line, field)
+			if idx < 0 {
+				t.Errorf("line %d %q: missing %q", i, lines[i], field)
+				break
+			}
+			line = line[idx+len(field):]
+		}
+	}
+}
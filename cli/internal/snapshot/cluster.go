@@ -0,0 +1,111 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// SnapshotResource is the Konflux Snapshot custom resource
+var SnapshotResource = schema.GroupVersionResource{
+	Group:    "appstudio.redhat.com",
+	Version:  "v1alpha1",
+	Resource: "snapshots",
+}
+
+// ComponentResource is the Konflux Component custom resource
+var ComponentResource = schema.GroupVersionResource{
+	Group:    "appstudio.redhat.com",
+	Version:  "v1alpha1",
+	Resource: "components",
+}
+
+// ParseSnapshotName parses a snapshot reference of the form [<namespace>/]<name>,
+// using defaultNamespace when none is given
+func ParseSnapshotName(ref, defaultNamespace string) (namespace, name string, err error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		namespace, name = defaultNamespace, ref
+	}
+	if namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid snapshot name %q (expected <namespace>/<name>)", ref)
+	}
+	return namespace, name, nil
+}
+
+// FetchSnapshot reads a Snapshot custom resource from the cluster. Its spec has
+// the same layout as the snapshot JSON passed to Tekton tasks.
+func FetchSnapshot(ctx context.Context, client dynamic.Interface, namespace, name string) (*Snapshot, error) {
+	obj, err := client.Resource(SnapshotResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get snapshot %s/%s: %w", namespace, name, err)
+	}
+
+	spec, found, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil || !found {
+		return nil, fmt.Errorf("snapshot %s/%s has no spec", namespace, name)
+	}
+
+	var snapshot Snapshot
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &snapshot); err != nil {
+		return nil, fmt.Errorf("parse snapshot %s/%s: %w", namespace, name, err)
+	}
+	return &snapshot, nil
+}
+
+// ResolveComponentSources fills in missing git sources of the snapshot's
+// components from the Component custom resources in the namespace: the
+// repository URL from the spec and the revision from the last built commit.
+// The last built commit is only used when the component's last built image is
+// the snapshot's image, as the component may have been rebuilt since.
+// Components that cannot be read are skipped with a warning.
+func (s *Snapshot) ResolveComponentSources(ctx context.Context, client dynamic.Interface, namespace string) {
+	for i := range s.Components {
+		comp := &s.Components[i]
+		if comp.Source.Git.URL != "" && comp.Source.Git.Revision != "" {
+			continue
+		}
+
+		obj, err := client.Resource(ComponentResource).Namespace(namespace).Get(ctx, comp.Name, metav1.GetOptions{})
+		if err != nil {
+			fmt.Printf("Warning: failed to get component %s/%s: %v\n", namespace, comp.Name, err)
+			continue
+		}
+
+		if comp.Source.Git.URL == "" {
+			comp.Source.Git.URL, _, _ = unstructured.NestedString(obj.Object, "spec", "source", "git", "url")
+		}
+		if comp.Source.Git.Revision == "" {
+			lastBuiltImage, _, _ := unstructured.NestedString(obj.Object, "status", "lastPromotedImage")
+			if lastBuiltImage == "" {
+				lastBuiltImage, _, _ = unstructured.NestedString(obj.Object, "spec", "containerImage")
+			}
+			if sameImage(lastBuiltImage, comp.ContainerImage) {
+				comp.Source.Git.Revision, _, _ = unstructured.NestedString(obj.Object, "status", "lastBuiltCommit")
+			} else {
+				fmt.Printf("Warning: component %s/%s was last built as %q, not the snapshot image %q; its revision is unknown\n",
+					namespace, comp.Name, lastBuiltImage, comp.ContainerImage)
+			}
+		}
+	}
+}
+
+// sameImage reports whether two image references refer to the same image:
+// by digest when both are pinned by digest, otherwise by the full reference
+func sameImage(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	_, digestA, okA := strings.Cut(a, "@")
+	_, digestB, okB := strings.Cut(b, "@")
+	if okA && okB {
+		return digestA == digestB
+	}
+	return a == b
+}
@@ -0,0 +1,142 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newFakeDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			SnapshotResource:  "SnapshotList",
+			ComponentResource: "ComponentList",
+		}, objects...)
+}
+
+func konfluxObject(kind, namespace, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: fields}
+	obj.SetAPIVersion("appstudio.redhat.com/v1alpha1")
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestParseSnapshotName(t *testing.T) {
+	tests := []struct {
+		ref, defaultNamespace string
+		wantNamespace         string
+		wantName              string
+		wantErr               bool
+	}{
+		{ref: "tenant/snap", wantNamespace: "tenant", wantName: "snap"},
+		{ref: "snap", defaultNamespace: "tenant", wantNamespace: "tenant", wantName: "snap"},
+		{ref: "snap", wantErr: true},
+		{ref: "tenant/", wantErr: true},
+		{ref: "a/b/c", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		namespace, name, err := ParseSnapshotName(tt.ref, tt.defaultNamespace)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSnapshotName(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			continue
+		}
+		if namespace != tt.wantNamespace || name != tt.wantName {
+			t.Errorf("ParseSnapshotName(%q) = %q, %q, want %q, %q", tt.ref, namespace, name, tt.wantNamespace, tt.wantName)
+		}
+	}
+}
+
+func TestFetchSnapshot(t *testing.T) {
+	snap := konfluxObject("Snapshot", "tenant", "app-snapshot", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"application": "app",
+			"components": []interface{}{
+				map[string]interface{}{
+					"name":           "api",
+					"containerImage": "quay.io/org/api@sha256:abc",
+					"source": map[string]interface{}{
+						"git": map[string]interface{}{
+							"url":      "https://github.com/org/api",
+							"revision": "1234567",
+						},
+					},
+				},
+				map[string]interface{}{
+					"name":           "worker",
+					"containerImage": "quay.io/org/worker@sha256:def",
+				},
+				map[string]interface{}{
+					"name":           "ui",
+					"containerImage": "quay.io/org/ui@sha256:123",
+				},
+			},
+		},
+	})
+	component := konfluxObject("Component", "tenant", "worker", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{
+				"git": map[string]interface{}{"url": "https://github.com/org/worker", "revision": "main"},
+			},
+		},
+		"status": map[string]interface{}{
+			"lastBuiltCommit":   "89abcde",
+			"lastPromotedImage": "quay.io/org/worker@sha256:def",
+		},
+	})
+	// Rebuilt since the snapshot was created
+	rebuilt := konfluxObject("Component", "tenant", "ui", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{
+				"git": map[string]interface{}{"url": "https://github.com/org/ui"},
+			},
+		},
+		"status": map[string]interface{}{
+			"lastBuiltCommit":   "fedcba9",
+			"lastPromotedImage": "quay.io/org/ui@sha256:456",
+		},
+	})
+	client := newFakeDynamicClient(snap, component, rebuilt)
+
+	result, err := FetchSnapshot(context.Background(), client, "tenant", "app-snapshot")
+	if err != nil {
+		t.Fatalf("FetchSnapshot failed: %v", err)
+	}
+	if len(result.Components) != 3 {
+		t.Fatalf("got %d components, want 3", len(result.Components))
+	}
+	if result.Components[0].ContainerImage != "quay.io/org/api@sha256:abc" {
+		t.Errorf("unexpected image %q", result.Components[0].ContainerImage)
+	}
+	if result.Components[0].Source.Git.Revision != "1234567" {
+		t.Errorf("unexpected revision %q", result.Components[0].Source.Git.Revision)
+	}
+
+	result.ResolveComponentSources(context.Background(), client, "tenant")
+
+	if got := result.Components[1].Source.Git; got.URL != "https://github.com/org/worker" || got.Revision != "89abcde" {
+		t.Errorf("unexpected resolved source %+v", got)
+	}
+	// The last built commit of a rebuilt component is not the snapshot's
+	if got := result.Components[2].Source.Git; got.URL != "https://github.com/org/ui" || got.Revision != "" {
+		t.Errorf("unexpected source of rebuilt component %+v", got)
+	}
+	// Sources from the snapshot are kept
+	if got := result.Components[0].Source.Git; got.URL != "https://github.com/org/api" || got.Revision != "1234567" {
+		t.Errorf("snapshot source was overwritten: %+v", got)
+	}
+}
+
+func TestFetchSnapshot_NotFound(t *testing.T) {
+	client := newFakeDynamicClient()
+
+	if _, err := FetchSnapshot(context.Background(), client, "tenant", "missing"); err == nil {
+		t.Error("expected error for missing snapshot")
+	}
+}
@@ -0,0 +1,266 @@
+package trace
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/konflux-ci/coverport/cli/internal/processor"
+)
+
+// fileHits holds the lines and functions of a single file executed by a test
+type fileHits struct {
+	lines     map[int]bool
+	functions map[string]bool
+}
+
+// hits maps source file paths to what a test executed in them
+type hits map[string]*fileHits
+
+// file returns the entry for the given path, creating it if needed
+func (h hits) file(path string) *fileHits {
+	fh, ok := h[path]
+	if !ok {
+		fh = &fileHits{lines: make(map[int]bool), functions: make(map[string]bool)}
+		h[path] = fh
+	}
+	return fh
+}
+
+// loadHits reads the coverage stored in a per-test directory.
+// Text profiles (Go coverage.out) and LCOV files are used when present,
+// otherwise raw Go and NYC coverage data are converted.
+func loadHits(ctx context.Context, dir string) (hits, error) {
+	for _, name := range []string{"coverage_filtered.out", "coverage.out"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return parseGoProfileFile(filepath.Join(dir, name))
+		}
+	}
+
+	if lcovFiles, _ := filepath.Glob(filepath.Join(dir, "*.lcov")); len(lcovFiles) > 0 {
+		return parseLCOVFile(lcovFiles[0])
+	}
+
+	format, err := processor.DetectFormat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case processor.FormatGo:
+		return convertGoCoverage(ctx, dir)
+	case processor.FormatNYC:
+		data, err := processor.LoadNYCCoverage(dir)
+		if err != nil {
+			return nil, err
+		}
+		return hitsFromNYC(data), nil
+	default:
+		return nil, fmt.Errorf("unsupported coverage format for tracing: %s", format)
+	}
+}
+
+// convertGoCoverage converts binary Go coverage data to a text profile and parses it
+func convertGoCoverage(ctx context.Context, dir string) (hits, error) {
+	goPath, err := exec.LookPath("go")
+	if err != nil {
+		return nil, fmt.Errorf("go toolchain not found (required for Go coverage): %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp("", "coverport-trace-*.out")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	cmd := exec.CommandContext(ctx, goPath, "tool", "covdata", "textfmt", "-i="+dir, "-o="+tmpFile.Name())
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("convert coverage: %w\nOutput: %s", err, output)
+	}
+
+	return parseGoProfileFile(tmpFile.Name())
+}
+
+// parseGoProfileFile parses a Go text coverage profile from a file
+func parseGoProfileFile(path string) (hits, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open coverage profile: %w", err)
+	}
+	defer f.Close()
+
+	return parseGoProfile(f)
+}
+
+// parseGoProfile parses a Go text coverage profile.
+// Line format: path/to/file.go:startLine.startCol,endLine.endCol numStmts count
+func parseGoProfile(r io.Reader) (hits, error) {
+	result := make(hits)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		idx := strings.LastIndex(line, ":")
+		if idx == -1 {
+			continue
+		}
+		filePath := line[:idx]
+
+		fields := strings.Fields(line[idx+1:])
+		if len(fields) != 3 {
+			continue
+		}
+
+		count, err := strconv.Atoi(fields[2])
+		if err != nil || count == 0 {
+			continue
+		}
+
+		startLine, endLine, ok := parseBlockLines(fields[0])
+		if !ok {
+			continue
+		}
+
+		fh := result.file(filePath)
+		for l := startLine; l <= endLine; l++ {
+			fh.lines[l] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read coverage profile: %w", err)
+	}
+
+	return result, nil
+}
+
+// parseBlockLines extracts the start and end line from a block like "10.2,12.16"
+func parseBlockLines(block string) (int, int, bool) {
+	start, end, found := strings.Cut(block, ",")
+	if !found {
+		return 0, 0, false
+	}
+
+	startLine, err := strconv.Atoi(strings.SplitN(start, ".", 2)[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	endLine, err := strconv.Atoi(strings.SplitN(end, ".", 2)[0])
+	if err != nil || endLine < startLine {
+		return 0, 0, false
+	}
+
+	return startLine, endLine, true
+}
+
+// parseLCOVFile parses an LCOV tracefile from a file
+func parseLCOVFile(path string) (hits, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open LCOV file: %w", err)
+	}
+	defer f.Close()
+
+	return parseLCOV(f)
+}
+
+// parseLCOV parses an LCOV tracefile, using DA records for lines and
+// FNDA records for functions
+func parseLCOV(r io.Reader) (hits, error) {
+	result := make(hits)
+	var current *fileHits
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			current = result.file(strings.TrimPrefix(line, "SF:"))
+		case line == "end_of_record":
+			current = nil
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "DA:"):
+			parts := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(parts) < 2 {
+				continue
+			}
+			lineNum, err1 := strconv.Atoi(parts[0])
+			count, err2 := strconv.Atoi(parts[1])
+			if err1 == nil && err2 == nil && count > 0 {
+				current.lines[lineNum] = true
+			}
+		case strings.HasPrefix(line, "FNDA:"):
+			countStr, name, found := strings.Cut(strings.TrimPrefix(line, "FNDA:"), ",")
+			if !found {
+				continue
+			}
+			if count, err := strconv.Atoi(countStr); err == nil && count > 0 {
+				current.functions[name] = true
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read LCOV file: %w", err)
+	}
+
+	// Drop files without any executed lines or functions
+	for path, fh := range result {
+		if len(fh.lines) == 0 && len(fh.functions) == 0 {
+			delete(result, path)
+		}
+	}
+
+	return result, nil
+}
+
+// hitsFromNYC converts Istanbul coverage into executed lines and functions
+func hitsFromNYC(data processor.NYCCoverageData) hits {
+	result := make(hits)
+
+	for filePath, fc := range data {
+		if fc == nil {
+			continue
+		}
+
+		var fh *fileHits
+		for id, count := range fc.S {
+			loc, ok := fc.StatementMap[id]
+			if !ok || count == 0 {
+				continue
+			}
+			if fh == nil {
+				fh = result.file(filePath)
+			}
+			for l := loc.Start.Line; l <= loc.End.Line; l++ {
+				fh.lines[l] = true
+			}
+		}
+
+		for id, count := range fc.F {
+			fn, ok := fc.FnMap[id]
+			if !ok || count == 0 || fn.Name == "" {
+				continue
+			}
+			if fh == nil {
+				fh = result.file(filePath)
+			}
+			fh.functions[fn.Name] = true
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,134 @@
+package trace
+
+import (
+	"sort"
+)
+
+// Change describes the changed lines of a file, as reported by a diff.
+// Lines must be numbered like the code the index was built from.
+type Change struct {
+	File  string
+	Lines []int
+}
+
+// Selection is the result of test impact analysis
+type Selection struct {
+	Tests          []string `json:"tests"`           // Minimal set of tests covering the changes
+	Impacted       []string `json:"impacted"`        // All tests executing changed code
+	UncoveredFiles []string `json:"uncovered_files"` // Changed files without coverage data in the index
+}
+
+// SelectTests selects the tests impacted by the given changes.
+//
+// Every changed line executed by at least one test must be covered by a
+// selected test. When none of a file's changed lines were executed (e.g.
+// new code in an existing file), the tests touching that file are used
+// instead. Files absent from the index are reported as uncovered.
+// The minimal set is computed greedily, so it is small but not guaranteed
+// to be optimal.
+func (idx *Index) SelectTests(changes []Change) Selection {
+	var requirements [][]int
+	var uncovered []string
+	impacted := make(map[int]bool)
+
+	for _, change := range changes {
+		path, ok := idx.ResolveFile(change.File)
+		if !ok {
+			uncovered = append(uncovered, change.File)
+			continue
+		}
+		fc := idx.Files[path]
+
+		executed := false
+		for _, line := range change.Lines {
+			if ids := fc.Lines[line]; len(ids) > 0 {
+				requirements = append(requirements, ids)
+				executed = true
+			}
+		}
+
+		if !executed {
+			var ids []int
+			for _, lineIDs := range fc.Lines {
+				for _, id := range lineIDs {
+					ids = appendID(ids, id)
+				}
+			}
+			if len(ids) > 0 {
+				requirements = append(requirements, ids)
+			}
+		}
+	}
+
+	for _, ids := range requirements {
+		for _, id := range ids {
+			impacted[id] = true
+		}
+	}
+
+	impactedIDs := make([]int, 0, len(impacted))
+	for id := range impacted {
+		impactedIDs = append(impactedIDs, id)
+	}
+	sort.Ints(impactedIDs)
+
+	sort.Strings(uncovered)
+
+	return Selection{
+		Tests:          idx.testNames(minimalCover(requirements)),
+		Impacted:       idx.testNames(impactedIDs),
+		UncoveredFiles: nonNilStrings(uncovered),
+	}
+}
+
+// minimalCover greedily picks tests until every requirement (a list of
+// tests, any of which satisfies it) is satisfied
+func minimalCover(requirements [][]int) []int {
+	satisfied := make([]bool, len(requirements))
+	remaining := len(requirements)
+	var selected []int
+
+	for remaining > 0 {
+		counts := make(map[int]int)
+		for i, ids := range requirements {
+			if satisfied[i] {
+				continue
+			}
+			for _, id := range ids {
+				counts[id]++
+			}
+		}
+
+		best, bestCount := -1, 0
+		for id, count := range counts {
+			if count > bestCount || (count == bestCount && id < best) {
+				best, bestCount = id, count
+			}
+		}
+
+		selected = append(selected, best)
+		for i, ids := range requirements {
+			if !satisfied[i] && containsSorted(ids, best) {
+				satisfied[i] = true
+				remaining--
+			}
+		}
+	}
+
+	sort.Ints(selected)
+	return selected
+}
+
+// containsSorted reports whether a sorted list of IDs holds id
+func containsSorted(ids []int, id int) bool {
+	i := sort.SearchInts(ids, id)
+	return i < len(ids) && ids[i] == id
+}
+
+// nonNilStrings returns an empty slice instead of nil so JSON output is [] rather than null
+func nonNilStrings(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
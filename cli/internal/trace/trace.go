@@ -0,0 +1,262 @@
+// Package trace builds a test-to-code traceability index from per-test coverage.
+//
+// The index maps each covered source line and function to the tests that
+// executed it, so it can answer "which tests cover this file:line" and
+// "which files does this test touch".
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/konflux-ci/coverport/cli/internal/manifest"
+)
+
+// Version of the index format
+const Version = "1.0"
+
+// DefaultIndexFile is the file name used for the index inside a coverage directory
+const DefaultIndexFile = "trace-index.json"
+
+// Index maps source lines and functions to the tests that executed them
+type Index struct {
+	Version     string                   `json:"version"`
+	Collection  string                   `json:"collection"`
+	GeneratedAt string                   `json:"generated_at"`
+	Tests       []TestEntry              `json:"tests"`
+	Files       map[string]*FileCoverage `json:"files"`
+
+	testIDs map[string]int
+}
+
+// TestEntry describes a test in the index. Files reference tests by ID.
+type TestEntry struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	Components []string `json:"components"`
+	Failed     bool     `json:"failed,omitempty"`
+}
+
+// FileCoverage holds the tests that executed each line and function of a file
+type FileCoverage struct {
+	Lines     map[int][]int    `json:"lines"`
+	Functions map[string][]int `json:"functions,omitempty"`
+}
+
+// NewIndex creates an empty index for the given collection
+func NewIndex(collection string) *Index {
+	return &Index{
+		Version:     Version,
+		Collection:  collection,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Tests:       []TestEntry{},
+		Files:       make(map[string]*FileCoverage),
+		testIDs:     make(map[string]int),
+	}
+}
+
+// AddTestCoverage reads the coverage collected for a test and adds it to the index.
+// coverageDir is the directory holding the test's coverage data.
+func (idx *Index) AddTestCoverage(ctx context.Context, test manifest.TestInfo, coverageDir string) error {
+	fileHits, err := loadHits(ctx, coverageDir)
+	if err != nil {
+		return fmt.Errorf("load coverage for %s: %w", test.Name, err)
+	}
+
+	id := idx.testID(test)
+	for path, fh := range fileHits {
+		fc, ok := idx.Files[path]
+		if !ok {
+			fc = &FileCoverage{Lines: make(map[int][]int), Functions: make(map[string][]int)}
+			idx.Files[path] = fc
+		}
+		for line := range fh.lines {
+			fc.Lines[line] = appendID(fc.Lines[line], id)
+		}
+		for fn := range fh.functions {
+			fc.Functions[fn] = appendID(fc.Functions[fn], id)
+		}
+	}
+
+	return nil
+}
+
+// testID returns the ID of the test, registering it on first use.
+// A test collected from several components maps to a single entry.
+func (idx *Index) testID(test manifest.TestInfo) int {
+	if idx.testIDs == nil {
+		idx.rebuildTestIDs()
+	}
+
+	id, ok := idx.testIDs[test.Name]
+	if !ok {
+		id = len(idx.Tests)
+		idx.Tests = append(idx.Tests, TestEntry{ID: id, Name: test.Name})
+		idx.testIDs[test.Name] = id
+	}
+
+	entry := &idx.Tests[id]
+	if test.Component != "" && !slices.Contains(entry.Components, test.Component) {
+		entry.Components = append(entry.Components, test.Component)
+	}
+	if test.Failed {
+		entry.Failed = true
+	}
+
+	return id
+}
+
+// rebuildTestIDs restores the test name lookup after loading an index
+func (idx *Index) rebuildTestIDs() {
+	idx.testIDs = make(map[string]int, len(idx.Tests))
+	for i, test := range idx.Tests {
+		idx.testIDs[test.Name] = i
+	}
+}
+
+// ResolveFile finds the indexed path matching the given file.
+// An exact match is preferred, otherwise the longest indexed path ending
+// with the file (on a path boundary) is used, so repository-relative paths
+// match module-qualified Go paths.
+func (idx *Index) ResolveFile(file string) (string, bool) {
+	if _, ok := idx.Files[file]; ok {
+		return file, true
+	}
+
+	file = strings.TrimPrefix(file, "./")
+	best := ""
+	for path := range idx.Files {
+		if path == file || strings.HasSuffix(path, "/"+file) || strings.HasSuffix(file, "/"+path) {
+			if len(path) > len(best) || (len(path) == len(best) && path < best) {
+				best = path
+			}
+		}
+	}
+
+	return best, best != ""
+}
+
+// TestsForLine returns the names of the tests that executed the given line
+func (idx *Index) TestsForLine(file string, line int) []string {
+	path, ok := idx.ResolveFile(file)
+	if !ok {
+		return nil
+	}
+	return idx.testNames(idx.Files[path].Lines[line])
+}
+
+// TestsForFile returns the names of the tests that executed any line of the file
+func (idx *Index) TestsForFile(file string) []string {
+	path, ok := idx.ResolveFile(file)
+	if !ok {
+		return nil
+	}
+
+	var ids []int
+	for _, lineIDs := range idx.Files[path].Lines {
+		for _, id := range lineIDs {
+			ids = appendID(ids, id)
+		}
+	}
+	return idx.testNames(ids)
+}
+
+// FilesForTest returns the files touched by the named test, sorted by path
+func (idx *Index) FilesForTest(testName string) []string {
+	if idx.testIDs == nil {
+		idx.rebuildTestIDs()
+	}
+
+	id, ok := idx.testIDs[testName]
+	if !ok {
+		return nil
+	}
+
+	var files []string
+	for path, fc := range idx.Files {
+		if fileHasTest(fc, id) {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// testNames converts test IDs into sorted test names
+func (idx *Index) testNames(ids []int) []string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id >= 0 && id < len(idx.Tests) {
+			names = append(names, idx.Tests[id].Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save writes the index to a JSON file
+func (idx *Index) Save(path string) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal index: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads an index from a JSON file
+func Load(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("unmarshal index: %w", err)
+	}
+
+	if idx.Files == nil {
+		idx.Files = make(map[string]*FileCoverage)
+	}
+	idx.rebuildTestIDs()
+
+	return &idx, nil
+}
+
+// fileHasTest reports whether the test executed any line of the file
+func fileHasTest(fc *FileCoverage, id int) bool {
+	for _, ids := range fc.Lines {
+		if slices.Contains(ids, id) {
+			return true
+		}
+	}
+	for _, ids := range fc.Functions {
+		if slices.Contains(ids, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendID adds id to a sorted list of IDs if not already present
+func appendID(ids []int, id int) []int {
+	i := sort.SearchInts(ids, id)
+	if i < len(ids) && ids[i] == id {
+		return ids
+	}
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	return ids
+}
@@ -0,0 +1,308 @@
+package trace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/konflux-ci/coverport/cli/internal/manifest"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func TestParseGoProfile(t *testing.T) {
+	profile := `mode: atomic
+github.com/org/app/pkg/users/users.go:10.2,12.16 2 1
+github.com/org/app/pkg/users/users.go:14.2,14.20 1 0
+github.com/org/app/main.go:5.13,6.2 1 3
+`
+
+	result, err := parseGoProfile(strings.NewReader(profile))
+	if err != nil {
+		t.Fatalf("parseGoProfile failed: %v", err)
+	}
+
+	users := result["github.com/org/app/pkg/users/users.go"]
+	if users == nil {
+		t.Fatal("Expected users.go in result")
+	}
+	for _, line := range []int{10, 11, 12} {
+		if !users.lines[line] {
+			t.Errorf("Expected line %d to be covered", line)
+		}
+	}
+	if users.lines[14] {
+		t.Error("Expected line 14 (count 0) not to be covered")
+	}
+
+	if main := result["github.com/org/app/main.go"]; main == nil || !main.lines[5] || !main.lines[6] {
+		t.Error("Expected main.go lines 5-6 to be covered")
+	}
+}
+
+func TestParseLCOV(t *testing.T) {
+	lcov := `TN:
+SF:src/api.js
+FN:3,handler
+FN:9,unused
+FNDA:2,handler
+FNDA:0,unused
+DA:3,2
+DA:4,2
+DA:9,0
+end_of_record
+SF:src/untouched.js
+DA:1,0
+end_of_record
+`
+
+	result, err := parseLCOV(strings.NewReader(lcov))
+	if err != nil {
+		t.Fatalf("parseLCOV failed: %v", err)
+	}
+
+	if _, ok := result["src/untouched.js"]; ok {
+		t.Error("Expected file without hits to be dropped")
+	}
+
+	api := result["src/api.js"]
+	if api == nil {
+		t.Fatal("Expected src/api.js in result")
+	}
+	if !api.lines[3] || !api.lines[4] || api.lines[9] {
+		t.Errorf("Unexpected covered lines: %v", api.lines)
+	}
+	if !api.functions["handler"] || api.functions["unused"] {
+		t.Errorf("Unexpected covered functions: %v", api.functions)
+	}
+}
+
+func TestAddTestCoverage_NYC(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile(t, filepath.Join(tmpDir, "coverage-final.json"), `{
+  "/app/src/api.js": {
+    "path": "/app/src/api.js",
+    "statementMap": {
+      "0": {"start": {"line": 3, "column": 0}, "end": {"line": 4, "column": 10}},
+      "1": {"start": {"line": 9, "column": 0}, "end": {"line": 9, "column": 10}}
+    },
+    "fnMap": {
+      "0": {"name": "handler", "line": 3}
+    },
+    "branchMap": {},
+    "s": {"0": 1, "1": 0},
+    "f": {"0": 1},
+    "b": {}
+  }
+}`)
+
+	idx := NewIndex("e2e")
+	if err := idx.AddTestCoverage(context.Background(), manifest.TestInfo{Name: "serves api", Component: "frontend"}, tmpDir); err != nil {
+		t.Fatalf("AddTestCoverage failed: %v", err)
+	}
+
+	if got := idx.TestsForLine("src/api.js", 4); !reflect.DeepEqual(got, []string{"serves api"}) {
+		t.Errorf("TestsForLine(4) = %v, want [serves api]", got)
+	}
+	if got := idx.TestsForLine("src/api.js", 9); len(got) != 0 {
+		t.Errorf("TestsForLine(9) = %v, want none", got)
+	}
+	if got := idx.Files["/app/src/api.js"].Functions["handler"]; !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("Functions[handler] = %v, want [0]", got)
+	}
+}
+
+func TestIndexQueries(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile(t, filepath.Join(tmpDir, "create", "coverage.out"), `mode: atomic
+github.com/org/app/pkg/users/users.go:10.2,12.16 2 1
+github.com/org/app/main.go:5.13,6.2 1 1
+`)
+	writeFile(t, filepath.Join(tmpDir, "delete", "coverage.out"), `mode: atomic
+github.com/org/app/pkg/users/users.go:12.2,20.3 4 1
+`)
+	writeFile(t, filepath.Join(tmpDir, "frontend", "app.lcov"), `SF:src/api.js
+DA:3,1
+end_of_record
+`)
+
+	idx := NewIndex("e2e")
+	tests := []struct {
+		info manifest.TestInfo
+		dir  string
+	}{
+		{manifest.TestInfo{Name: "creates a user", Component: "backend"}, "create"},
+		{manifest.TestInfo{Name: "deletes a user", Component: "backend", Failed: true}, "delete"},
+		{manifest.TestInfo{Name: "creates a user", Component: "frontend"}, "frontend"},
+	}
+	for _, tt := range tests {
+		if err := idx.AddTestCoverage(context.Background(), tt.info, filepath.Join(tmpDir, tt.dir)); err != nil {
+			t.Fatalf("AddTestCoverage(%s) failed: %v", tt.dir, err)
+		}
+	}
+
+	if len(idx.Tests) != 2 {
+		t.Fatalf("Expected 2 tests, got %d", len(idx.Tests))
+	}
+	if !reflect.DeepEqual(idx.Tests[0].Components, []string{"backend", "frontend"}) {
+		t.Errorf("Expected components [backend frontend], got %v", idx.Tests[0].Components)
+	}
+	if !idx.Tests[1].Failed {
+		t.Error("Expected second test to be marked failed")
+	}
+
+	lineTests := []struct {
+		file string
+		line int
+		want []string
+	}{
+		{"github.com/org/app/pkg/users/users.go", 10, []string{"creates a user"}},
+		{"pkg/users/users.go", 12, []string{"creates a user", "deletes a user"}},
+		{"./pkg/users/users.go", 18, []string{"deletes a user"}},
+		{"pkg/users/users.go", 99, []string{}},
+		{"sers.go", 10, nil},
+	}
+	for _, tt := range lineTests {
+		got := idx.TestsForLine(tt.file, tt.line)
+		if len(got) != len(tt.want) || (len(tt.want) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("TestsForLine(%s, %d) = %v, want %v", tt.file, tt.line, got, tt.want)
+		}
+	}
+
+	if got := idx.TestsForFile("main.go"); !reflect.DeepEqual(got, []string{"creates a user"}) {
+		t.Errorf("TestsForFile(main.go) = %v, want [creates a user]", got)
+	}
+
+	wantFiles := []string{"github.com/org/app/main.go", "github.com/org/app/pkg/users/users.go", "src/api.js"}
+	if got := idx.FilesForTest("creates a user"); !reflect.DeepEqual(got, wantFiles) {
+		t.Errorf("FilesForTest = %v, want %v", got, wantFiles)
+	}
+	if got := idx.FilesForTest("unknown"); got != nil {
+		t.Errorf("FilesForTest(unknown) = %v, want nil", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile(t, filepath.Join(tmpDir, "t1", "coverage.out"), "mode: set\nexample.com/app/a.go:1.1,2.2 1 1\n")
+
+	idx := NewIndex("e2e")
+	if err := idx.AddTestCoverage(context.Background(), manifest.TestInfo{Name: "t1"}, filepath.Join(tmpDir, "t1")); err != nil {
+		t.Fatalf("AddTestCoverage failed: %v", err)
+	}
+
+	indexPath := filepath.Join(tmpDir, DefaultIndexFile)
+	if err := idx.Save(indexPath); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(indexPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if loaded.Collection != "e2e" {
+		t.Errorf("Expected collection e2e, got %s", loaded.Collection)
+	}
+	if got := loaded.TestsForLine("a.go", 2); !reflect.DeepEqual(got, []string{"t1"}) {
+		t.Errorf("TestsForLine after load = %v, want [t1]", got)
+	}
+	if got := loaded.FilesForTest("t1"); !reflect.DeepEqual(got, []string{"example.com/app/a.go"}) {
+		t.Errorf("FilesForTest after load = %v", got)
+	}
+}
+
+func TestAddTestCoverage_Unsupported(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile(t, filepath.Join(tmpDir, ".coverage"), "sqlite")
+
+	idx := NewIndex("e2e")
+	if err := idx.AddTestCoverage(context.Background(), manifest.TestInfo{Name: "py"}, tmpDir); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+	if len(idx.Tests) != 0 {
+		t.Errorf("Expected no tests registered on failure, got %d", len(idx.Tests))
+	}
+}
+
+func TestSelectTests(t *testing.T) {
+	idx := NewIndex("e2e")
+	for _, name := range []string{"creates a user", "deletes a user", "lists users", "smoke"} {
+		idx.testID(manifest.TestInfo{Name: name})
+	}
+	idx.Files["github.com/org/app/pkg/users/users.go"] = &FileCoverage{Lines: map[int][]int{
+		10: {0, 3},
+		11: {0, 3},
+		20: {1, 3},
+		30: {2},
+	}}
+	idx.Files["github.com/org/app/pkg/auth/auth.go"] = &FileCoverage{Lines: map[int][]int{
+		5: {1},
+	}}
+
+	tests := []struct {
+		name          string
+		changes       []Change
+		wantTests     []string
+		wantImpacted  []string
+		wantUncovered []string
+	}{
+		{
+			name:          "single line picks all covering tests as impacted",
+			changes:       []Change{{File: "pkg/users/users.go", Lines: []int{10}}},
+			wantTests:     []string{"creates a user"},
+			wantImpacted:  []string{"creates a user", "smoke"},
+			wantUncovered: []string{},
+		},
+		{
+			name:          "minimal set prefers test covering most lines",
+			changes:       []Change{{File: "pkg/users/users.go", Lines: []int{10, 20}}},
+			wantTests:     []string{"smoke"},
+			wantImpacted:  []string{"creates a user", "deletes a user", "smoke"},
+			wantUncovered: []string{},
+		},
+		{
+			name:          "unexecuted lines fall back to tests touching the file",
+			changes:       []Change{{File: "pkg/auth/auth.go", Lines: []int{99}}},
+			wantTests:     []string{"deletes a user"},
+			wantImpacted:  []string{"deletes a user"},
+			wantUncovered: []string{},
+		},
+		{
+			name: "files without coverage data are reported",
+			changes: []Change{
+				{File: "pkg/users/users.go", Lines: []int{30}},
+				{File: "README.md", Lines: []int{1}},
+			},
+			wantTests:     []string{"lists users"},
+			wantImpacted:  []string{"lists users"},
+			wantUncovered: []string{"README.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := idx.SelectTests(tt.changes)
+			if !reflect.DeepEqual(selection.Tests, tt.wantTests) {
+				t.Errorf("Tests = %v, want %v", selection.Tests, tt.wantTests)
+			}
+			if !reflect.DeepEqual(selection.Impacted, tt.wantImpacted) {
+				t.Errorf("Impacted = %v, want %v", selection.Impacted, tt.wantImpacted)
+			}
+			if !reflect.DeepEqual(selection.UncoveredFiles, tt.wantUncovered) {
+				t.Errorf("UncoveredFiles = %v, want %v", selection.UncoveredFiles, tt.wantUncovered)
+			}
+		})
+	}
+}
@@ -1,10 +1,15 @@
 package coverageclient
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,8 +17,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"oras.land/oras-go/v2"
@@ -40,9 +47,16 @@ type CoverageClient struct {
 	namespace       string
 	outputDir       string
 	httpClient      *http.Client
-	defaultFilters  []string // Default file patterns to filter out from coverage
-	sourceDir       string   // Local source directory for path remapping
-	enablePathRemap bool     // Whether to automatically remap container paths
+	defaultFilters  []string       // Default file patterns to filter out from coverage
+	sourceDir       string         // Local source directory for path remapping
+	enablePathRemap bool           // Whether to automatically remap container paths
+	detectedFormat  CoverageFormat // Format of the last saved coverage
+	pathPrefix      string         // Path prefix of the coverage endpoints in pods (e.g. /debug)
+	transport       Transport      // How coverage servers in pods are reached (nil: port-forward)
+
+	serverInfoMu   sync.Mutex
+	serverInfo     map[string]*ServerInfo // Negotiated server info per server base URL
+	protocolWarned bool                   // Whether the newer-protocol warning was printed
 }
 
 // CoverageResponse matches the Go coverage server's response format
@@ -53,6 +67,15 @@ type CoverageResponse struct {
 	CountersData     string `json:"counters_data"`
 	TestName         string `json:"test_name"`
 	Timestamp        int64  `json:"timestamp"`
+
+	// Coverage files of other processes found in the server's GOCOVERDIR
+	CoverDirFiles []CoverDirFile `json:"gocoverdir_files,omitempty"`
+}
+
+// CoverDirFile is a coverage file from the server's GOCOVERDIR
+type CoverDirFile struct {
+	Name string `json:"name"`
+	Data string `json:"data"` // base64 encoded
 }
 
 // PythonCoverageResponse matches the Python coverage server's response format
@@ -64,12 +87,21 @@ type PythonCoverageResponse struct {
 	Message       string `json:"message"`        // Optional message
 }
 
+// NodeCoverageResponse matches the Node.js coverage server's response format
+type NodeCoverageResponse struct {
+	Label        string `json:"label"`
+	Timestamp    string `json:"timestamp"`
+	CoverageData string `json:"coverage_data"` // base64 encoded Istanbul coverage JSON
+	Message      string `json:"message"`       // Optional message
+}
+
 // HealthResponse represents the Python coverage server health check response
 type HealthResponse struct {
 	Status          string `json:"status"`
 	CoverageEnabled bool   `json:"coverage_enabled"`
 	DataDir         string `json:"data_dir"`
 	CoverageFiles   int    `json:"coverage_files"`
+	Format          string `json:"format"` // Set by servers other than Python (e.g. "istanbul")
 }
 
 // SaveResponse represents the Python coverage server save trigger response
@@ -79,6 +111,52 @@ type SaveResponse struct {
 	CoverageFiles int    `json:"coverage_files"`
 }
 
+// ProtocolVersion is the newest coverage server wire protocol the client understands
+const ProtocolVersion = 1
+
+const (
+	// TokenEnvVar holds the bearer token sent to coverage servers by default
+	TokenEnvVar = "COVERAGE_TOKEN"
+
+	// DefaultTokenSecretKey is the Secret key read by TokenFromSecret when none is given
+	DefaultTokenSecretKey = "token"
+)
+
+// ErrUnauthorized is returned when a coverage server rejects the client's token
+var ErrUnauthorized = errors.New("coverage server requires a valid token")
+
+// Optional coverage server features, advertised in /coverage/info and the
+// X-Art-Coverage-Capabilities header
+const (
+	CapabilityReset     = "reset"      // GET /coverage/reset clears the counters
+	CapabilitySave      = "save"       // GET /coverage/save flushes coverage to disk (Python)
+	CapabilityNoMeta    = "nometa"     // GET /coverage?nometa=1 returns the counters only (Go)
+	CapabilityPush      = "push"       // GET /coverage/push dumps coverage to a collector (Go)
+	CapabilityStreaming = "streaming"  // GET /coverage honors Accept: application/x-tar (Go, Python, Rust)
+	CapabilityCoverDir  = "gocoverdir" // GET /coverage includes GOCOVERDIR files of subprocesses (Go)
+)
+
+// ServerInfo describes a coverage server as returned by /coverage/info.
+// Servers predating the endpoint are reported with protocol version 0.
+type ServerInfo struct {
+	Format          string   `json:"format"`
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
+	Pid             int      `json:"pid"`
+	Binary          string   `json:"binary"`
+}
+
+// Legacy reports whether the server predates /coverage/info, in which case
+// its format and features have to be guessed
+func (i *ServerInfo) Legacy() bool {
+	return i.ProtocolVersion == 0
+}
+
+// Has reports whether the server advertises the capability
+func (i *ServerInfo) Has(capability string) bool {
+	return slices.Contains(i.Capabilities, capability)
+}
+
 // CoverageFormat represents the detected coverage format
 type CoverageFormat string
 
@@ -92,8 +170,8 @@ const (
 // RustCoverageResponse matches the Rust coverage server's response format
 type RustCoverageResponse struct {
 	ProfrawFilename string `json:"profraw_filename"`
-	ProfrawData     string `json:"profraw_data"`  // base64 encoded LLVM profraw data
-	ProfrawSize     int    `json:"profraw_size"`  // size in bytes before encoding
+	ProfrawData     string `json:"profraw_data"` // base64 encoded LLVM profraw data
+	ProfrawSize     int    `json:"profraw_size"` // size in bytes before encoding
 	Timestamp       uint64 `json:"timestamp"`
 	CoverageEnabled bool   `json:"coverage_enabled"`
 }
@@ -129,6 +207,12 @@ func NewClient(namespace, outputDir string) (*CoverageClient, error) {
 		return nil, fmt.Errorf("build kubernetes config: %w", err)
 	}
 
+	return NewClientForConfig(config, namespace, outputDir)
+}
+
+// NewClientForConfig creates a new coverage client for the given namespace of
+// the cluster behind config (e.g. a kubeconfig context other than the current one)
+func NewClientForConfig(config *rest.Config, namespace, outputDir string) (*CoverageClient, error) {
 	// Create clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -146,7 +230,7 @@ func NewClient(namespace, outputDir string) (*CoverageClient, error) {
 		cwd = "."
 	}
 
-	return &CoverageClient{
+	client := &CoverageClient{
 		clientset:       clientset,
 		restConfig:      config,
 		namespace:       namespace,
@@ -155,7 +239,9 @@ func NewClient(namespace, outputDir string) (*CoverageClient, error) {
 		defaultFilters:  []string{"coverage_server"}, // Default: filter out the coverage server itself
 		sourceDir:       cwd,
 		enablePathRemap: true, // Default: enable automatic path remapping
-	}, nil
+	}
+	client.SetToken(os.Getenv(TokenEnvVar))
+	return client, nil
 }
 
 // NewClientForURL creates a coverage client for URL-based collection (without Kubernetes)
@@ -171,7 +257,7 @@ func NewClientForURL(outputDir string) (*CoverageClient, error) {
 		cwd = "."
 	}
 
-	return &CoverageClient{
+	client := &CoverageClient{
 		clientset:       nil, // No Kubernetes client needed
 		restConfig:      nil,
 		namespace:       "",
@@ -180,7 +266,9 @@ func NewClientForURL(outputDir string) (*CoverageClient, error) {
 		defaultFilters:  []string{"coverage_server"},
 		sourceDir:       cwd,
 		enablePathRemap: true,
-	}, nil
+	}
+	client.SetToken(os.Getenv(TokenEnvVar))
+	return client, nil
 }
 
 // SetDefaultFilters configures which files to automatically filter from coverage reports
@@ -203,6 +291,131 @@ func (c *CoverageClient) SetPathRemapping(enabled bool) {
 	c.enablePathRemap = enabled
 }
 
+// SetPathPrefix sets the path prefix of the coverage endpoints for pod
+// collection, for servers mounted on the application's own mux (e.g. /debug)
+func (c *CoverageClient) SetPathPrefix(prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	c.pathPrefix = prefix
+}
+
+// LocalCoverageURL returns the coverage URL of a server port-forwarded to localPort
+func (c *CoverageClient) LocalCoverageURL(localPort int) string {
+	return fmt.Sprintf("http://localhost:%d%s/coverage", localPort, c.pathPrefix)
+}
+
+// SetToken sets the bearer token sent to coverage servers (default: the
+// COVERAGE_TOKEN environment variable). An empty token disables authentication.
+func (c *CoverageClient) SetToken(token string) {
+	base := c.httpClient.Transport
+	if bearer, ok := base.(*bearerTransport); ok {
+		base = bearer.base
+	}
+	if token == "" {
+		c.httpClient.Transport = base
+		return
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.httpClient.Transport = &bearerTransport{token: token, base: base}
+}
+
+// TLSOptions configures TLS for HTTPS coverage URLs
+type TLSOptions struct {
+	CAFile             string // PEM bundle of CAs trusted for the server certificate (default: system roots)
+	CertFile           string // Client certificate for servers requiring mTLS
+	KeyFile            string // Client certificate key
+	InsecureSkipVerify bool   // Skip server certificate verification (testing only)
+}
+
+// SetTLSConfig configures TLS for HTTPS coverage URLs
+func (c *CoverageClient) SetTLSConfig(opts TLSOptions) error {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CAFile != "" {
+		caPEM, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in CA bundle %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return fmt.Errorf("both client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if bearer, ok := c.httpClient.Transport.(*bearerTransport); ok {
+		bearer.base = transport
+	} else {
+		c.httpClient.Transport = transport
+	}
+	return nil
+}
+
+// TokenFromSecret reads a coverage server token from a Secret in the client's
+// namespace. The key defaults to DefaultTokenSecretKey.
+func (c *CoverageClient) TokenFromSecret(ctx context.Context, name, key string) (string, error) {
+	if c.clientset == nil {
+		return "", fmt.Errorf("kubernetes client not configured")
+	}
+	if key == "" {
+		key = DefaultTokenSecretKey
+	}
+
+	secret, err := c.clientset.CoreV1().Secrets(c.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("get secret %s/%s: %w", c.namespace, name, err)
+	}
+	data, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %q", c.namespace, name, key)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// bearerTransport adds the coverage server token to every request
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+// bearerToken returns the token set with SetToken, if any
+func (c *CoverageClient) bearerToken() string {
+	if c.httpClient == nil {
+		return ""
+	}
+	if bearer, ok := c.httpClient.Transport.(*bearerTransport); ok {
+		return bearer.token
+	}
+	return ""
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
 // GetPodName discovers a pod name dynamically based on label selector
 // Example: client.GetPodName("app=coverage-demo")
 func (c *CoverageClient) GetPodName(labelSelector string) (string, error) {
@@ -248,29 +461,58 @@ func (c *CoverageClient) CollectCoverageFromPod(ctx context.Context, podName, te
 func (c *CoverageClient) CollectCoverageFromPodWithContainer(ctx context.Context, podName, containerName, testName string, targetPort int) error {
 	fmt.Printf("Collecting coverage from pod %s for test: %s\n", podName, testName)
 
-	// Setup port forwarding
-	localPort, stopChan, err := c.setupPortForward(podName, targetPort)
+	// Make the coverage server reachable (port-forward by default)
+	urls, stop, err := c.connectPod(ctx, podName, []int{targetPort})
 	if err != nil {
-		return fmt.Errorf("setup port forward: %w", err)
+		return fmt.Errorf("connect to pod: %w", err)
+	}
+	defer stop()
+	baseURL := urls[targetPort]
+	fmt.Printf("Connected: %s -> pod:%d\n", baseURL, targetPort)
+	if err := c.waitForServer(ctx, baseURL); err != nil {
+		return fmt.Errorf("connect to pod: %w", err)
 	}
-	defer close(stopChan)
 
-	// Wait a bit for port forward to be ready
-	time.Sleep(2 * time.Second)
+	coverageURL := baseURL + c.pathPrefix + "/coverage"
 
-	// Check health to detect Python coverage and trigger save if needed
-	health, err := c.checkCoverageHealth(localPort)
-	isPython := err == nil && health.CoverageEnabled
+	// Negotiate features with the server; legacy servers are probed via /health
+	info, err := c.GetServerInfo(coverageURL)
+	if err != nil {
+		fmt.Printf("  Warning: Failed to query server info: %v\n", err)
+		info = &ServerInfo{}
+	}
+	health, err := c.checkCoverageHealth(baseURL)
+	var isPython bool
+	if info.Legacy() {
+		isPython = err == nil && health.CoverageEnabled &&
+			(health.Format == "" || ParseCoverageFormat(health.Format) == FormatPython)
+	} else {
+		fmt.Printf("  Coverage server: %s, protocol v%d, capabilities: %s\n",
+			info.Format, info.ProtocolVersion, strings.Join(info.Capabilities, ","))
+		isPython = ParseCoverageFormat(info.Format) == FormatPython
+	}
 
 	if isPython {
 		fmt.Printf("  Detected Python coverage server\n")
-		if health.CoverageFiles == 0 {
+		if !info.Legacy() && !info.Has(CapabilitySave) {
+			fmt.Printf("  Server does not support saving coverage, collecting existing files\n")
+		} else if health == nil || health.CoverageFiles == 0 {
 			fmt.Printf("  No coverage files yet, triggering save...\n")
-			if err := c.triggerPythonCoverageSave(localPort); err != nil {
+			triggered := true
+			if err := c.triggerPythonCoverageSave(baseURL); err != nil {
 				fmt.Printf("  Warning: Failed to trigger save via endpoint: %v\n", err)
 				// Fallback: try exec into pod
 				if execErr := c.triggerCoverageSaveViaExec(ctx, podName, containerName); execErr != nil {
 					fmt.Printf("  Warning: Failed to trigger save via exec: %v\n", execErr)
+					triggered = false
+				}
+			}
+			// Workers write their coverage as they restart
+			if triggered {
+				if count, err := c.waitForCoverageFiles(ctx, baseURL); err != nil {
+					fmt.Printf("  Warning: Coverage files not ready: %v\n", err)
+				} else {
+					fmt.Printf("  %d coverage file(s) written\n", count)
 				}
 			}
 		} else {
@@ -279,13 +521,12 @@ func (c *CoverageClient) CollectCoverageFromPodWithContainer(ctx context.Context
 	}
 
 	// Collect coverage via HTTP
-	coverageURL := fmt.Sprintf("http://localhost:%d/coverage", localPort)
 	if err := c.collectCoverageFromURL(coverageURL, testName); err != nil {
 		return fmt.Errorf("collect coverage: %w", err)
 	}
 
 	// For Python: generate Cobertura XML via exec into the pod
-	if isPython {
+	if isPython && c.detectedFormat == FormatPython {
 		testDir := filepath.Join(c.outputDir, testName)
 		if err := c.generatePythonXMLInPod(ctx, podName, containerName, testDir); err != nil {
 			fmt.Printf("  Warning: Failed to generate XML in pod: %v\n", err)
@@ -301,9 +542,49 @@ func (c *CoverageClient) CollectCoverageFromPodWithContainer(ctx context.Context
 	return nil
 }
 
+// CollectCoverageViaSocket collects coverage from a coverage server listening on
+// a Unix socket inside the container (COVERAGE_SOCKET), for pods that cannot
+// expose a TCP port. The request is made by running curl in the container via
+// exec, so the container image must provide curl.
+func (c *CoverageClient) CollectCoverageViaSocket(ctx context.Context, podName, containerName, socketPath, testName string) error {
+	fmt.Printf("Collecting coverage from pod %s via socket %s for test: %s\n", podName, socketPath, testName)
+
+	if c.restConfig == nil {
+		return fmt.Errorf("kubernetes client not configured")
+	}
+
+	command := c.socketCommand(socketPath, "/coverage?name="+url.QueryEscape(testName))
+	stdout, stderr, err := c.execInPod(ctx, podName, containerName, command)
+	if err != nil {
+		return fmt.Errorf("request coverage over socket: %w (stderr: %s)", err, strings.TrimSpace(stderr))
+	}
+
+	if err := c.saveCoverage([]byte(stdout), testName, ""); err != nil {
+		return fmt.Errorf("collect coverage: %w", err)
+	}
+
+	if err := c.savePodMetadata(ctx, podName, containerName, testName, 0); err != nil {
+		fmt.Printf("Warning: Failed to save pod metadata: %v\n", err)
+	}
+
+	fmt.Printf("Coverage collected successfully for test: %s\n", testName)
+	return nil
+}
+
+// socketCommand returns the curl command requesting path (relative to the path
+// prefix) from the coverage server on the Unix socket
+func (c *CoverageClient) socketCommand(socketPath, path string) []string {
+	command := []string{"curl", "--silent", "--show-error", "--fail", "--unix-socket", socketPath,
+		"-H", "Accept: application/json"}
+	if token := c.bearerToken(); token != "" {
+		command = append(command, "-H", "Authorization: Bearer "+token)
+	}
+	return append(command, "http://localhost"+c.pathPrefix+path)
+}
+
 // checkCoverageHealth checks the coverage server health endpoint to detect format
-func (c *CoverageClient) checkCoverageHealth(localPort int) (*HealthResponse, error) {
-	healthURL := fmt.Sprintf("http://localhost:%d/health", localPort)
+func (c *CoverageClient) checkCoverageHealth(baseURL string) (*HealthResponse, error) {
+	healthURL := baseURL + c.pathPrefix + "/health"
 	resp, err := c.httpClient.Get(healthURL)
 	if err != nil {
 		return nil, fmt.Errorf("health check: %w", err)
@@ -324,8 +605,8 @@ func (c *CoverageClient) checkCoverageHealth(localPort int) (*HealthResponse, er
 }
 
 // triggerPythonCoverageSave hits the /coverage/save endpoint to trigger SIGHUP
-func (c *CoverageClient) triggerPythonCoverageSave(localPort int) error {
-	saveURL := fmt.Sprintf("http://localhost:%d/coverage/save", localPort)
+func (c *CoverageClient) triggerPythonCoverageSave(baseURL string) error {
+	saveURL := baseURL + c.pathPrefix + "/coverage/save"
 	resp, err := c.httpClient.Get(saveURL)
 	if err != nil {
 		return fmt.Errorf("trigger save: %w", err)
@@ -350,15 +631,14 @@ func (c *CoverageClient) triggerPythonCoverageSave(localPort int) error {
 	return nil
 }
 
-// triggerCoverageSaveViaExec sends SIGHUP to PID 1 via kubectl exec (fallback)
+// triggerCoverageSaveViaExec sends SIGHUP to PID 1 via kubectl exec (fallback).
+// It returns once the signal is sent; see waitForCoverageFiles.
 func (c *CoverageClient) triggerCoverageSaveViaExec(ctx context.Context, podName, containerName string) error {
 	cmd := []string{"python", "-c", "import os, signal; os.kill(1, signal.SIGHUP)"}
 	stdout, stderr, err := c.execInPod(ctx, podName, containerName, cmd)
 	if err != nil {
 		return fmt.Errorf("exec HUP: %w (stdout: %s, stderr: %s)", err, stdout, stderr)
 	}
-	// Wait for workers to save coverage
-	time.Sleep(3 * time.Second)
 	return nil
 }
 
@@ -486,6 +766,50 @@ func (c *CoverageClient) CollectCoverageFromURL(coverageURL, testName string) er
 	return c.collectCoverageFromURL(coverageURL, testName)
 }
 
+// ResetCoverageFromURL resets the coverage counters of the server behind the given
+// coverage URL (e.g. http://localhost:53700/coverage) via its /reset endpoint
+func (c *CoverageClient) ResetCoverageFromURL(coverageURL string) error {
+	if info, err := c.GetServerInfo(coverageURL); err == nil && !info.Legacy() && !info.Has(CapabilityReset) {
+		return fmt.Errorf("coverage server (%s) does not support resetting counters", info.Format)
+	}
+
+	resetURL := strings.TrimSuffix(coverageURL, "/") + "/reset"
+	resp, err := c.httpClient.Get(resetURL)
+	if err != nil {
+		return fmt.Errorf("send reset request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("reset endpoint returned 401: %w", ErrUnauthorized)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("reset endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// PortForward forwards a local port to the target port of the given pod and keeps
+// it open until the returned stop function is called. This allows callers to issue
+// several requests (e.g. reset and collect per test) over a single port-forward.
+func (c *CoverageClient) PortForward(podName string, targetPort int) (int, func(), error) {
+	if c.restConfig == nil {
+		return 0, nil, fmt.Errorf("kubernetes client not configured")
+	}
+
+	localPort, stopChan, err := c.setupPortForward(podName, targetPort)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return localPort, func() {
+		close(stopChan)
+		c.forgetServerInfo(fmt.Sprintf("http://localhost:%d%s", localPort, c.pathPrefix))
+	}, nil
+}
+
 // savePodMetadata retrieves pod information and saves it to metadata.json
 func (c *CoverageClient) savePodMetadata(ctx context.Context, podName, containerName, testName string, targetPort int) error {
 	// Get pod details
@@ -633,32 +957,228 @@ func (c *CoverageClient) detectContainerByPort(ctx context.Context, podName stri
 // in the coverage range (53700..53749, plus legacy 9095) is listening.
 // Returns the detected port or an error if none found.
 func (c *CoverageClient) DetectCoveragePort(ctx context.Context, podName, containerName string) (int, error) {
+	ports, err := c.DetectCoveragePorts(ctx, podName, containerName)
+	if err != nil {
+		return 0, err
+	}
+	return ports[0], nil
+}
+
+// DetectCoveragePorts execs into the specified container and returns every
+// listening port in the coverage range. Containers in a pod share the network
+// namespace, so this includes the ports of the other containers.
+func (c *CoverageClient) DetectCoveragePorts(ctx context.Context, podName, containerName string) ([]int, error) {
 	if c.clientset == nil || c.restConfig == nil {
-		return 0, fmt.Errorf("kubernetes client not configured")
+		return nil, fmt.Errorf("kubernetes client not configured")
 	}
 
 	// Use bash /dev/tcp probe — works in most containers without extra tools.
-	// Prints the first listening port and exits.
+	// Prints every listening port.
 	cmd := []string{"bash", "-c",
-		`for p in $(seq 53700 53749) 9095; do (echo >/dev/tcp/localhost/$p) 2>/dev/null && echo $p && exit 0; done; exit 1`,
+		`found=1; for p in $(seq 53700 53749) 9095; do (echo >/dev/tcp/localhost/$p) 2>/dev/null && echo $p && found=0; done; exit $found`,
 	}
 
 	stdout, _, err := c.execInPod(ctx, podName, containerName, cmd)
 	if err != nil {
-		return 0, fmt.Errorf("exec port detection: %w", err)
+		return nil, fmt.Errorf("exec port detection: %w", err)
 	}
 
-	portStr := strings.TrimSpace(stdout)
-	if portStr == "" {
-		return 0, fmt.Errorf("no coverage port detected in container %s", containerName)
+	ports, err := parseDetectedPorts(stdout)
+	if err != nil {
+		return nil, err
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no coverage port detected in container %s", containerName)
+	}
+	return ports, nil
+}
+
+// parseDetectedPorts parses the port detection output (one port per line)
+func parseDetectedPorts(output string) ([]int, error) {
+	var ports []int
+	for _, field := range strings.Fields(output) {
+		port, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("parse detected port %q: %w", field, err)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// CoverageServer is a coverage server found in a pod. Pid and Binary come from
+// the server's identity headers and are empty for servers that don't send them.
+type CoverageServer struct {
+	Port   int
+	Pid    int
+	Binary string
+}
+
+// DirName returns the subdirectory name for the server's coverage, keyed by
+// binary and PID (or by port when the server doesn't identify itself)
+func (s CoverageServer) DirName() string {
+	binary := filepath.Base(s.Binary)
+	if s.Pid == 0 || binary == "." || binary == ".." || binary == string(filepath.Separator) {
+		return fmt.Sprintf("port-%d", s.Port)
+	}
+	return fmt.Sprintf("%s-%d", binary, s.Pid)
+}
+
+// serverFromHeaders reads the identity headers of a coverage server response
+func serverFromHeaders(port int, header http.Header) CoverageServer {
+	server := CoverageServer{Port: port, Binary: header.Get("X-Art-Coverage-Binary")}
+	server.Pid, _ = strconv.Atoi(header.Get("X-Art-Coverage-Pid"))
+	return server
+}
+
+// PortAnnotation lists the coverage server port(s) of a pod, comma-separated
+const PortAnnotation = "coverport.io/port"
+
+// coveragePortName is the prefix of container port names that serve coverage
+// (e.g. "coverage", "coverage-2")
+const coveragePortName = "coverage"
+
+// legacyCoveragePort is the port of coverage servers predating 53700
+const legacyCoveragePort = 9095
+
+// DefaultCoveragePorts returns the ports coverage servers listen on: 53700..53749
+// (a Go server moves to the next port when one is taken) and the legacy 9095
+func DefaultCoveragePorts() []int {
+	ports := make([]int, 0, 51)
+	for port := 53700; port <= 53749; port++ {
+		ports = append(ports, port)
+	}
+	return append(ports, legacyCoveragePort)
+}
+
+// DiscoverCoverageServers returns every coverage server listening in the pod.
+// Ports come, in order of preference, from the coverport.io/port annotation,
+// from container ports named "coverage*", from HEAD probes of the default
+// ports through a port-forward, and last from exec'ing into a container
+// (preferredContainer first), which needs bash and pods/exec permission.
+func (c *CoverageClient) DiscoverCoverageServers(ctx context.Context, podName, preferredContainer string) ([]CoverageServer, error) {
+	if c.clientset == nil || c.restConfig == nil {
+		return nil, fmt.Errorf("kubernetes client not configured")
 	}
 
-	port, err := strconv.Atoi(portStr)
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
-		return 0, fmt.Errorf("parse detected port %q: %w", portStr, err)
+		return nil, fmt.Errorf("get pod: %w", err)
 	}
 
-	return port, nil
+	declared, err := declaredCoveragePorts(pod)
+	if err != nil {
+		fmt.Printf("  Warning: %v\n", err)
+	}
+	if len(declared) > 0 {
+		return c.probeServers(ctx, podName, declared, false)
+	}
+
+	servers, err := c.probeServers(ctx, podName, DefaultCoveragePorts(), true)
+	if err == nil && len(servers) > 0 {
+		return servers, nil
+	}
+	if err != nil {
+		fmt.Printf("  Warning: Port probing failed: %v\n", err)
+	}
+
+	containers := []string{}
+	if preferredContainer != "" {
+		containers = append(containers, preferredContainer)
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name != preferredContainer {
+			containers = append(containers, container.Name)
+		}
+	}
+
+	var ports []int
+	for _, container := range containers {
+		if ports, err = c.DetectCoveragePorts(ctx, podName, container); err == nil {
+			break
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("detect coverage ports: %w", err)
+	}
+	return c.probeServers(ctx, podName, ports, false)
+}
+
+// declaredCoveragePorts returns the ports given by the coverport.io/port
+// annotation, or else the container ports named "coverage*"
+func declaredCoveragePorts(pod *corev1.Pod) ([]int, error) {
+	if value, ok := pod.Annotations[PortAnnotation]; ok {
+		var ports []int
+		for _, field := range strings.Split(value, ",") {
+			port, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || port <= 0 || port > 65535 {
+				return nil, fmt.Errorf("invalid %s annotation %q", PortAnnotation, value)
+			}
+			ports = append(ports, port)
+		}
+		return ports, nil
+	}
+
+	var ports []int
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if strings.HasPrefix(port.Name, coveragePortName) {
+				ports = append(ports, int(port.ContainerPort))
+			}
+		}
+	}
+	return ports, nil
+}
+
+// probeServers connects to the pod and identifies the coverage server on
+// each port with a HEAD request. When scanning, only responses carrying the
+// X-Art-Coverage-Server header count, and probing stops at the first port
+// nothing listens on, since servers take consecutive ports. Otherwise every
+// port is returned, identified where possible. If the first port does not
+// answer, it is polled for up to probeReadyTimeout before giving up on it.
+func (c *CoverageClient) probeServers(ctx context.Context, podName string, ports []int, scan bool) ([]CoverageServer, error) {
+	urls, stop, err := c.connectPod(ctx, podName, ports)
+	if err != nil {
+		return nil, fmt.Errorf("connect to pod: %w", err)
+	}
+	defer stop()
+
+	var servers []CoverageServer
+	gap := false
+	for _, port := range ports {
+		// Past a free port, only the legacy port can still have a server
+		if gap && port != legacyCoveragePort {
+			continue
+		}
+
+		resp, err := c.httpClient.Head(urls[port] + c.pathPrefix + "/coverage")
+		if err != nil && port == ports[0] {
+			// The server or the transport's path to it may still be starting
+			// up, so give the first port time to answer before a failure
+			// there ends the scan
+			readyCtx, cancel := context.WithTimeout(ctx, probeReadyTimeout)
+			if c.waitForServer(readyCtx, urls[port]) == nil {
+				resp, err = c.httpClient.Head(urls[port] + c.pathPrefix + "/coverage")
+			}
+			cancel()
+		}
+		if err != nil {
+			if scan {
+				gap = true
+				continue
+			}
+			fmt.Printf("  Warning: Failed to identify coverage server on port %d: %v\n", port, err)
+			servers = append(servers, CoverageServer{Port: port})
+			continue
+		}
+		resp.Body.Close()
+
+		if scan && resp.Header.Get("X-Art-Coverage-Server") == "" {
+			continue
+		}
+		servers = append(servers, serverFromHeaders(port, resp.Header))
+	}
+	return servers, nil
 }
 
 // createExecutor creates a remote command executor
@@ -672,19 +1192,28 @@ func (c *CoverageClient) createExecutor(req *rest.Request) (remotecommand.Execut
 
 // setupPortForward sets up port forwarding to the pod
 func (c *CoverageClient) setupPortForward(podName string, targetPort int) (int, chan struct{}, error) {
-	// Use a local port (let the system choose)
-	localPort := 0 // 0 means let the system choose
+	localPorts, stopChan, err := portForward(c.restConfig, c.namespace, podName, []int{targetPort})
+	if err != nil {
+		return 0, nil, err
+	}
+	fmt.Printf("Port forward ready: localhost:%d -> pod:%d\n", localPorts[targetPort], targetPort)
+	return localPorts[targetPort], stopChan, nil
+}
 
-	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/portforward", c.namespace, podName)
-	hostIP := strings.TrimPrefix(c.restConfig.Host, "https://")
+// portForward forwards local ports chosen by the system to the target ports
+// of the pod over a single connection, returning the local port of each
+// target port
+func portForward(config *rest.Config, namespace, podName string, targetPorts []int) (map[int]int, chan struct{}, error) {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/portforward", namespace, podName)
+	hostIP := strings.TrimPrefix(config.Host, "https://")
 	serverURL, err := url.Parse(fmt.Sprintf("https://%s%s", hostIP, path))
 	if err != nil {
-		return 0, nil, fmt.Errorf("parse server URL: %w", err)
+		return nil, nil, fmt.Errorf("parse server URL: %w", err)
 	}
 
-	transport, upgrader, err := spdy.RoundTripperFor(c.restConfig)
+	transport, upgrader, err := spdy.RoundTripperFor(config)
 	if err != nil {
-		return 0, nil, fmt.Errorf("create round tripper: %w", err)
+		return nil, nil, fmt.Errorf("create round tripper: %w", err)
 	}
 
 	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", serverURL)
@@ -692,53 +1221,169 @@ func (c *CoverageClient) setupPortForward(podName string, targetPort int) (int,
 	stopChan := make(chan struct{}, 1)
 	readyChan := make(chan struct{})
 
-	// Create port forward
-	ports := []string{fmt.Sprintf("%d:%d", localPort, targetPort)}
+	// Create port forward; local port 0 lets the system choose
+	ports := make([]string, len(targetPorts))
+	for i, targetPort := range targetPorts {
+		ports[i] = fmt.Sprintf("0:%d", targetPort)
+	}
 
 	out := io.Discard
 	errOut := io.Discard
 
 	forwarder, err := portforward.New(dialer, ports, stopChan, readyChan, out, errOut)
 	if err != nil {
-		return 0, nil, fmt.Errorf("create port forwarder: %w", err)
+		return nil, nil, fmt.Errorf("create port forwarder: %w", err)
 	}
 
 	// Start port forwarding in background
+	errChan := make(chan error, 1)
 	go func() {
-		if err := forwarder.ForwardPorts(); err != nil {
-			fmt.Printf("Warning: Port forward error: %v\n", err)
-		}
+		errChan <- forwarder.ForwardPorts()
 	}()
 
-	// Wait for ready signal
+	// Wait for ready signal; a failed upgrade (e.g. missing pods/portforward
+	// permission or a proxy blocking SPDY) ends the forwarder before it is ready
 	select {
+	case err := <-errChan:
+		if err == nil {
+			err = errors.New("port forward closed")
+		}
+		return nil, nil, fmt.Errorf("port forward: %w", err)
 	case <-readyChan:
-		// Get the actual local port that was assigned
+		go func() {
+			if err := <-errChan; err != nil {
+				fmt.Printf("Warning: Port forward error: %v\n", err)
+			}
+		}()
+
+		// Get the actual local ports that were assigned
 		forwardedPorts, err := forwarder.GetPorts()
-		if err != nil || len(forwardedPorts) == 0 {
+		if err != nil || len(forwardedPorts) != len(targetPorts) {
 			close(stopChan)
-			return 0, nil, fmt.Errorf("get forwarded ports: %w", err)
+			return nil, nil, fmt.Errorf("get forwarded ports: %w", err)
 		}
-		actualLocalPort := int(forwardedPorts[0].Local)
-		fmt.Printf("Port forward ready: localhost:%d -> pod:%d\n", actualLocalPort, targetPort)
-		return actualLocalPort, stopChan, nil
+		localPorts := make(map[int]int, len(forwardedPorts))
+		for _, forwarded := range forwardedPorts {
+			localPorts[int(forwarded.Remote)] = int(forwarded.Local)
+		}
+		return localPorts, stopChan, nil
 	case <-time.After(30 * time.Second):
 		close(stopChan)
-		return 0, nil, fmt.Errorf("timeout waiting for port forward")
+		return nil, nil, fmt.Errorf("timeout waiting for port forward")
+	}
+}
+
+// CollectCountersFromURL collects only the coverage counters from a direct URL.
+// Servers supporting it skip the metadata (?nometa=1), which does not change for the
+// lifetime of the process, so it must be taken from an earlier full collection.
+// Other servers return their full coverage.
+func (c *CoverageClient) CollectCountersFromURL(coverageURL, testName string) error {
+	if info, err := c.GetServerInfo(coverageURL); err == nil && !info.Legacy() && !info.Has(CapabilityNoMeta) {
+		return c.collectCoverageFromURL(coverageURL, testName)
 	}
+	return c.collectCoverageFromURL(coverageURL+"?nometa=1", testName)
+}
+
+// GetServerInfo returns the format, protocol version and capabilities of the
+// coverage server behind the coverage URL (e.g. http://localhost:53700/coverage),
+// queried once per server from /coverage/info. Servers without the endpoint are
+// reported as legacy (protocol version 0).
+func (c *CoverageClient) GetServerInfo(coverageURL string) (*ServerInfo, error) {
+	baseURL, err := serverBaseURL(coverageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.serverInfoMu.Lock()
+	defer c.serverInfoMu.Unlock()
+	if info, ok := c.serverInfo[baseURL]; ok {
+		return info, nil
+	}
+
+	resp, err := c.httpClient.Get(baseURL + "/coverage/info")
+	if err != nil {
+		return nil, fmt.Errorf("query server info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	info := &ServerInfo{}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+			return nil, fmt.Errorf("decode server info: %w", err)
+		}
+		c.checkProtocolVersion(info.ProtocolVersion)
+	case http.StatusNotFound:
+		// Legacy server without /coverage/info
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("info endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	if c.serverInfo == nil {
+		c.serverInfo = make(map[string]*ServerInfo)
+	}
+	c.serverInfo[baseURL] = info
+	return info, nil
+}
+
+// forgetServerInfo drops the cached info of the server at baseURL (including
+// the path prefix), since a local port or pod IP may later be reused for
+// another pod
+func (c *CoverageClient) forgetServerInfo(baseURL string) {
+	c.serverInfoMu.Lock()
+	defer c.serverInfoMu.Unlock()
+	delete(c.serverInfo, baseURL)
+}
+
+// checkProtocolVersion warns (once) when a server speaks a newer protocol than
+// the client, since new fields or semantics may be ignored
+func (c *CoverageClient) checkProtocolVersion(version int) {
+	if version > ProtocolVersion && !c.protocolWarned {
+		c.protocolWarned = true
+		fmt.Printf("  Warning: Coverage server uses protocol version %d, newer than supported (%d); update coverport\n",
+			version, ProtocolVersion)
+	}
+}
+
+// serverBaseURL returns the root URL of the coverage server behind a coverage URL
+func serverBaseURL(coverageURL string) (string, error) {
+	u, err := url.Parse(coverageURL)
+	if err != nil {
+		return "", fmt.Errorf("parse coverage URL: %w", err)
+	}
+	u.RawQuery = ""
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/coverage")
+	return strings.TrimSuffix(u.String(), "/"), nil
 }
 
 // collectCoverageFromURL collects coverage from the given URL
 // Automatically detects Go or Python coverage format
 func (c *CoverageClient) collectCoverageFromURL(coverageURL, testName string) error {
 	// Try GET request first (Python uses GET with query param)
-	getURL := coverageURL + "?name=" + url.QueryEscape(testName)
-	resp, err := c.httpClient.Get(getURL)
+	separator := "?"
+	if strings.Contains(coverageURL, "?") {
+		separator = "&"
+	}
+	getURL := coverageURL + separator + "name=" + url.QueryEscape(testName)
+	req, err := http.NewRequest(http.MethodGet, getURL, nil)
+	if err != nil {
+		return fmt.Errorf("create coverage request: %w", err)
+	}
+	// Streaming servers send the coverage files as a tar archive, older servers
+	// ignore the header and send JSON. Accept-Encoding: gzip is added (and the
+	// response decompressed) by the HTTP transport. zstd is not negotiated, as
+	// the Go coverage server can only use the standard library.
+	req.Header.Set("Accept", "application/x-tar, application/json;q=0.9")
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("send coverage request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("coverage endpoint returned 401: %w", ErrUnauthorized)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("coverage endpoint returned %d: %s", resp.StatusCode, body)
@@ -748,6 +1393,13 @@ func (c *CoverageClient) collectCoverageFromURL(coverageURL, testName string) er
 	if resp.Header.Get("X-Art-Coverage-Server") == "" {
 		fmt.Printf("  Warning: Response missing X-Art-Coverage-Server header (may be an older server or wrong endpoint)\n")
 	}
+	if version, err := strconv.Atoi(resp.Header.Get("X-Art-Coverage-Protocol")); err == nil {
+		c.checkProtocolVersion(version)
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-tar") {
+		return c.saveCoverageArchive(resp, testName)
+	}
 
 	// Read response body into buffer for format detection
 	body, err := io.ReadAll(resp.Body)
@@ -755,26 +1407,175 @@ func (c *CoverageClient) collectCoverageFromURL(coverageURL, testName string) er
 		return fmt.Errorf("read response body: %w", err)
 	}
 
-	// Detect format based on response fields
-	format := c.detectCoverageFormat(body)
+	return c.saveCoverage(body, testName, ParseCoverageFormat(resp.Header.Get("X-Art-Coverage-Format")))
+}
+
+// saveCoverageArchive streams the files of a tar coverage response into the
+// test directory as they are, without buffering them in memory
+func (c *CoverageClient) saveCoverageArchive(resp *http.Response, testName string) error {
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		// Only left compressed when the gzip encoding was requested explicitly
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("decompress coverage archive: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	// The Go, Python and Rust servers stream archives; servers predating the
+	// format header are Go servers
+	format := ParseCoverageFormat(resp.Header.Get("X-Art-Coverage-Format"))
+	if format == "" {
+		format = FormatGo
+	}
+	fmt.Printf("  Detected coverage format: %s (streamed)\n", format)
+
+	testDir := filepath.Join(c.outputDir, testName)
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		return fmt.Errorf("create test directory: %w", err)
+	}
+
+	saved := 0
+	tr := tar.NewReader(body)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read coverage archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := filepath.Base(header.Name)
+		if name != header.Name || name == "." || name == ".." {
+			return fmt.Errorf("unexpected file %q in coverage archive", header.Name)
+		}
+
+		path := filepath.Join(testDir, name)
+		if err := writeFileFromReader(path, tr); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		fmt.Printf("  Saved: %s\n", path)
+		saved++
+	}
+
+	if saved == 0 {
+		return fmt.Errorf("coverage archive contains no files")
+	}
+
+	c.detectedFormat = format
+	return nil
+}
+
+// writeFileFromReader streams r into a new file at path
+func writeFileFromReader(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SaveCoverage saves a coverage server payload (the JSON returned by /coverage or
+// pushed by a terminating pod) into the test directory. The format is detected
+// from the payload if empty (e.g. no X-Art-Coverage-Format header was sent).
+func (c *CoverageClient) SaveCoverage(body []byte, testName string, format CoverageFormat) error {
+	return c.saveCoverage(body, testName, format)
+}
+
+// DetectedFormat returns the format of the last coverage saved by the client,
+// or an empty string if nothing was saved yet
+func (c *CoverageClient) DetectedFormat() CoverageFormat {
+	return c.detectedFormat
+}
+
+// saveCoverage saves a coverage payload. The format announced by the server
+// (X-Art-Coverage-Format header) takes precedence over detection.
+func (c *CoverageClient) saveCoverage(body []byte, testName string, format CoverageFormat) error {
+	if format == "" {
+		format = c.detectCoverageFormat(body)
+	}
 	fmt.Printf("  Detected coverage format: %s\n", format)
 
+	var err error
 	switch format {
 	case FormatPython:
-		return c.collectPythonCoverage(body, testName)
+		err = c.collectPythonCoverage(body, testName)
+	case FormatNYC:
+		err = c.collectNYCCoverage(body, testName)
 	case FormatRust:
-		return c.collectRustCoverage(body, testName)
+		err = c.collectRustCoverage(body, testName)
 	case FormatGo:
-		return c.collectGoCoverage(body, testName)
+		err = c.collectGoCoverage(body, testName)
 	default:
 		return fmt.Errorf("unsupported coverage format: %s", format)
 	}
+	if err != nil {
+		return err
+	}
+
+	c.detectedFormat = format
+	return nil
+}
+
+// ParseCoverageFormat converts a format name (as sent by coverage servers) to a
+// CoverageFormat. It returns an empty string for unknown names.
+func ParseCoverageFormat(name string) CoverageFormat {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "go":
+		return FormatGo
+	case "python":
+		return FormatPython
+	case "nyc", "istanbul", "node", "nodejs":
+		return FormatNYC
+	case "rust":
+		return FormatRust
+	default:
+		return ""
+	}
+}
+
+// isIstanbulPayload reports whether the base64 coverage_data of a response is
+// Istanbul JSON (an object keyed by file path) rather than a Python .coverage file
+func isIstanbulPayload(body []byte) bool {
+	var resp NodeCoverageResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.CoverageData == "" {
+		return false
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.CoverageData)
+	if err != nil {
+		return false
+	}
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{' && json.Valid(data)
 }
 
 // detectCoverageFormat detects the coverage format from the response body
 func (c *CoverageClient) detectCoverageFormat(body []byte) CoverageFormat {
-	// Python responses contain "coverage_data" field
+	// Servers may state their format explicitly
+	var declared struct {
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal(body, &declared); err == nil {
+		if format := ParseCoverageFormat(declared.Format); format != "" {
+			return format
+		}
+	}
+	// Python and Node.js responses contain "coverage_data" field; Node.js
+	// sends Istanbul JSON while Python sends a .coverage SQLite database
 	if bytes.Contains(body, []byte(`"coverage_data"`)) {
+		if isIstanbulPayload(body) {
+			return FormatNYC
+		}
 		return FormatPython
 	}
 	// Rust responses contain "profraw_data" field
@@ -830,6 +1631,45 @@ func (c *CoverageClient) collectPythonCoverage(body []byte, testName string) err
 	return nil
 }
 
+// collectNYCCoverage handles Node.js/Istanbul coverage format
+func (c *CoverageClient) collectNYCCoverage(body []byte, testName string) error {
+	var resp NodeCoverageResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decode Node.js coverage response: %w", err)
+	}
+
+	if resp.CoverageData == "" {
+		if resp.Message != "" {
+			fmt.Printf("  Warning: %s\n", resp.Message)
+		}
+		return fmt.Errorf("no coverage data received")
+	}
+
+	coverageData, err := base64.StdEncoding.DecodeString(resp.CoverageData)
+	if err != nil {
+		return fmt.Errorf("decode Istanbul coverage data: %w", err)
+	}
+	if !json.Valid(coverageData) {
+		return fmt.Errorf("istanbul coverage data is not valid JSON")
+	}
+
+	// Create test-specific subdirectory
+	testDir := filepath.Join(c.outputDir, testName)
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		return fmt.Errorf("create test directory: %w", err)
+	}
+
+	// Save as coverage-final.json, the file NYC processing looks for
+	coverageFile := filepath.Join(testDir, "coverage-final.json")
+	if err := os.WriteFile(coverageFile, coverageData, 0644); err != nil {
+		return fmt.Errorf("write coverage file: %w", err)
+	}
+
+	fmt.Printf("  Saved: %s (%d bytes)\n", coverageFile, len(coverageData))
+
+	return nil
+}
+
 // collectRustCoverage handles Rust/LLVM profraw coverage format
 func (c *CoverageClient) collectRustCoverage(body []byte, testName string) error {
 	var resp RustCoverageResponse
@@ -875,7 +1715,7 @@ func (c *CoverageClient) collectGoCoverage(body []byte, testName string) error {
 		return fmt.Errorf("decode Go coverage response: %w", err)
 	}
 
-	// Decode and save metadata
+	// Decode metadata (empty when requested with ?nometa=1)
 	metaData, err := base64.StdEncoding.DecodeString(covResp.MetaData)
 	if err != nil {
 		return fmt.Errorf("decode metadata: %w", err)
@@ -894,9 +1734,12 @@ func (c *CoverageClient) collectGoCoverage(body []byte, testName string) error {
 	}
 
 	// Save files with proper names
-	metaPath := filepath.Join(testDir, covResp.MetaFilename)
-	if err := os.WriteFile(metaPath, metaData, 0644); err != nil {
-		return fmt.Errorf("write metadata file: %w", err)
+	if covResp.MetaFilename != "" && len(metaData) > 0 {
+		metaPath := filepath.Join(testDir, covResp.MetaFilename)
+		if err := os.WriteFile(metaPath, metaData, 0644); err != nil {
+			return fmt.Errorf("write metadata file: %w", err)
+		}
+		fmt.Printf("  Saved: %s\n", metaPath)
 	}
 
 	counterPath := filepath.Join(testDir, covResp.CountersFilename)
@@ -904,9 +1747,25 @@ func (c *CoverageClient) collectGoCoverage(body []byte, testName string) error {
 		return fmt.Errorf("write counters file: %w", err)
 	}
 
-	fmt.Printf("  Saved: %s\n", metaPath)
 	fmt.Printf("  Saved: %s\n", counterPath)
 
+	// Subprocess coverage goes alongside, so covdata merges it with ours
+	for _, file := range covResp.CoverDirFiles {
+		name := filepath.Base(file.Name)
+		if name != file.Name || name == "." || name == ".." {
+			return fmt.Errorf("unexpected GOCOVERDIR file %q", file.Name)
+		}
+		data, err := base64.StdEncoding.DecodeString(file.Data)
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", name, err)
+		}
+		path := filepath.Join(testDir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		fmt.Printf("  Saved: %s\n", path)
+	}
+
 	return nil
 }
 
@@ -1,14 +1,29 @@
 package coverageclient
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -284,6 +299,50 @@ func TestCollectCoverageFromURL(t *testing.T) {
 	}
 }
 
+func TestCollectGoCoverage_CoverDirFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	client := &CoverageClient{outputDir: tempDir}
+
+	response := CoverageResponse{
+		MetaFilename:     "covmeta.own",
+		MetaData:         base64.StdEncoding.EncodeToString([]byte("own meta")),
+		CountersFilename: "covcounters.own.1.1",
+		CountersData:     base64.StdEncoding.EncodeToString([]byte("own counters")),
+		CoverDirFiles: []CoverDirFile{
+			{Name: "covmeta.child", Data: base64.StdEncoding.EncodeToString([]byte("child meta"))},
+			{Name: "covcounters.child.2.1", Data: base64.StdEncoding.EncodeToString([]byte("child counters"))},
+		},
+	}
+	body, _ := json.Marshal(response)
+
+	if err := client.collectGoCoverage(body, "test-case"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testDir := filepath.Join(tempDir, "test-case")
+	for name, want := range map[string]string{
+		"covmeta.own":           "own meta",
+		"covcounters.own.1.1":   "own counters",
+		"covmeta.child":         "child meta",
+		"covcounters.child.2.1": "child counters",
+	} {
+		got, err := os.ReadFile(filepath.Join(testDir, name))
+		if err != nil {
+			t.Errorf("Expected %s to be saved: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("Content mismatch for %s. Expected %s, got %s", name, want, got)
+		}
+	}
+
+	response.CoverDirFiles = []CoverDirFile{{Name: "../escape", Data: ""}}
+	body, _ = json.Marshal(response)
+	if err := client.collectGoCoverage(body, "test-case"); err == nil {
+		t.Error("Expected error for GOCOVERDIR file with a path")
+	}
+}
+
 func TestCollectCoverageFromURL_ServerError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -696,6 +755,702 @@ func TestDetectCoveragePort_ExecFailure(t *testing.T) {
 	}
 }
 
+func TestParseDetectedPorts(t *testing.T) {
+	ports, err := parseDetectedPorts("53700\n53701\n9095\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ports, []int{53700, 53701, 9095}) {
+		t.Errorf("Unexpected ports: %v", ports)
+	}
+
+	if _, err := parseDetectedPorts("53700\nbash: warning\n"); err == nil {
+		t.Error("Expected error for unexpected output")
+	}
+}
+
+func TestCoverageServer_DirName(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Art-Coverage-Pid", "42")
+	header.Set("X-Art-Coverage-Binary", "manager")
+
+	server := serverFromHeaders(53701, header)
+	if server != (CoverageServer{Port: 53701, Pid: 42, Binary: "manager"}) {
+		t.Errorf("Unexpected server: %+v", server)
+	}
+
+	tests := []struct {
+		server CoverageServer
+		want   string
+	}{
+		{CoverageServer{Port: 53701, Pid: 42, Binary: "manager"}, "manager-42"},
+		{CoverageServer{Port: 53701, Pid: 42, Binary: "../../manager"}, "manager-42"},
+		{CoverageServer{Port: 53701, Pid: 42, Binary: ".."}, "port-53701"},
+		{serverFromHeaders(9095, http.Header{}), "port-9095"},
+	}
+	for _, tt := range tests {
+		if got := tt.server.DirName(); got != tt.want {
+			t.Errorf("DirName(%+v) = %q, want %q", tt.server, got, tt.want)
+		}
+	}
+}
+
+func TestDeclaredCoveragePorts(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		ports       []corev1.ContainerPort
+		want        []int
+		wantErr     bool
+	}{
+		{
+			name: "none",
+			ports: []corev1.ContainerPort{
+				{Name: "http", ContainerPort: 8080},
+			},
+		},
+		{
+			name:        "annotation",
+			annotations: map[string]string{PortAnnotation: "53700, 53701"},
+			ports: []corev1.ContainerPort{
+				{Name: "coverage", ContainerPort: 9095},
+			},
+			want: []int{53700, 53701},
+		},
+		{
+			name:        "invalid annotation",
+			annotations: map[string]string{PortAnnotation: "coverage"},
+			wantErr:     true,
+		},
+		{
+			name: "named ports",
+			ports: []corev1.ContainerPort{
+				{Name: "http", ContainerPort: 8080},
+				{Name: "coverage", ContainerPort: 53700},
+				{Name: "coverage-2", ContainerPort: 53701},
+			},
+			want: []int{53700, 53701},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Ports: tt.ports}},
+				},
+			}
+
+			got, err := declaredCoveragePorts(pod)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected ports %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDefaultCoveragePorts(t *testing.T) {
+	ports := DefaultCoveragePorts()
+	if len(ports) != 51 || ports[0] != 53700 || ports[49] != 53749 || ports[50] != 9095 {
+		t.Errorf("Unexpected default ports: %v", ports)
+	}
+}
+
+func TestDiscoverCoverageServers_NoConfig(t *testing.T) {
+	client := &CoverageClient{namespace: "test-ns"}
+
+	if _, err := client.DiscoverCoverageServers(context.Background(), "test-pod", ""); err == nil {
+		t.Error("Expected error when kubernetes client is not configured")
+	}
+}
+
+func TestResetCoverageFromURL(t *testing.T) {
+	var resetCalled bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/coverage/info" {
+			http.NotFound(w, r) // Legacy server
+			return
+		}
+		if r.URL.Path != "/coverage/reset" {
+			t.Errorf("Expected /coverage/reset, got %s", r.URL.Path)
+		}
+		resetCalled = true
+		w.Write([]byte("Coverage counters reset"))
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := client.ResetCoverageFromURL(server.URL + "/coverage"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if !resetCalled {
+		t.Error("Reset endpoint was not called")
+	}
+}
+
+func TestSetToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/coverage/info" {
+			http.NotFound(w, r) // Legacy server
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		if authorization != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("Coverage counters reset"))
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	err := client.ResetCoverageFromURL(server.URL + "/coverage")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized without a token, got: %v", err)
+	}
+
+	client.SetToken("secret")
+	client.SetToken("secret") // Setting the token again must not stack transports
+	if err := client.ResetCoverageFromURL(server.URL + "/coverage"); err != nil {
+		t.Errorf("Unexpected error with token: %v", err)
+	}
+
+	client.SetToken("")
+	if _, ok := client.httpClient.Transport.(*bearerTransport); ok {
+		t.Error("Expected bearer transport to be removed for an empty token")
+	}
+}
+
+func TestTokenFromSecret(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "coverage-token", Namespace: "test-ns"},
+		Data: map[string][]byte{
+			"token":  []byte("secret\n"),
+			"custom": []byte("other"),
+		},
+	})
+	client := &CoverageClient{clientset: clientset, namespace: "test-ns"}
+
+	tests := []struct {
+		name      string
+		secret    string
+		key       string
+		expected  string
+		expectErr bool
+	}{
+		{name: "default key", secret: "coverage-token", expected: "secret"},
+		{name: "custom key", secret: "coverage-token", key: "custom", expected: "other"},
+		{name: "missing key", secret: "coverage-token", key: "missing", expectErr: true},
+		{name: "missing secret", secret: "missing", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := client.TokenFromSecret(context.Background(), tt.secret, tt.key)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if token != tt.expected {
+				t.Errorf("Expected token %q, got %q", tt.expected, token)
+			}
+		})
+	}
+}
+
+// writeTestCertificates generates a CA with a server certificate for
+// 127.0.0.1/localhost and a client certificate, and writes them to dir.
+// It returns the CA, server and client certificate and key paths.
+func writeTestCertificates(t *testing.T, dir string) (caFile, serverCert, serverKey, clientCert, clientKey string) {
+	t.Helper()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("Failed to create %s certificate: %v", name, err)
+		}
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		return writePEM(name+".crt", "CERTIFICATE", der), writePEM(name+".key", "EC PRIVATE KEY", keyDER)
+	}
+
+	caFile = writePEM("ca.crt", "CERTIFICATE", caDER)
+	serverCert, serverKey = issue("server", 2, x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey = issue("client", 3, x509.ExtKeyUsageClientAuth)
+	return caFile, serverCert, serverKey, clientCert, clientKey
+}
+
+// newTLSCoverageServer starts an HTTPS Go coverage server with the given
+// certificate, requiring client certificates signed by clientCA if set
+func newTLSCoverageServer(t *testing.T, certFile, keyFile, clientCA string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/coverage/info" {
+			http.NotFound(w, r) // Legacy server
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CoverageResponse{
+			MetaFilename:     "covmeta.abc",
+			MetaData:         base64.StdEncoding.EncodeToString([]byte("meta")),
+			CountersFilename: "covcounters.abc.1.2",
+			CountersData:     base64.StdEncoding.EncodeToString([]byte("counters")),
+		})
+	}))
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load server certificate: %v", err)
+	}
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != "" {
+		caPEM, _ := os.ReadFile(clientCA)
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(caPEM)
+		server.TLS.ClientCAs = pool
+		server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	server.StartTLS()
+	return server
+}
+
+func TestSetTLSConfig(t *testing.T) {
+	caFile, serverCert, serverKey, clientCert, clientKey := writeTestCertificates(t, t.TempDir())
+
+	tlsServer := newTLSCoverageServer(t, serverCert, serverKey, "")
+	defer tlsServer.Close()
+	mtlsServer := newTLSCoverageServer(t, serverCert, serverKey, caFile)
+	defer mtlsServer.Close()
+
+	tests := []struct {
+		name      string
+		serverURL string
+		opts      TLSOptions
+		expectErr bool
+	}{
+		{name: "CA bundle", serverURL: tlsServer.URL, opts: TLSOptions{CAFile: caFile}},
+		{name: "untrusted server", serverURL: tlsServer.URL, opts: TLSOptions{}, expectErr: true},
+		{name: "insecure skip verify", serverURL: tlsServer.URL, opts: TLSOptions{InsecureSkipVerify: true}},
+		{name: "mTLS", serverURL: mtlsServer.URL, opts: TLSOptions{CAFile: caFile, CertFile: clientCert, KeyFile: clientKey}},
+		{name: "mTLS without client certificate", serverURL: mtlsServer.URL, opts: TLSOptions{CAFile: caFile}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &CoverageClient{
+				outputDir:  t.TempDir(),
+				httpClient: &http.Client{Timeout: 10 * time.Second},
+			}
+			client.SetToken("secret") // TLS settings must apply underneath the token transport
+			if err := client.SetTLSConfig(tt.opts); err != nil {
+				t.Fatalf("SetTLSConfig failed: %v", err)
+			}
+
+			err := client.collectCoverageFromURL(tt.serverURL+"/coverage", "tls")
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(client.outputDir, "tls", "covcounters.abc.1.2")); err != nil {
+				t.Errorf("Expected counters file to be saved: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetTLSConfig_InvalidFiles(t *testing.T) {
+	caFile, _, _, clientCert, _ := writeTestCertificates(t, t.TempDir())
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{name: "missing CA bundle", opts: TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.crt")}},
+		{name: "CA bundle without certificates", opts: TLSOptions{CAFile: os.DevNull}},
+		{name: "certificate without key", opts: TLSOptions{CAFile: caFile, CertFile: clientCert}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &CoverageClient{httpClient: &http.Client{}}
+			if err := client.SetTLSConfig(tt.opts); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestLocalCoverageURL(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{prefix: "", expected: "http://localhost:8080/coverage"},
+		{prefix: "/debug", expected: "http://localhost:8080/debug/coverage"},
+		{prefix: "debug/", expected: "http://localhost:8080/debug/coverage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			client := &CoverageClient{}
+			client.SetPathPrefix(tt.prefix)
+			if got := client.LocalCoverageURL(8080); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSocketCommand(t *testing.T) {
+	client := &CoverageClient{httpClient: &http.Client{}}
+	client.SetPathPrefix("/debug")
+
+	command := client.socketCommand("/tmp/coverage.sock", "/coverage?name=test")
+	expected := []string{"curl", "--silent", "--show-error", "--fail", "--unix-socket", "/tmp/coverage.sock",
+		"-H", "Accept: application/json", "http://localhost/debug/coverage?name=test"}
+	if strings.Join(command, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v, got %v", expected, command)
+	}
+
+	client.SetToken("secret")
+	command = client.socketCommand("/tmp/coverage.sock", "/coverage")
+	if !strings.Contains(strings.Join(command, " "), "Authorization: Bearer secret") {
+		t.Errorf("Expected token header in command, got %v", command)
+	}
+}
+
+func TestCollectCoverageViaSocket_NoRestConfig(t *testing.T) {
+	client := &CoverageClient{httpClient: &http.Client{}}
+
+	err := client.CollectCoverageViaSocket(context.Background(), "pod", "app", "/tmp/coverage.sock", "test")
+	if err == nil || !strings.Contains(err.Error(), "kubernetes client not configured") {
+		t.Errorf("Expected 'kubernetes client not configured' error, got: %v", err)
+	}
+}
+
+func TestResetCoverageFromURL_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "please use -covermode=atomic", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	err := client.ResetCoverageFromURL(server.URL + "/coverage")
+	if err == nil {
+		t.Fatal("Expected error for server error response")
+	}
+	if !strings.Contains(err.Error(), "covermode=atomic") {
+		t.Errorf("Expected error to include server message, got: %v", err)
+	}
+}
+
+func TestCollectCountersFromURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/coverage/info" {
+			http.NotFound(w, r) // Legacy server
+			return
+		}
+		if r.URL.Query().Get("nometa") != "1" {
+			t.Errorf("Expected nometa=1, got query %q", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("name") != "snap" {
+			t.Errorf("Expected name=snap, got query %q", r.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CoverageResponse{
+			CountersFilename: "covcounters.abc.1.2",
+			CountersData:     base64.StdEncoding.EncodeToString([]byte("counters")),
+		})
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{
+		outputDir:  tempDir,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := client.CollectCountersFromURL(server.URL, "snap"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(tempDir, "snap"))
+	if err != nil {
+		t.Fatalf("Failed to read snapshot dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "covcounters.abc.1.2" {
+		t.Errorf("Expected only the counters file, got %v", entries)
+	}
+}
+
+// writeTestArchive writes a tar archive of the given files
+func writeTestArchive(t *testing.T, w io.Writer, files map[string]string) {
+	t.Helper()
+	tw := tar.NewWriter(w)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatalf("Failed to write tar data: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+}
+
+func TestCollectCoverageFromURL_Streaming(t *testing.T) {
+	goFiles := map[string]string{"covmeta.abc": "meta", "covcounters.abc.1.2": "counters"}
+
+	tests := []struct {
+		name   string
+		format CoverageFormat
+		files  map[string]string
+		gzip   bool
+	}{
+		{name: "plain tar", format: FormatGo, files: goFiles, gzip: false},
+		{name: "gzip tar", format: FormatGo, files: goFiles, gzip: true},
+		{name: "python", format: FormatPython, files: map[string]string{".coverage": "zdata"}, gzip: true},
+		{name: "rust", format: FormatRust, files: map[string]string{"coverage.7.1.profraw": "profraw"}, gzip: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept"), "application/x-tar") {
+					t.Errorf("Expected Accept to include application/x-tar, got %q", r.Header.Get("Accept"))
+				}
+				w.Header().Set("Content-Type", "application/x-tar")
+				w.Header().Set("X-Art-Coverage-Format", string(tt.format))
+				if tt.gzip && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					w.Header().Set("Content-Encoding", "gzip")
+					gz := gzip.NewWriter(w)
+					defer gz.Close()
+					writeTestArchive(t, gz, tt.files)
+					return
+				}
+				writeTestArchive(t, w, tt.files)
+			}))
+			defer server.Close()
+
+			tempDir := t.TempDir()
+			client := &CoverageClient{
+				outputDir:  tempDir,
+				httpClient: &http.Client{Timeout: 10 * time.Second},
+			}
+
+			if err := client.collectCoverageFromURL(server.URL, "stream"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for name, want := range tt.files {
+				data, err := os.ReadFile(filepath.Join(tempDir, "stream", name))
+				if err != nil {
+					t.Fatalf("Expected %s to be saved: %v", name, err)
+				}
+				if string(data) != want {
+					t.Errorf("%s: expected %q, got %q", name, want, data)
+				}
+			}
+			if client.DetectedFormat() != tt.format {
+				t.Errorf("Expected format %s, got %s", tt.format, client.DetectedFormat())
+			}
+		})
+	}
+}
+
+func TestCollectCoverageFromURL_StreamingRejectsPaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-tar")
+		writeTestArchive(t, w, map[string]string{"../escape": "data"})
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{
+		outputDir:  filepath.Join(tempDir, "out"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := client.collectCoverageFromURL(server.URL, "stream"); err == nil {
+		t.Error("Expected error for archive entry outside the test directory")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "out", "escape")); err == nil {
+		t.Error("Archive entry was written outside the test directory")
+	}
+}
+
+// newInfoServer returns a coverage server advertising the given info, recording
+// the requests it receives
+func newInfoServer(t *testing.T, info ServerInfo, requests *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RequestURI())
+		w.Header().Set("X-Art-Coverage-Server", "1")
+		switch r.URL.Path {
+		case "/coverage/info":
+			json.NewEncoder(w).Encode(info)
+		case "/coverage/reset":
+			w.Write([]byte("reset"))
+		default:
+			json.NewEncoder(w).Encode(NodeCoverageResponse{
+				CoverageData: base64.StdEncoding.EncodeToString([]byte(`{}`)),
+			})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetServerInfo(t *testing.T) {
+	var requests []string
+	server := newInfoServer(t, ServerInfo{Format: "go", ProtocolVersion: 1, Capabilities: []string{"reset", "nometa"}}, &requests)
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 10 * time.Second}}
+
+	for _, coverageURL := range []string{server.URL + "/coverage", server.URL + "/coverage?nometa=1", server.URL} {
+		info, err := client.GetServerInfo(coverageURL)
+		if err != nil {
+			t.Fatalf("GetServerInfo(%s) failed: %v", coverageURL, err)
+		}
+		if info.Legacy() || info.Format != "go" || !info.Has(CapabilityNoMeta) || info.Has(CapabilitySave) {
+			t.Errorf("Unexpected server info: %+v", info)
+		}
+	}
+	if len(requests) != 1 || requests[0] != "/coverage/info" {
+		t.Errorf("Expected a single cached /coverage/info request, got %v", requests)
+	}
+}
+
+func TestGetServerInfo_Legacy(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 10 * time.Second}}
+
+	info, err := client.GetServerInfo(server.URL + "/coverage")
+	if err != nil {
+		t.Fatalf("GetServerInfo failed: %v", err)
+	}
+	if !info.Legacy() {
+		t.Errorf("Expected legacy server info, got %+v", info)
+	}
+}
+
+func TestCapabilityNegotiation(t *testing.T) {
+	var requests []string
+	server := newInfoServer(t, ServerInfo{Format: "istanbul", ProtocolVersion: 1}, &requests)
+	client := &CoverageClient{
+		outputDir:  t.TempDir(),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	// Without the nometa capability the full coverage is requested
+	if err := client.CollectCountersFromURL(server.URL+"/coverage", "snap"); err != nil {
+		t.Fatalf("CollectCountersFromURL failed: %v", err)
+	}
+	for _, uri := range requests {
+		if strings.Contains(uri, "nometa") {
+			t.Errorf("Expected no nometa request for a server without the capability, got %s", uri)
+		}
+	}
+
+	// Without the reset capability, reset fails without calling the server
+	requests = nil
+	err := client.ResetCoverageFromURL(server.URL + "/coverage")
+	if err == nil || !strings.Contains(err.Error(), "does not support") {
+		t.Errorf("Expected unsupported reset error, got %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("Expected no requests, got %v", requests)
+	}
+}
+
+func TestCheckProtocolVersion(t *testing.T) {
+	client := &CoverageClient{}
+
+	client.checkProtocolVersion(ProtocolVersion)
+	if client.protocolWarned {
+		t.Error("Expected no warning for the supported protocol version")
+	}
+	client.checkProtocolVersion(ProtocolVersion + 1)
+	if !client.protocolWarned {
+		t.Error("Expected a warning for a newer protocol version")
+	}
+}
+
+func TestPortForward_NoConfig(t *testing.T) {
+	client := &CoverageClient{namespace: "test-ns"}
+
+	_, _, err := client.PortForward("test-pod", 53700)
+	if err == nil || !strings.Contains(err.Error(), "kubernetes client not configured") {
+		t.Errorf("Expected 'kubernetes client not configured' error, got: %v", err)
+	}
+}
+
 // func TestPrintCoverageSummary(t *testing.T) {
 // 	tempDir, _ := os.MkdirTemp("", "summary-test-*")
 // 	defer os.RemoveAll(tempDir)
@@ -763,6 +1518,91 @@ func TestDetectCoverageFormat_Python(t *testing.T) {
 	}
 }
 
+func TestDetectCoverageFormat_Istanbul(t *testing.T) {
+	client := &CoverageClient{}
+
+	istanbul := base64.StdEncoding.EncodeToString([]byte(`{"/app/src/api.js":{"path":"/app/src/api.js","s":{"0":1}}}`))
+	sqlite := base64.StdEncoding.EncodeToString([]byte("SQLite format 3\x00"))
+
+	tests := []struct {
+		name     string
+		body     string
+		expected CoverageFormat
+	}{
+		{"declared istanbul", `{"coverage_data":"e30=","format":"istanbul"}`, FormatNYC},
+		{"sniffed istanbul JSON", `{"label":"t","coverage_data":"` + istanbul + `"}`, FormatNYC},
+		{"python sqlite", `{"label":"t","coverage_data":"` + sqlite + `"}`, FormatPython},
+		{"unknown declared format falls back to sniffing", `{"coverage_data":"` + sqlite + `","format":"cobol"}`, FormatPython},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if format := client.detectCoverageFormat([]byte(tt.body)); format != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, format)
+			}
+		})
+	}
+}
+
+func TestCollectNYCCoverage(t *testing.T) {
+	istanbul := `{"/app/src/api.js":{"path":"/app/src/api.js","statementMap":{},"fnMap":{},"branchMap":{},"s":{},"f":{},"b":{}}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Art-Coverage-Format", "istanbul")
+		json.NewEncoder(w).Encode(NodeCoverageResponse{
+			Label:        "node-test",
+			CoverageData: base64.StdEncoding.EncodeToString([]byte(istanbul)),
+		})
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{
+		outputDir:  tempDir,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := client.CollectCoverageFromURL(server.URL, "node-test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "node-test", "coverage-final.json"))
+	if err != nil {
+		t.Fatalf("coverage-final.json not created: %v", err)
+	}
+	if string(data) != istanbul {
+		t.Errorf("coverage content mismatch.\nExpected: %q\nGot:      %q", istanbul, data)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "node-test", ".coverage")); !os.IsNotExist(err) {
+		t.Error("Istanbul coverage must not be saved as a Python .coverage file")
+	}
+	if format := client.DetectedFormat(); format != FormatNYC {
+		t.Errorf("expected detected format %q, got %q", FormatNYC, format)
+	}
+}
+
+func TestParseCoverageFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CoverageFormat
+	}{
+		{"go", FormatGo},
+		{"Istanbul", FormatNYC},
+		{"nyc", FormatNYC},
+		{"python", FormatPython},
+		{"rust", FormatRust},
+		{"", ""},
+		{"cobol", ""},
+	}
+
+	for _, tt := range tests {
+		if result := ParseCoverageFormat(tt.input); result != tt.expected {
+			t.Errorf("ParseCoverageFormat(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
 func TestCollectRustCoverage(t *testing.T) {
 	profrawContent := []byte("fake profraw binary data for testing")
 	encodedData := base64.StdEncoding.EncodeToString(profrawContent)
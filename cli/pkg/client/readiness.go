@@ -0,0 +1,174 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// serverReadyTimeout bounds waiting for a coverage server to respond
+	serverReadyTimeout = 30 * time.Second
+
+	// probeReadyTimeout bounds waiting for the first probed port to respond
+	// during discovery, which pods without a server there also pay
+	probeReadyTimeout = 10 * time.Second
+
+	// healthPollInitial and healthPollMax are the first and the longest
+	// interval between health polls
+	healthPollInitial = 50 * time.Millisecond
+	healthPollMax     = 2 * time.Second
+
+	// filesSettleTimeout bounds waiting for Python workers to write coverage
+	filesSettleTimeout = 15 * time.Second
+
+	// filesPollInterval is the interval between polls of the coverage files
+	filesPollInterval = 250 * time.Millisecond
+
+	// filesSettleTime is how long the coverage files must stay unchanged to be
+	// considered complete
+	filesSettleTime = time.Second
+)
+
+// errNoFilesEndpoint is returned for servers without /coverage/files
+var errNoFilesEndpoint = errors.New("coverage server does not list coverage files")
+
+// CoverageFile is a coverage data file listed by /coverage/files (Python)
+type CoverageFile struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	MTime string `json:"mtime"`
+}
+
+// CoverageFilesResponse represents the Python coverage server file listing
+type CoverageFilesResponse struct {
+	DataDir string         `json:"data_dir"`
+	Files   []CoverageFile `json:"files"`
+}
+
+// waitForServer polls the health endpoint of the coverage server at baseURL
+// with backoff until it responds. Any HTTP response counts, except the gateway
+// errors returned while a transport cannot reach the pod yet, so servers
+// without a health endpoint are ready once they answer with 404.
+func (c *CoverageClient) waitForServer(ctx context.Context, baseURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, serverReadyTimeout)
+	defer cancel()
+
+	healthURL := baseURL + c.pathPrefix + "/health"
+	interval := healthPollInitial
+	for {
+		err := c.pollHealth(ctx, healthURL)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("coverage server not ready: %w", err)
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, healthPollMax)
+	}
+}
+
+// pollHealth requests the health URL once
+func (c *CoverageClient) pollHealth(ctx context.Context, healthURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return fmt.Errorf("health endpoint returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// waitForCoverageFiles polls /coverage/files of the Python coverage server at
+// baseURL after a save was triggered, until coverage files exist and neither
+// their number nor their sizes changed for filesSettleTime. It returns the
+// number of files. Failed listings count as empty, since the server may be
+// unreachable while the workers restart.
+func (c *CoverageClient) waitForCoverageFiles(ctx context.Context, baseURL string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, filesSettleTimeout)
+	defer cancel()
+
+	filesURL := baseURL + c.pathPrefix + "/coverage/files"
+	var last []CoverageFile
+	var lastChange time.Time
+	var lastErr error
+	for {
+		files, err := c.listCoverageFiles(ctx, filesURL)
+		if errors.Is(err, errNoFilesEndpoint) {
+			return 0, err
+		}
+		lastErr = err
+
+		now := time.Now()
+		if lastChange.IsZero() || !sameCoverageFiles(files, last) {
+			last, lastChange = files, now
+		} else if len(files) > 0 && now.Sub(lastChange) >= filesSettleTime {
+			return len(files), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if len(last) == 0 {
+				if lastErr != nil {
+					return 0, fmt.Errorf("no coverage files written: %w", lastErr)
+				}
+				return 0, fmt.Errorf("no coverage files written: %w", ctx.Err())
+			}
+			// Collect what was written so far
+			return len(last), nil
+		case <-time.After(filesPollInterval):
+		}
+	}
+}
+
+// listCoverageFiles requests the coverage file listing once
+func (c *CoverageClient) listCoverageFiles(ctx context.Context, filesURL string) ([]CoverageFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, filesURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list coverage files: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNoFilesEndpoint
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("files endpoint returned %d", resp.StatusCode)
+	}
+	var listing CoverageFilesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("decode files response: %w", err)
+	}
+	return listing.Files, nil
+}
+
+// sameCoverageFiles reports whether two listings have the same files and sizes
+func sameCoverageFiles(a, b []CoverageFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Size != b[i].Size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForServer(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/debug/health" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		// Unreachable through the proxy at first, then a server without /health
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 5 * time.Second}}
+	client.SetPathPrefix("/debug")
+	if err := client.waitForServer(context.Background(), server.URL); err != nil {
+		t.Fatalf("waitForServer failed: %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("got %d health requests, want 3", got)
+	}
+}
+
+func TestWaitForServer_ContextDone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 5 * time.Second}}
+	if err := client.waitForServer(ctx, server.URL); err == nil {
+		t.Fatal("expected error when the server never becomes ready")
+	}
+}
+
+func TestWaitForCoverageFiles(t *testing.T) {
+	// Workers write their files over the first polls, then the listing settles
+	listings := [][]CoverageFile{
+		{},
+		{{Name: ".coverage.1", Size: 10}},
+		{{Name: ".coverage.1", Size: 10}, {Name: ".coverage.2", Size: 5}},
+		{{Name: ".coverage.1", Size: 10}, {Name: ".coverage.2", Size: 20}},
+	}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/coverage/files" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		i := int(requests.Add(1)) - 1
+		if i >= len(listings) {
+			i = len(listings) - 1
+		}
+		json.NewEncoder(w).Encode(CoverageFilesResponse{DataDir: "/dev/shm", Files: listings[i]})
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 5 * time.Second}}
+	count, err := client.waitForCoverageFiles(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("waitForCoverageFiles failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("got %d files, want 2", count)
+	}
+	if got := int(requests.Load()); got <= len(listings) {
+		t.Errorf("returned after %d polls, before the files settled", got)
+	}
+}
+
+func TestWaitForCoverageFiles_Restarting(t *testing.T) {
+	// The server is unreachable while the workers restart after SIGHUP
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(CoverageFilesResponse{DataDir: "/dev/shm", Files: []CoverageFile{{Name: ".coverage.1", Size: 10}}})
+	}))
+	defer server.Close()
+
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 5 * time.Second}}
+	count, err := client.waitForCoverageFiles(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("waitForCoverageFiles failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("got %d files, want 1", count)
+	}
+}
+
+func TestWaitForCoverageFiles_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 5 * time.Second}}
+	_, err := client.waitForCoverageFiles(ctx, server.URL)
+	if err == nil || !strings.Contains(err.Error(), "returned 502") {
+		t.Errorf("expected the last listing error, got %v", err)
+	}
+}
+
+func TestWaitForCoverageFiles_NoFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(CoverageFilesResponse{DataDir: "/dev/shm"})
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 5 * time.Second}}
+	if _, err := client.waitForCoverageFiles(ctx, server.URL); err == nil {
+		t.Error("expected error when no coverage files are written")
+	}
+}
+
+func TestWaitForCoverageFiles_LegacyServer(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 5 * time.Second}}
+	if _, err := client.waitForCoverageFiles(context.Background(), server.URL); err == nil {
+		t.Error("expected error for a server without /coverage/files")
+	}
+}
@@ -0,0 +1,364 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Transport names accepted by TransportByName
+const (
+	TransportAuto        = "auto"         // Direct when running in a cluster, else port-forward; then proxy
+	TransportDirect      = "direct"       // Pod IP, falling back to port-forward and proxy
+	TransportPortForward = "port-forward" // SPDY port-forward through the API server
+	TransportProxy       = "proxy"        // The API server's pods/proxy subresource
+)
+
+const (
+	// directDialTimeout bounds the reachability check of pod IPs, across all ports
+	directDialTimeout = 2 * time.Second
+
+	// proxyCheckTimeout bounds the first request through the API server proxy
+	proxyCheckTimeout = 10 * time.Second
+)
+
+// Transport makes the coverage servers of a pod reachable over HTTP
+type Transport interface {
+	// Name identifies the transport in output (e.g. "port-forward")
+	Name() string
+
+	// Connect makes the given ports of the pod reachable. It returns the base
+	// URL (e.g. http://10.128.0.12:53700) of each port and a function that
+	// closes the connections.
+	Connect(ctx context.Context, pod *corev1.Pod, ports []int) (map[int]string, func(), error)
+}
+
+// NewPortForwardTransport returns a transport that port-forwards through the
+// API server of config, which requires the pods/portforward permission
+func NewPortForwardTransport(config *rest.Config) Transport {
+	return &portForwardTransport{config: config}
+}
+
+type portForwardTransport struct {
+	config *rest.Config
+}
+
+func (t *portForwardTransport) Name() string {
+	return TransportPortForward
+}
+
+func (t *portForwardTransport) Connect(ctx context.Context, pod *corev1.Pod, ports []int) (map[int]string, func(), error) {
+	if t.config == nil {
+		return nil, nil, fmt.Errorf("kubernetes client not configured")
+	}
+
+	localPorts, stopChan, err := portForward(t.config, pod.Namespace, pod.Name, ports)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	urls := make(map[int]string, len(localPorts))
+	for port, localPort := range localPorts {
+		urls[port] = fmt.Sprintf("http://localhost:%d", localPort)
+	}
+	return urls, func() { close(stopChan) }, nil
+}
+
+// NewProxyTransport returns a transport that reaches the pod through the
+// pods/<name>:<port>/proxy subresource of the API server of config, for
+// networks that block the SPDY/websocket upgrade of port-forwarding. Requests
+// go through a local reverse proxy, so they are made like for other transports.
+// Only GET and HEAD requests are forwarded, which need the get permission on
+// pods/proxy (other methods would need create). The API server consumes the
+// Authorization header, so servers requiring a coverage token cannot be reached
+// this way.
+func NewProxyTransport(config *rest.Config) Transport {
+	return &proxyTransport{config: config}
+}
+
+type proxyTransport struct {
+	config *rest.Config
+}
+
+func (t *proxyTransport) Name() string {
+	return TransportProxy
+}
+
+func (t *proxyTransport) Connect(ctx context.Context, pod *corev1.Pod, ports []int) (map[int]string, func(), error) {
+	if t.config == nil {
+		return nil, nil, fmt.Errorf("kubernetes client not configured")
+	}
+
+	apiTransport, err := rest.TransportFor(t.config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create API server transport: %w", err)
+	}
+	host := t.config.Host
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	apiServer, err := url.Parse(host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse API server URL: %w", err)
+	}
+
+	var servers []*http.Server
+	stop := func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}
+
+	urls := make(map[int]string, len(ports))
+	for _, port := range ports {
+		target := *apiServer
+		target.Path = path.Join(apiServer.Path, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s:%d/proxy", pod.Namespace, pod.Name, port))
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			stop()
+			return nil, nil, fmt.Errorf("listen for proxy: %w", err)
+		}
+		reverseProxy := &httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				r.SetURL(&target)
+				// The API server authenticates with the credentials of config
+				r.Out.Header.Del("Authorization")
+			},
+			Transport: apiTransport,
+		}
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.Error(w, "only GET and HEAD requests are proxied", http.StatusMethodNotAllowed)
+				return
+			}
+			reverseProxy.ServeHTTP(w, r)
+		})}
+		go server.Serve(listener)
+		servers = append(servers, server)
+		urls[port] = "http://" + listener.Addr().String()
+	}
+
+	// Check the API server lets us through before the transport is chosen.
+	// Its own errors are Status objects; the pod's responses pass through.
+	checkCtx, cancel := context.WithTimeout(ctx, proxyCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(checkCtx, http.MethodGet, urls[ports[0]], nil)
+	if err != nil {
+		stop()
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		stop()
+		return nil, nil, fmt.Errorf("reach API server proxy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		var status metav1.Status
+		if json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&status) == nil && status.Kind == "Status" {
+			stop()
+			return nil, nil, fmt.Errorf("API server proxy denied: %s", status.Message)
+		}
+	}
+
+	return urls, stop, nil
+}
+
+// NewDirectTransport returns a transport that connects to the pod IP, for
+// collection from inside the cluster (e.g. a Tekton step). Pod IPs are also the
+// endpoints of (headless) Services, so network policies admitting Service
+// traffic to the pod admit it too. No API server permissions are needed.
+func NewDirectTransport() Transport {
+	var dialer net.Dialer
+	return &directTransport{dialTimeout: directDialTimeout, dial: dialer.DialContext}
+}
+
+type directTransport struct {
+	dialTimeout time.Duration
+	dial        func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func (t *directTransport) Name() string {
+	return TransportDirect
+}
+
+func (t *directTransport) Connect(ctx context.Context, pod *corev1.Pod, ports []int) (map[int]string, func(), error) {
+	if pod.Status.PodIP == "" {
+		return nil, nil, fmt.Errorf("pod %s has no IP", pod.Name)
+	}
+
+	if err := t.checkReachable(ctx, pod.Status.PodIP, ports); err != nil {
+		return nil, nil, err
+	}
+
+	urls := make(map[int]string, len(ports))
+	for _, port := range ports {
+		urls[port] = "http://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port))
+	}
+	return urls, func() {}, nil
+}
+
+// checkReachable dials the ports of the pod IP concurrently and returns once one
+// answers. A refused connection shows the pod is reachable even though nothing
+// listens on the port (e.g. while scanning for servers). Dropped packets (e.g. by
+// a NetworkPolicy) leave the dials hanging, so all of them share one deadline.
+func (t *directTransport) checkReachable(ctx context.Context, podIP string, ports []int) error {
+	ctx, cancel := context.WithTimeout(ctx, t.dialTimeout)
+	defer cancel()
+
+	results := make(chan error, len(ports))
+	for _, port := range ports {
+		go func(port int) {
+			conn, err := t.dial(ctx, "tcp", net.JoinHostPort(podIP, strconv.Itoa(port)))
+			if err == nil {
+				conn.Close()
+			} else if errors.Is(err, syscall.ECONNREFUSED) {
+				err = nil
+			}
+			results <- err
+		}(port)
+	}
+
+	var lastErr error
+	for range ports {
+		if lastErr = <-results; lastErr == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("pod IP %s not reachable: %w", podIP, lastErr)
+}
+
+// NewFallbackTransport returns a transport that tries each transport in order
+// until one connects
+func NewFallbackTransport(transports ...Transport) Transport {
+	return &fallbackTransport{transports: transports}
+}
+
+type fallbackTransport struct {
+	transports []Transport
+}
+
+func (t *fallbackTransport) Name() string {
+	name := ""
+	for i, transport := range t.transports {
+		if i > 0 {
+			name += ","
+		}
+		name += transport.Name()
+	}
+	return name
+}
+
+func (t *fallbackTransport) Connect(ctx context.Context, pod *corev1.Pod, ports []int) (map[int]string, func(), error) {
+	var errs []error
+	for i, transport := range t.transports {
+		urls, stop, err := transport.Connect(ctx, pod, ports)
+		if err == nil {
+			return urls, stop, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", transport.Name(), err))
+		if i < len(t.transports)-1 {
+			fmt.Printf("  Warning: %s transport failed (%v), trying %s\n", transport.Name(), err, t.transports[i+1].Name())
+		}
+	}
+	return nil, nil, errors.Join(errs...)
+}
+
+// TransportByName returns the transport with the given name (see the
+// Transport* constants) for the client's cluster. Set the coverage token first:
+// the proxy transport cannot send it, so it is left out of the fallbacks when a
+// token is set, and refused when requested explicitly.
+func (c *CoverageClient) TransportByName(name string) (Transport, error) {
+	portForward := NewPortForwardTransport(c.restConfig)
+	fallbacks := []Transport{portForward}
+	withToken := c.bearerToken() != ""
+	if !withToken {
+		fallbacks = append(fallbacks, NewProxyTransport(c.restConfig))
+	}
+	switch name {
+	case TransportPortForward:
+		return portForward, nil
+	case TransportProxy:
+		if withToken {
+			return nil, fmt.Errorf("the %s transport cannot send the coverage token (the API server drops the Authorization header); use %s or %s",
+				TransportProxy, TransportPortForward, TransportDirect)
+		}
+		return NewProxyTransport(c.restConfig), nil
+	case TransportDirect:
+		return NewFallbackTransport(append([]Transport{NewDirectTransport()}, fallbacks...)...), nil
+	case "", TransportAuto:
+		if InCluster() {
+			return NewFallbackTransport(append([]Transport{NewDirectTransport()}, fallbacks...)...), nil
+		}
+		return NewFallbackTransport(fallbacks...), nil
+	}
+	return nil, fmt.Errorf("unknown transport %q (expected %s, %s, %s or %s)",
+		name, TransportAuto, TransportDirect, TransportPortForward, TransportProxy)
+}
+
+// InCluster reports whether coverport runs in a Kubernetes pod
+func InCluster() bool {
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != "" && os.Getenv("KUBERNETES_SERVICE_PORT") != ""
+}
+
+// SetTransport sets how coverage servers in pods are reached (default: port-forward)
+func (c *CoverageClient) SetTransport(transport Transport) {
+	c.transport = transport
+}
+
+// connectPod makes the given ports of the pod reachable through the client's
+// transport, returning the base URL of each port and a function closing the
+// connections
+func (c *CoverageClient) connectPod(ctx context.Context, podName string, ports []int) (map[int]string, func(), error) {
+	if c.clientset == nil || c.restConfig == nil {
+		return nil, nil, fmt.Errorf("kubernetes client not configured")
+	}
+
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("get pod: %w", err)
+	}
+
+	transport := c.transport
+	if transport == nil {
+		transport = NewPortForwardTransport(c.restConfig)
+	}
+	urls, stop, err := transport.Connect(ctx, pod, ports)
+	if err != nil {
+		return nil, nil, err
+	}
+	return urls, func() {
+		stop()
+		for _, baseURL := range urls {
+			c.forgetServerInfo(baseURL + c.pathPrefix)
+		}
+	}, nil
+}
+
+// ConnectPod makes the coverage server on the target port of the pod reachable
+// through the client's transport and keeps it reachable until the returned stop
+// function is called. It returns the server's coverage URL.
+func (c *CoverageClient) ConnectPod(ctx context.Context, podName string, targetPort int) (string, func(), error) {
+	urls, stop, err := c.connectPod(ctx, podName, []int{targetPort})
+	if err != nil {
+		return "", nil, err
+	}
+	return urls[targetPort] + c.pathPrefix + "/coverage", stop, nil
+}
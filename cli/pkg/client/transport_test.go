@@ -0,0 +1,424 @@
+package coverageclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// podAt returns a running pod with the given IP
+func podAt(ip string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-pod", Namespace: "test-ns"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "quay.io/org/app:latest"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+	}
+}
+
+// serverPort returns the port of a test server
+func serverPort(t *testing.T, server *httptest.Server) int {
+	t.Helper()
+	_, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	return port
+}
+
+// freePort returns a local port nothing listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+func TestDirectTransport(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	port := serverPort(t, server)
+	closed := freePort(t)
+
+	transport := NewDirectTransport()
+	urls, stop, err := transport.Connect(context.Background(), podAt("127.0.0.1"), []int{port, closed})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer stop()
+
+	if urls[port] != server.URL {
+		t.Errorf("got URL %q, want %q", urls[port], server.URL)
+	}
+	if want := "http://127.0.0.1:" + strconv.Itoa(closed); urls[closed] != want {
+		t.Errorf("got URL %q, want %q", urls[closed], want)
+	}
+}
+
+func TestDirectTransport_RefusedIsReachable(t *testing.T) {
+	// Scanning for servers hits ports nothing listens on
+	transport := NewDirectTransport()
+	if _, _, err := transport.Connect(context.Background(), podAt("127.0.0.1"), []int{freePort(t)}); err != nil {
+		t.Errorf("expected refused port to count as reachable, got %v", err)
+	}
+}
+
+func TestDirectTransport_DroppedPackets(t *testing.T) {
+	// A NetworkPolicy dropping packets leaves every dial hanging
+	transport := &directTransport{
+		dialTimeout: 100 * time.Millisecond,
+		dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	start := time.Now()
+	if _, _, err := transport.Connect(context.Background(), podAt("10.0.0.1"), DefaultCoveragePorts()); err == nil {
+		t.Fatal("expected error for unreachable pod IP")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("reachability check took %s, want one dial timeout for all ports", elapsed)
+	}
+}
+
+func TestDirectTransport_NoPodIP(t *testing.T) {
+	transport := NewDirectTransport()
+	if _, _, err := transport.Connect(context.Background(), podAt(""), []int{53700}); err == nil {
+		t.Error("expected error for pod without IP")
+	}
+}
+
+// stubTransport connects to fixed URLs or fails
+type stubTransport struct {
+	name      string
+	err       error
+	connected bool
+}
+
+func (t *stubTransport) Name() string { return t.name }
+
+func (t *stubTransport) Connect(ctx context.Context, pod *corev1.Pod, ports []int) (map[int]string, func(), error) {
+	if t.err != nil {
+		return nil, nil, t.err
+	}
+	t.connected = true
+	urls := make(map[int]string)
+	for _, port := range ports {
+		urls[port] = "http://" + t.name + ":" + strconv.Itoa(port)
+	}
+	return urls, func() {}, nil
+}
+
+func TestFallbackTransport(t *testing.T) {
+	failing := &stubTransport{name: "failing", err: errors.New("blocked")}
+	working := &stubTransport{name: "working"}
+	unused := &stubTransport{name: "unused"}
+
+	transport := NewFallbackTransport(failing, working, unused)
+	if transport.Name() != "failing,working,unused" {
+		t.Errorf("unexpected name %q", transport.Name())
+	}
+
+	urls, _, err := transport.Connect(context.Background(), podAt("10.0.0.1"), []int{53700})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if urls[53700] != "http://working:53700" {
+		t.Errorf("got URL %q from the wrong transport", urls[53700])
+	}
+	if unused.connected {
+		t.Error("transports after the first working one should not be tried")
+	}
+
+	transport = NewFallbackTransport(failing, &stubTransport{name: "other", err: errors.New("denied")})
+	_, _, err = transport.Connect(context.Background(), podAt("10.0.0.1"), []int{53700})
+	if err == nil || !strings.Contains(err.Error(), "blocked") || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("expected errors of all transports, got %v", err)
+	}
+}
+
+func TestTransportByName(t *testing.T) {
+	client := &CoverageClient{restConfig: &rest.Config{Host: "https://api.example.com"}}
+
+	tests := []struct {
+		name      string
+		inCluster bool
+		want      string
+		wantErr   bool
+	}{
+		{name: "port-forward", want: "port-forward"},
+		{name: "proxy", want: "proxy"},
+		{name: "direct", want: "direct,port-forward,proxy"},
+		{name: "auto", want: "port-forward,proxy"},
+		{name: "", want: "port-forward,proxy"},
+		{name: "auto", inCluster: true, want: "direct,port-forward,proxy"},
+		{name: "tunnel", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		host, port := "", ""
+		if tt.inCluster {
+			host, port = "10.96.0.1", "443"
+		}
+		t.Setenv("KUBERNETES_SERVICE_HOST", host)
+		t.Setenv("KUBERNETES_SERVICE_PORT", port)
+
+		transport, err := client.TransportByName(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("TransportByName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && transport.Name() != tt.want {
+			t.Errorf("TransportByName(%q) in cluster=%v = %q, want %q", tt.name, tt.inCluster, transport.Name(), tt.want)
+		}
+	}
+}
+
+func TestTransportByName_WithToken(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	client := &CoverageClient{restConfig: &rest.Config{Host: "https://api.example.com"}, httpClient: &http.Client{}}
+	client.SetToken("secret")
+
+	// The proxy transport cannot send the token
+	for name, want := range map[string]string{"auto": "port-forward", "direct": "direct,port-forward", "port-forward": "port-forward"} {
+		transport, err := client.TransportByName(name)
+		if err != nil {
+			t.Errorf("TransportByName(%q) failed: %v", name, err)
+			continue
+		}
+		if transport.Name() != want {
+			t.Errorf("TransportByName(%q) with token = %q, want %q", name, transport.Name(), want)
+		}
+	}
+	if _, err := client.TransportByName("proxy"); err == nil || !strings.Contains(err.Error(), "token") {
+		t.Errorf("expected error for the proxy transport with a token, got %v", err)
+	}
+}
+
+func TestCollectCoverageFromPod_Direct(t *testing.T) {
+	response := CoverageResponse{
+		MetaFilename:     "covmeta.test",
+		MetaData:         base64.StdEncoding.EncodeToString([]byte("meta")),
+		CountersFilename: "covcounters.test",
+		CountersData:     base64.StdEncoding.EncodeToString([]byte("counters")),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/debug/coverage/info":
+			json.NewEncoder(w).Encode(ServerInfo{Format: "go", ProtocolVersion: ProtocolVersion})
+		case "/debug/coverage":
+			json.NewEncoder(w).Encode(response)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	port := serverPort(t, server)
+
+	tempDir := t.TempDir()
+	client := &CoverageClient{
+		clientset:  fake.NewSimpleClientset(podAt("127.0.0.1")),
+		restConfig: &rest.Config{},
+		namespace:  "test-ns",
+		outputDir:  tempDir,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	client.SetPathPrefix("/debug")
+	client.SetTransport(NewDirectTransport())
+
+	if err := client.CollectCoverageFromPodWithContainer(context.Background(), "app-pod", "app", "direct-test", port); err != nil {
+		t.Fatalf("CollectCoverageFromPodWithContainer failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "direct-test", "covcounters.test"))
+	if err != nil || string(data) != "counters" {
+		t.Errorf("counters not collected: %q, %v", data, err)
+	}
+	if _, ok := client.serverInfo[server.URL+"/debug"]; ok {
+		t.Error("server info of the pod should be dropped after collection")
+	}
+}
+
+func TestProbeServers_WaitsForFirstPort(t *testing.T) {
+	port := freePort(t)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Art-Coverage-Server", "go")
+		w.Header().Set("X-Art-Coverage-Binary", "app")
+	}))
+
+	// The server only starts listening after the scan began
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		time.Sleep(300 * time.Millisecond)
+		listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
+		if err != nil {
+			t.Errorf("listen: %v", err)
+			return
+		}
+		server.Listener = listener
+		server.Start()
+	}()
+	t.Cleanup(func() {
+		<-started
+		server.Close()
+	})
+
+	client := &CoverageClient{
+		clientset:  fake.NewSimpleClientset(podAt("127.0.0.1")),
+		restConfig: &rest.Config{},
+		namespace:  "test-ns",
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+	client.SetTransport(NewDirectTransport())
+
+	servers, err := client.probeServers(context.Background(), "app-pod", []int{port, freePort(t)}, true)
+	if err != nil {
+		t.Fatalf("probeServers failed: %v", err)
+	}
+	if len(servers) != 1 || servers[0].Port != port || servers[0].Binary != "app" {
+		t.Errorf("unexpected servers: %+v", servers)
+	}
+}
+
+func TestConnectPod(t *testing.T) {
+	client := &CoverageClient{
+		clientset:  fake.NewSimpleClientset(podAt("10.0.0.1")),
+		restConfig: &rest.Config{},
+		namespace:  "test-ns",
+	}
+	client.SetPathPrefix("debug")
+	client.SetTransport(&stubTransport{name: "pod"})
+
+	coverageURL, stop, err := client.ConnectPod(context.Background(), "app-pod", 53700)
+	if err != nil {
+		t.Fatalf("ConnectPod failed: %v", err)
+	}
+	defer stop()
+	if coverageURL != "http://pod:53700/debug/coverage" {
+		t.Errorf("unexpected coverage URL %q", coverageURL)
+	}
+
+	if _, _, err := client.ConnectPod(context.Background(), "missing-pod", 53700); err == nil {
+		t.Error("expected error for missing pod")
+	}
+}
+
+// newAPIServer returns a fake API server proxying to pods with the handler
+func newAPIServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *rest.Config) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, &rest.Config{Host: server.URL, BearerToken: "api-token"}
+}
+
+func TestProxyTransport(t *testing.T) {
+	var paths []string
+	_, config := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer api-token" {
+			t.Errorf("API server got Authorization %q", got)
+		}
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("X-Art-Coverage-Server", "go")
+		w.Write([]byte("ok"))
+	})
+
+	urls, stop, err := NewProxyTransport(config).Connect(context.Background(), podAt("10.0.0.1"), []int{53700, 53701})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer stop()
+
+	client := &CoverageClient{httpClient: &http.Client{Timeout: 10 * time.Second}}
+	client.SetToken("coverage-token")
+	resp, err := client.httpClient.Get(urls[53701] + "/debug/coverage/info")
+	if err != nil {
+		t.Fatalf("request through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Art-Coverage-Server") != "go" {
+		t.Error("pod response headers should pass through the proxy")
+	}
+
+	want := "/api/v1/namespaces/test-ns/pods/app-pod:53701/proxy/debug/coverage/info"
+	if len(paths) != 2 || paths[1] != want {
+		t.Errorf("API server got paths %v, want %s last", paths, want)
+	}
+
+	// Other methods would need the create permission on pods/proxy
+	resp, err = client.httpClient.Post(urls[53701]+"/debug/coverage/push", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || len(paths) != 2 {
+		t.Errorf("POST got %d and reached the API server: %v", resp.StatusCode, len(paths) != 2)
+	}
+}
+
+func TestProxyTransport_Denied(t *testing.T) {
+	_, config := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Message:  `pods "app-pod" is forbidden: cannot get resource "pods/proxy"`,
+		})
+	})
+
+	_, _, err := NewProxyTransport(config).Connect(context.Background(), podAt("10.0.0.1"), []int{53700})
+	if err == nil || !strings.Contains(err.Error(), "pods/proxy") {
+		t.Errorf("expected permission error, got %v", err)
+	}
+}
+
+func TestProxyTransport_PodUnauthorized(t *testing.T) {
+	// A coverage server requiring a token answers 401 itself; the proxy works
+	_, config := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+
+	_, stop, err := NewProxyTransport(config).Connect(context.Background(), podAt("10.0.0.1"), []int{53700})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	stop()
+}
+
+func TestPortForwardTransport_FailsFast(t *testing.T) {
+	// An API server (or proxy) refusing the upgrade ends the forwarder early
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+	config := &rest.Config{Host: server.URL, TLSClientConfig: rest.TLSClientConfig{Insecure: true}}
+
+	start := time.Now()
+	_, _, err := NewPortForwardTransport(config).Connect(context.Background(), podAt("10.0.0.1"), []int{53700})
+	if err == nil {
+		t.Fatal("expected error for refused upgrade")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("port forward failure took %v", elapsed)
+	}
+}
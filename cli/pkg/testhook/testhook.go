@@ -0,0 +1,303 @@
+// Package testhook captures coverage per test for e2e suites such as Ginkgo.
+//
+// A Recorder resets the coverage counters of every target before a test runs
+// and collects them once it finishes, storing each test's coverage in its own
+// directory and indexing it in the collection manifest (metadata.json). This
+// gives test-to-code traceability without custom glue in every repository.
+//
+// Counters are process-global in the instrumented application, so per-test
+// coverage is only accurate when tests hitting the same targets run serially.
+// Go applications must be built with -covermode=atomic for counters to be reset.
+//
+// Example with Ginkgo:
+//
+//	var recorder *testhook.Recorder
+//
+//	var _ = BeforeSuite(func() {
+//		var err error
+//		recorder, err = testhook.NewRecorder("./coverage-output", "e2e", []testhook.Target{
+//			{Component: "backend", Namespace: "test", PodName: "backend-7d9f", Port: 53700},
+//		})
+//		Expect(err).NotTo(HaveOccurred())
+//	})
+//
+//	var _ = BeforeEach(func() {
+//		Expect(recorder.BeforeTest(CurrentSpecReport().FullText())).To(Succeed())
+//	})
+//
+//	var _ = AfterEach(func() {
+//		report := CurrentSpecReport()
+//		Expect(recorder.AfterTest(report.FullText(), report.Failed())).To(Succeed())
+//	})
+//
+//	var _ = AfterSuite(func() {
+//		recorder.Close()
+//	})
+package testhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konflux-ci/coverport/cli/internal/manifest"
+	coverageclient "github.com/konflux-ci/coverport/cli/pkg/client"
+)
+
+const (
+	// DefaultPort is the coverage server port used when a Target does not set one
+	DefaultPort = 53700
+
+	// testsDir is the directory (per component) holding per-test coverage
+	testsDir = "tests"
+
+	// maxDirNameLength caps the directory name derived from a test name
+	maxDirNameLength = 80
+)
+
+// Target describes a coverage server to reset and collect around each test.
+// Either URL or Namespace/PodName must be set.
+type Target struct {
+	Component     string // Component name, used for the output directory and manifest
+	URL           string // Direct coverage endpoint (e.g. http://localhost:53700/coverage)
+	Namespace     string // Pod namespace (Kubernetes targets)
+	PodName       string // Pod name (Kubernetes targets)
+	ContainerName string // Container running the coverage server (informational)
+	Port          int    // Coverage server port in the pod (default: 53700)
+	PathPrefix    string // Path prefix of the coverage endpoints in the pod (e.g. /debug)
+	Token         string // Bearer token for the coverage server (default: COVERAGE_TOKEN env var)
+	Transport     string // How the pod is reached: auto (default), direct, port-forward or proxy
+
+	TLS *coverageclient.TLSOptions // TLS settings for HTTPS URL targets
+}
+
+// target is a Target with an open connection to its coverage server
+type target struct {
+	Target
+	client      *coverageclient.CoverageClient
+	coverageURL string
+	stop        func()
+}
+
+// Recorder resets and collects coverage around each test
+type Recorder struct {
+	outputDir string
+	manifest  *manifest.CollectionManifest
+	targets   []*target
+	usedDirs  map[string]bool // Test directory names already recorded
+	current   string          // Test the counters were last reset for
+	mu        sync.Mutex
+}
+
+// NewRecorder creates a Recorder that writes per-test coverage under outputDir.
+// Kubernetes targets are connected once (see Target.Transport) and kept open until
+// Close is called.
+// If outputDir already holds a manifest, new tests are appended to it.
+func NewRecorder(outputDir, runName string, targets []Target) (*Recorder, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets specified")
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("create output directory: %w", err)
+	}
+
+	collectionManifest := manifest.NewCollectionManifest(runName, manifest.CollectionParameters{})
+	if manifest.Exists(outputDir) {
+		existing, err := manifest.Load(outputDir)
+		if err != nil {
+			return nil, fmt.Errorf("load existing manifest: %w", err)
+		}
+		collectionManifest = existing
+	}
+
+	r := &Recorder{
+		outputDir: outputDir,
+		manifest:  collectionManifest,
+		usedDirs:  make(map[string]bool),
+	}
+	for _, test := range collectionManifest.Tests {
+		r.usedDirs[filepath.Base(test.CoverageDir)] = true
+	}
+
+	for _, t := range targets {
+		connected, err := connectTarget(outputDir, t)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("connect to %s: %w", t.Component, err)
+		}
+		r.targets = append(r.targets, connected)
+	}
+
+	return r, nil
+}
+
+// connectTarget creates a coverage client for the target and, for Kubernetes
+// targets, connects to the pod
+func connectTarget(outputDir string, t Target) (*target, error) {
+	if t.Component == "" {
+		return nil, fmt.Errorf("target component name is required")
+	}
+
+	componentDir := filepath.Join(outputDir, t.Component)
+
+	if t.URL != "" {
+		client, err := coverageclient.NewClientForURL(componentDir)
+		if err != nil {
+			return nil, fmt.Errorf("create coverage client: %w", err)
+		}
+		if t.Token != "" {
+			client.SetToken(t.Token)
+		}
+		if t.TLS != nil {
+			if err := client.SetTLSConfig(*t.TLS); err != nil {
+				return nil, fmt.Errorf("configure TLS: %w", err)
+			}
+		}
+		return &target{Target: t, client: client, coverageURL: t.URL}, nil
+	}
+
+	if t.Namespace == "" || t.PodName == "" {
+		return nil, fmt.Errorf("either URL or namespace and pod name are required")
+	}
+	if t.Port == 0 {
+		t.Port = DefaultPort
+	}
+
+	client, err := coverageclient.NewClient(t.Namespace, componentDir)
+	if err != nil {
+		return nil, fmt.Errorf("create coverage client: %w", err)
+	}
+	client.SetPathPrefix(t.PathPrefix)
+	if t.Token != "" {
+		client.SetToken(t.Token)
+	}
+
+	transport, err := client.TransportByName(t.Transport)
+	if err != nil {
+		return nil, err
+	}
+	client.SetTransport(transport)
+
+	coverageURL, stop, err := client.ConnectPod(context.Background(), t.PodName, t.Port)
+	if err != nil {
+		return nil, fmt.Errorf("connect to pod: %w", err)
+	}
+
+	return &target{
+		Target:      t,
+		client:      client,
+		coverageURL: coverageURL,
+		stop:        stop,
+	}, nil
+}
+
+// BeforeTest resets the coverage counters of all targets and remembers the
+// test, so that AfterTest can detect tests overlapping on the same targets
+func (r *Recorder) BeforeTest(testName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.current = testName
+	var errs []error
+	for _, t := range r.targets {
+		if err := t.client.ResetCoverageFromURL(t.coverageURL); err != nil {
+			errs = append(errs, fmt.Errorf("reset %s: %w", t.Component, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AfterTest collects the coverage of all targets into a directory named after
+// the test, records it in the manifest and saves the manifest. It reports an
+// error (but still collects) if the counters were last reset for another test,
+// as the coverage then also holds that test's.
+func (r *Recorder) AfterTest(testName string, failed bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	if r.current != "" && r.current != testName {
+		errs = append(errs, fmt.Errorf("coverage of %q was reset for %q: tests hitting the same targets must run serially", testName, r.current))
+	}
+	r.current = ""
+
+	dirName := r.uniqueDirName(testName)
+
+	for _, t := range r.targets {
+		relDir := filepath.Join(testsDir, dirName)
+		if err := t.client.CollectCoverageFromURL(t.coverageURL, relDir); err != nil {
+			errs = append(errs, fmt.Errorf("collect %s: %w", t.Component, err))
+			continue
+		}
+
+		r.manifest.AddTest(manifest.TestInfo{
+			Name:        testName,
+			Component:   t.Component,
+			CoverageDir: filepath.Join(t.Component, relDir),
+			Failed:      failed,
+			CollectedAt: time.Now().Format(time.RFC3339),
+		})
+	}
+
+	if err := r.manifest.Save(r.outputDir); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close closes all pod connections opened by the Recorder
+func (r *Recorder) Close() {
+	for _, t := range r.targets {
+		if t.stop != nil {
+			t.stop()
+		}
+	}
+}
+
+// uniqueDirName returns a directory name for the test, adding a suffix when the
+// same test is recorded more than once (e.g. flake retries)
+func (r *Recorder) uniqueDirName(testName string) string {
+	name := TestDirName(testName)
+	candidate := name
+	for i := 2; r.usedDirs[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	r.usedDirs[candidate] = true
+	return candidate
+}
+
+// TestDirName converts a test name into a filesystem-safe directory name.
+// A short hash of the full name keeps names unique after sanitizing and truncation.
+func TestDirName(testName string) string {
+	var b strings.Builder
+	lastDash := true
+	for _, r := range strings.ToLower(testName) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_':
+			b.WriteRune(r)
+			lastDash = false
+		case !lastDash:
+			b.WriteRune('-')
+			lastDash = true
+		}
+	}
+
+	name := strings.Trim(b.String(), "-.")
+	if len(name) > maxDirNameLength {
+		name = strings.TrimRight(name[:maxDirNameLength], "-.")
+	}
+	if name == "" {
+		name = "test"
+	}
+
+	sum := sha256.Sum256([]byte(testName))
+	return fmt.Sprintf("%s-%x", name, sum[:4])
+}
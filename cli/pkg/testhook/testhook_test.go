@@ -0,0 +1,220 @@
+package testhook
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/konflux-ci/coverport/cli/internal/manifest"
+)
+
+func newFakeCoverageServer(t *testing.T, resets *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/coverage/reset":
+			*resets++
+			w.Write([]byte("Coverage counters reset"))
+		case "/coverage":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"meta_filename":     "covmeta.abc",
+				"meta_data":         base64.StdEncoding.EncodeToString([]byte("meta")),
+				"counters_filename": "covcounters.abc.1.1",
+				"counters_data":     base64.StdEncoding.EncodeToString([]byte("counters")),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestRecorder_BeforeAndAfterTest(t *testing.T) {
+	resets := 0
+	server := newFakeCoverageServer(t, &resets)
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	recorder, err := NewRecorder(outputDir, "e2e", []Target{
+		{Component: "backend", URL: server.URL + "/coverage"},
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+
+	for _, name := range []string{"Users creates a user", "Users deletes a user"} {
+		if err := recorder.BeforeTest(name); err != nil {
+			t.Fatalf("BeforeTest failed: %v", err)
+		}
+		if err := recorder.AfterTest(name, false); err != nil {
+			t.Fatalf("AfterTest failed: %v", err)
+		}
+	}
+
+	if resets != 2 {
+		t.Errorf("expected 2 resets, got %d", resets)
+	}
+
+	loaded, err := manifest.Load(outputDir)
+	if err != nil {
+		t.Fatalf("Load manifest failed: %v", err)
+	}
+	if len(loaded.Tests) != 2 {
+		t.Fatalf("expected 2 tests in manifest, got %d", len(loaded.Tests))
+	}
+
+	first := loaded.Tests[0]
+	if first.Name != "Users creates a user" || first.Component != "backend" {
+		t.Errorf("unexpected test entry: %+v", first)
+	}
+	if !strings.HasPrefix(first.CoverageDir, filepath.Join("backend", "tests", "users-creates-a-user-")) {
+		t.Errorf("unexpected coverage dir: %s", first.CoverageDir)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, first.CoverageDir, "covcounters.abc.1.1")); err != nil {
+		t.Errorf("counters file not written: %v", err)
+	}
+}
+
+func TestRecorder_RepeatedTestGetsUniqueDir(t *testing.T) {
+	resets := 0
+	server := newFakeCoverageServer(t, &resets)
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	recorder, err := NewRecorder(outputDir, "e2e", []Target{
+		{Component: "backend", URL: server.URL + "/coverage"},
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	recorder.AfterTest("flaky spec", true)
+	recorder.AfterTest("flaky spec", false)
+
+	// A new recorder appending to the same manifest must not reuse directories
+	again, err := NewRecorder(outputDir, "e2e", []Target{
+		{Component: "backend", URL: server.URL + "/coverage"},
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	again.AfterTest("flaky spec", false)
+
+	loaded, _ := manifest.Load(outputDir)
+	if len(loaded.Tests) != 3 {
+		t.Fatalf("expected 3 tests in manifest, got %d", len(loaded.Tests))
+	}
+
+	seen := make(map[string]bool)
+	for _, test := range loaded.Tests {
+		if seen[test.CoverageDir] {
+			t.Errorf("coverage dir reused: %s", test.CoverageDir)
+		}
+		seen[test.CoverageDir] = true
+	}
+	if !loaded.Tests[0].Failed || loaded.Tests[1].Failed {
+		t.Errorf("failed flag not recorded correctly: %+v", loaded.Tests)
+	}
+}
+
+func TestRecorder_ResetFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "please use -covermode=atomic", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	recorder, err := NewRecorder(t.TempDir(), "e2e", []Target{
+		{Component: "backend", URL: server.URL + "/coverage"},
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	err = recorder.BeforeTest("spec")
+	if err == nil || !strings.Contains(err.Error(), "reset backend") {
+		t.Errorf("expected reset error naming the component, got: %v", err)
+	}
+}
+
+func TestRecorder_OverlappingTests(t *testing.T) {
+	resets := 0
+	server := newFakeCoverageServer(t, &resets)
+	defer server.Close()
+
+	recorder, err := NewRecorder(t.TempDir(), "e2e", []Target{
+		{Component: "backend", URL: server.URL + "/coverage"},
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	// Two tests running in parallel against the same targets
+	recorder.BeforeTest("first spec")
+	recorder.BeforeTest("second spec")
+	if err := recorder.AfterTest("first spec", false); err == nil || !strings.Contains(err.Error(), "must run serially") {
+		t.Errorf("expected an error for overlapping tests, got: %v", err)
+	}
+	if err := recorder.AfterTest("second spec", false); err != nil {
+		t.Errorf("AfterTest without a pending reset failed: %v", err)
+	}
+	if len(recorder.manifest.Tests) != 2 {
+		t.Errorf("expected both tests to be collected, got %d", len(recorder.manifest.Tests))
+	}
+}
+
+func TestNewRecorder_InvalidTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []Target
+	}{
+		{name: "no targets", targets: nil},
+		{name: "missing component", targets: []Target{{URL: "http://localhost:53700/coverage"}}},
+		{name: "missing pod", targets: []Target{{Component: "backend", Namespace: "test"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRecorder(t.TempDir(), "e2e", tt.targets); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestTestDirName(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantPrefix string
+	}{
+		{name: "simple", input: "creates a user", wantPrefix: "creates-a-user-"},
+		{name: "ginkgo full text", input: "[It] Users [sig-auth] should log in", wantPrefix: "it-users-sig-auth-should-log-in-"},
+		{name: "only symbols", input: "!!!", wantPrefix: "test-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TestDirName(tt.input)
+			if !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("TestDirName(%q) = %q, want prefix %q", tt.input, got, tt.wantPrefix)
+			}
+			if len(got) != len(tt.wantPrefix)+8 {
+				t.Errorf("TestDirName(%q) = %q, expected 8 hash characters", tt.input, got)
+			}
+		})
+	}
+
+	long := TestDirName(strings.Repeat("a", 200))
+	if len(long) > maxDirNameLength+9 {
+		t.Errorf("long name not truncated: %d chars", len(long))
+	}
+	if TestDirName("a b") == TestDirName("a-b") {
+		t.Error("different test names should produce different directory names")
+	}
+}
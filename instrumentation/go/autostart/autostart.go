@@ -0,0 +1,27 @@
+// Package autostart starts a coverage server when it is imported, configured
+// from the COVERAGE_* environment variables (see the coverage package):
+//
+//	import _ "github.com/konflux-ci/coverport/instrumentation/go/autostart"
+//
+// The server runs for the lifetime of the process. Use coverage.NewServer
+// directly to control when it starts and stops.
+package autostart
+
+import (
+	"context"
+	"log"
+
+	coverage "github.com/konflux-ci/coverport/instrumentation/go"
+)
+
+func init() {
+	opts, err := coverage.OptionsFromEnv()
+	if err != nil {
+		log.Printf("[COVERAGE] ERROR: %v; not starting coverage server", err)
+		return
+	}
+
+	if err := coverage.NewServer(opts).Start(context.Background()); err != nil {
+		log.Printf("[COVERAGE] ERROR: %v", err)
+	}
+}
@@ -7,6 +7,20 @@ package coverage
 // tries ports starting at DefaultPort (or COVERAGE_PORT) and increments
 // up to MaxRetries times until it finds a free port.
 //
+// Importing this package does not start a server. Blank-import the autostart
+// subpackage to start one configured from the environment variables below:
+//   import _ "github.com/konflux-ci/coverport/instrumentation/go/autostart"
+// or create a Server to control its lifecycle (e.g. in tests):
+//   srv := coverage.NewServer(coverage.Options{Addr: "127.0.0.1", Port: 53700})
+//   if err := srv.Start(ctx); err != nil { ... }
+//   defer srv.Shutdown(context.Background())
+//
+// Applications that cannot open an extra TCP port can mount the endpoints on
+// their own server under a prefix, or serve them on a Unix socket
+// (COVERAGE_SOCKET or Options.SocketPath):
+//   srv := coverage.NewServer(coverage.Options{Prefix: "/debug"})
+//   appMux.Handle("/debug/", srv.Handler())
+//
 // Clients can identify a coverage server by sending a HEAD request to any
 // endpoint: the response will include the headers:
 //   X-Art-Coverage-Server:         1
@@ -16,31 +30,94 @@ package coverage
 //   X-Art-Coverage-Source-Url:     <url>     (if SOURCE_GIT_URL is set)
 //   X-Art-Coverage-Software-Group: <group>   (if SOFTWARE_GROUP or __doozer_group is set)
 //   X-Art-Coverage-Software-Key:   <key>     (if SOFTWARE_KEY or __doozer_key is set)
+//   X-Art-Coverage-Format:         go
+//   X-Art-Coverage-Protocol:       <protocol version>
+//   X-Art-Coverage-Capabilities:   <comma-separated capabilities>
+//
+// GET /coverage/info returns the same information as JSON, so clients can
+// negotiate optional features instead of guessing:
+//   {"format": "go", "protocol_version": 1, "capabilities": ["reset", "nometa", "push"], ...}
 //
 // Pass ?nometa=1 to /coverage to skip metadata collection on subsequent
 // requests (metadata does not change for the lifetime of the process).
+//
+// Coverage files left in GOCOVERDIR by other processes (e.g. short-lived
+// subprocesses built with -cover) are included in /coverage responses.
+// Metadata files are deduplicated by hash against this binary's metadata.
+//
+// /coverage returns JSON with base64-encoded files by default. Clients sending
+// "Accept: application/x-tar" receive the covmeta/covcounters files as a tar
+// stream instead, gzip-compressed if they also send "Accept-Encoding: gzip".
+// zstd is not offered: the standard library has no encoder and this package
+// has no dependencies.
+//
+// GET /coverage/reset clears the counters so that the next /coverage request
+// only reports code executed after the reset (e.g. per-test coverage). This
+// requires the binary to be built with -covermode=atomic.
+//
+// Coverage of pods that are rolled, evicted or scaled down is lost unless it is
+// dumped before the process exits. GET or POST /coverage/push dumps the current
+// coverage to the destinations below and is meant to be used as a preStop hook:
+//   COVERAGE_PUSH_URL:         POST the /coverage JSON to a collector (e.g. coverport agent)
+//   COVERAGE_DUMP_DIR:         write covmeta/covcounters files to <dir>/<component>/<pod>-<pid>/
+//   COVERAGE_COMPONENT:        component name sent with the dump (default: binary name)
+//   COVERAGE_DUMP_ON_SIGTERM:  set to 1 or true to also dump when the process receives SIGTERM
+//
+// Set COVERAGE_TOKEN (or COVERAGE_TOKEN_FILE, e.g. a mounted Secret) to require
+// "Authorization: Bearer <token>" on all endpoints. HEAD requests to /coverage
+// (identity probing) and /health stay unauthenticated.
+//
+// Set COVERAGE_TLS_CERT and COVERAGE_TLS_KEY (e.g. a mounted kubernetes.io/tls
+// Secret) to serve HTTPS. COVERAGE_TLS_CLIENT_CA additionally requires clients
+// to present a certificate signed by one of the CAs in the bundle (mTLS).
+//
+// Pushed requests carry the identification headers above plus:
+//   X-Art-Coverage-Component:  <component>
+//   X-Art-Coverage-Pod:        <hostname>
+//   X-Art-Coverage-Namespace:  <namespace>  (if POD_NAMESPACE is set)
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime/coverage"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 const (
 	DefaultPort = 53700 // Starting port for the coverage server
 	MaxRetries  = 50    // Maximum number of ports to try
+
+	// ProtocolVersion is the version of the coverage server wire protocol
+	// shared by the Go, Python, Node.js and Rust servers
+	ProtocolVersion = 1
+
+	// Format is the coverage format served by this server
+	Format = "go"
 )
 
+// Capabilities lists the optional protocol features supported by this server
+var Capabilities = []string{"reset", "nometa", "push", "streaming", "gocoverdir"}
+
 // metaHashOnce ensures the metadata hash is computed exactly once.
 // The hash is process-stable so it never changes after the first read.
 var (
@@ -55,21 +132,142 @@ type CoverageResponse struct {
 	CountersFilename string `json:"counters_filename"`
 	CountersData     string `json:"counters_data"` // base64 encoded
 	Timestamp        int64  `json:"timestamp"`
+	Format           string `json:"format"`
+	ProtocolVersion  int    `json:"protocol_version"`
+
+	// Coverage files of other processes found in GOCOVERDIR
+	CoverDirFiles []CoverDirFile `json:"gocoverdir_files,omitempty"`
+}
+
+// CoverDirFile is a coverage file found in GOCOVERDIR
+type CoverDirFile struct {
+	Name string `json:"name"`
+	Data string `json:"data"` // base64 encoded
+}
+
+// InfoResponse represents the JSON response from the /coverage/info endpoint
+type InfoResponse struct {
+	Format          string   `json:"format"`
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
+	Pid             int      `json:"pid"`
+	Binary          string   `json:"binary"`
+}
+
+// Options configures a Server. Use OptionsFromEnv for the configuration
+// documented above.
+type Options struct {
+	Addr          string         // Bind address without port (default: all interfaces)
+	Port          int            // First port to try (default: DefaultPort)
+	MaxRetries    int            // Number of successive ports to try (default: MaxRetries)
+	SocketPath    string         // Listen on this Unix socket instead of a TCP port
+	Prefix        string         // Path prefix of the endpoints, e.g. "/debug" (default: none)
+	Mux           *http.ServeMux // Mux to register the coverage endpoints on (default: a new mux)
+	Logger        *log.Logger    // Logger for server messages (default: the standard logger)
+	Token         string         // Bearer token required by the endpoints (default: none)
+	TLSConfig     *tls.Config    // Serve HTTPS with this configuration (default: plain HTTP)
+	PushOnSigterm bool           // Push coverage when the process receives SIGTERM
+}
+
+// coveragePaths are the endpoints served by a Server, relative to Options.Prefix
+var coveragePaths = []string{"/coverage", "/coverage/info", "/coverage/reset", "/coverage/push", "/health"}
+
+// Server is a coverage server. Create it with NewServer, then call Start.
+type Server struct {
+	opts   Options
+	logger *log.Logger
+
+	mu       sync.Mutex
+	server   *http.Server
+	listener net.Listener
+	done     chan struct{} // Closed by Shutdown
+
+	mountOnce sync.Once // Mounts the coverage endpoints on Options.Mux
+}
+
+// defaultServer backs the package-level handlers
+var defaultServer = NewServer(Options{})
+
+// NewServer creates a coverage server with the given options
+func NewServer(opts Options) *Server {
+	if opts.Port == 0 {
+		opts.Port = DefaultPort
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = MaxRetries
+	}
+	opts.Prefix = strings.TrimSuffix(opts.Prefix, "/")
+	if opts.Prefix != "" && !strings.HasPrefix(opts.Prefix, "/") {
+		opts.Prefix = "/" + opts.Prefix
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Server{opts: opts, logger: logger}
+}
+
+// OptionsFromEnv returns the options configured by COVERAGE_PORT, COVERAGE_SOCKET,
+// COVERAGE_TOKEN, COVERAGE_TOKEN_FILE, COVERAGE_TLS_* and COVERAGE_DUMP_ON_SIGTERM
+func OptionsFromEnv() (Options, error) {
+	opts := Options{SocketPath: os.Getenv("COVERAGE_SOCKET")}
+	if envPort := os.Getenv("COVERAGE_PORT"); envPort != "" {
+		if p, err := strconv.Atoi(envPort); err == nil && p > 0 {
+			opts.Port = p
+		}
+	}
+
+	token, err := loadToken()
+	if err != nil {
+		return Options{}, err
+	}
+	opts.Token = token
+
+	tlsConfig, err := loadTLSConfig()
+	if err != nil {
+		return Options{}, err
+	}
+	opts.TLSConfig = tlsConfig
+
+	dumpOnSigterm := os.Getenv("COVERAGE_DUMP_ON_SIGTERM")
+	opts.PushOnSigterm = dumpOnSigterm == "1" || dumpOnSigterm == "true"
+
+	return opts, nil
 }
 
-func init() {
-	// Start coverage server in a separate goroutine
-	go startCoverageServer()
+// binaryName returns the base name of the running executable
+func binaryName() string {
+	if exePath, err := os.Executable(); err == nil {
+		return filepath.Base(exePath)
+	}
+	return "unknown"
 }
 
 // identityMiddleware wraps a handler to add identification headers to
 // every response (including HEAD requests) so that clients can confirm they
 // are talking to a coverage server rather than an unrelated process.
 func identityMiddleware(next http.Handler) http.Handler {
-	pid := strconv.Itoa(os.Getpid())
-	exe := "unknown"
-	if exePath, err := os.Executable(); err == nil {
-		exe = filepath.Base(exePath)
+	headers := identityHeaders()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for header, val := range headers {
+			w.Header().Set(header, val)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// identityHeaders returns the headers identifying this coverage server.
+// Optional headers are omitted when their environment variable is not set.
+func identityHeaders() map[string]string {
+	headers := map[string]string{
+		"X-Art-Coverage-Server": "1",
+		"X-Art-Coverage-Pid":    strconv.Itoa(os.Getpid()),
+		"X-Art-Coverage-Binary": binaryName(),
+
+		"X-Art-Coverage-Format":       Format,
+		"X-Art-Coverage-Protocol":     strconv.Itoa(ProtocolVersion),
+		"X-Art-Coverage-Capabilities": strings.Join(Capabilities, ","),
 	}
 
 	softwareGroup := os.Getenv("SOFTWARE_GROUP")
@@ -86,142 +284,648 @@ func identityMiddleware(next http.Handler) http.Handler {
 		"X-Art-Coverage-Software-Group": softwareGroup,
 		"X-Art-Coverage-Software-Key":   softwareKey,
 	}
+	for header, val := range envHeaders {
+		if val != "" {
+			headers[header] = val
+		}
+	}
+
+	return headers
+}
+
+// loadToken returns the token required by the coverage endpoints, read from
+// COVERAGE_TOKEN or the file named by COVERAGE_TOKEN_FILE. An empty token
+// disables authentication.
+func loadToken() (string, error) {
+	if token := os.Getenv("COVERAGE_TOKEN"); token != "" {
+		return token, nil
+	}
+	tokenFile := os.Getenv("COVERAGE_TOKEN_FILE")
+	if tokenFile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("read COVERAGE_TOKEN_FILE: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("COVERAGE_TOKEN_FILE %s is empty", tokenFile)
+	}
+	return token, nil
+}
+
+// loadTLSConfig returns the server TLS configuration from COVERAGE_TLS_CERT,
+// COVERAGE_TLS_KEY and COVERAGE_TLS_CLIENT_CA, or nil when TLS is not configured
+func loadTLSConfig() (*tls.Config, error) {
+	certFile := os.Getenv("COVERAGE_TLS_CERT")
+	keyFile := os.Getenv("COVERAGE_TLS_KEY")
+	clientCAFile := os.Getenv("COVERAGE_TLS_CLIENT_CA")
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, fmt.Errorf("COVERAGE_TLS_CLIENT_CA requires COVERAGE_TLS_CERT and COVERAGE_TLS_KEY")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("both COVERAGE_TLS_CERT and COVERAGE_TLS_KEY must be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		caPEM, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read COVERAGE_TLS_CLIENT_CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in COVERAGE_TLS_CLIENT_CA %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// authMiddleware rejects requests without the bearer token to the endpoints
+// under prefix. The health endpoint is let through, and HEAD requests to the
+// coverage endpoint are answered without running it, so that clients and probes
+// can still identify the server without the token. HEAD requests to other
+// endpoints would run their GET handlers and need the token.
+func authMiddleware(token, prefix string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + token)
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-Art-Coverage-Server", "1")
-		w.Header().Set("X-Art-Coverage-Pid", pid)
-		w.Header().Set("X-Art-Coverage-Binary", exe)
-		for header, val := range envHeaders {
-			if val != "" {
-				w.Header().Set(header, val)
-			}
+		if r.URL.Path == prefix+"/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodHead && r.URL.Path == prefix+"/coverage" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="coverage"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
-// startCoverageServer starts a dedicated HTTP server for coverage collection.
-// It tries successive ports starting from COVERAGE_PORT (default 53700)
-// until one is available or MaxRetries attempts are exhausted.
-func startCoverageServer() {
-	startPort := DefaultPort
-	if envPort := os.Getenv("COVERAGE_PORT"); envPort != "" {
-		if p, err := strconv.Atoi(envPort); err == nil && p > 0 {
-			startPort = p
-		}
-	}
-
-	// Create a new ServeMux for the coverage server (isolated from main app)
+// Handler returns the coverage endpoints under Options.Prefix, with
+// identification headers and token authentication. Mount it on an
+// application's mux to serve coverage without a separate port.
+func (s *Server) Handler() http.Handler {
+	prefix := s.opts.Prefix
 	mux := http.NewServeMux()
-	mux.HandleFunc("/coverage", CoverageHandler)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(prefix+"/coverage", s.handleCoverage)
+	mux.HandleFunc(prefix+"/coverage/info", InfoHandler)
+	mux.HandleFunc(prefix+"/coverage/reset", s.handleReset)
+	mux.HandleFunc(prefix+"/coverage/push", s.handlePush)
+	mux.HandleFunc(prefix+"/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "coverage server healthy")
 	})
 
-	handler := identityMiddleware(mux)
+	return identityMiddleware(authMiddleware(s.opts.Token, prefix, mux))
+}
+
+// Start binds the first free port starting at Options.Port and serves the
+// coverage endpoints in the background until ctx is done or Shutdown is called.
+// It returns an error if no port in the range could be bound.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server != nil {
+		return errors.New("coverage server already started")
+	}
+
+	handler := s.Handler()
+	if s.opts.Mux != nil {
+		// Only the coverage endpoints get identification headers and
+		// authentication. A mux panics on duplicate patterns, so the endpoints
+		// are mounted once and stay mounted across restarts.
+		s.mountOnce.Do(func() {
+			for _, path := range coveragePaths {
+				s.opts.Mux.Handle(s.opts.Prefix+path, handler)
+			}
+		})
+		handler = s.opts.Mux
+	}
+
+	ln, err := s.listen()
+	if err != nil {
+		return err
+	}
+	if s.opts.Token != "" {
+		s.logger.Println("[COVERAGE] Token authentication enabled")
+	}
+	if s.opts.TLSConfig != nil {
+		ln = tls.NewListener(ln, s.opts.TLSConfig)
+		s.logger.Printf("[COVERAGE] TLS enabled (client certificates required: %t)", s.opts.TLSConfig.ClientAuth == tls.RequireAndVerifyClientCert)
+	}
+
+	addr := ln.Addr().String()
+	s.logger.Printf("[COVERAGE] Starting coverage server on %s (pid %d)", addr, os.Getpid())
+	s.logger.Printf("[COVERAGE] Endpoints: GET %[1]s/coverage, GET %[1]s/coverage/info, GET %[1]s/coverage/reset, GET|POST %[1]s/coverage/push, GET %[1]s/health, HEAD %[1]s/*", s.opts.Prefix)
+
+	server := &http.Server{Handler: handler, ErrorLog: s.logger}
+	done := make(chan struct{})
+	s.server, s.listener, s.done = server, ln, done
+
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Printf("[COVERAGE] ERROR: Coverage server on %s failed: %v", addr, err)
+		}
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			s.Shutdown(shutdownCtx)
+		case <-done:
+		}
+	}()
+	if s.opts.PushOnSigterm {
+		go s.pushOnSigterm(done)
+	}
 
-	for attempt := 0; attempt < MaxRetries; attempt++ {
-		port := startPort + attempt
-		addr := fmt.Sprintf(":%d", port)
+	return nil
+}
 
-		ln, err := net.Listen("tcp", addr)
+// listen binds the Unix socket, or else the first available port in the
+// configured range
+func (s *Server) listen() (net.Listener, error) {
+	if s.opts.SocketPath != "" {
+		// Remove a stale socket left by a previous process
+		if err := os.Remove(s.opts.SocketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+		ln, err := net.Listen("unix", s.opts.SocketPath)
+		if err != nil {
+			return nil, fmt.Errorf("listen on socket %s: %w", s.opts.SocketPath, err)
+		}
+		return ln, nil
+	}
+
+	for attempt := 0; attempt < s.opts.MaxRetries; attempt++ {
+		port := s.opts.Port + attempt
+		ln, err := net.Listen("tcp", net.JoinHostPort(s.opts.Addr, strconv.Itoa(port)))
 		if err != nil {
-			log.Printf("[COVERAGE] Port %d unavailable: %v; trying next", port, err)
+			s.logger.Printf("[COVERAGE] Port %d unavailable: %v; trying next", port, err)
 			continue
 		}
+		return ln, nil
+	}
 
-		log.Printf("[COVERAGE] Starting coverage server on port %d (pid %d)", port, os.Getpid())
-		log.Printf("[COVERAGE] Endpoints: GET %s/coverage, GET %s/health, HEAD %s/*", addr, addr, addr)
+	return nil, fmt.Errorf("could not bind any port in range %d–%d", s.opts.Port, s.opts.Port+s.opts.MaxRetries-1)
+}
 
-		if err := http.Serve(ln, handler); err != nil {
-			log.Printf("[COVERAGE] ERROR: Coverage server on port %d failed: %v", port, err)
-		}
-		return
+// Addr returns the address the server listens on, or nil if it is not running
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
 	}
+	return s.listener.Addr()
+}
 
-	log.Printf("[COVERAGE] ERROR: Could not bind any port in range %d–%d", startPort, startPort+MaxRetries-1)
+// Shutdown gracefully stops the server, waiting for active requests until ctx
+// is done. The server can be started again afterwards.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	server, done := s.server, s.done
+	s.server, s.listener, s.done = nil, nil, nil
+	s.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	close(done)
+	s.logger.Println("[COVERAGE] Shutting down coverage server")
+	return server.Shutdown(ctx)
 }
 
-// ensureMetaHash computes and caches the metadata hash exactly once.
-// The hash is derived from the binary's coverage metadata which is
+// ensureMetaHash computes and caches the metadata hash exactly once per
+// process. The hash is derived from the binary's coverage metadata which is
 // constant for the lifetime of the process.
-func ensureMetaHash() {
+func (s *Server) ensureMetaHash() {
 	metaHashOnce.Do(func() {
 		var buf bytes.Buffer
 		if err := coverage.WriteMeta(&buf); err != nil {
-			log.Printf("[COVERAGE] Warning: could not prime metadata hash: %v", err)
+			s.logger.Printf("[COVERAGE] Warning: could not prime metadata hash: %v", err)
 			metaHash = "unknown"
 			return
 		}
+		// The hash follows the magic, version, length and entry count in the
+		// meta-data file header, as in the covmeta.<hash> files of GOCOVERDIR
 		data := buf.Bytes()
-		if len(data) >= 32 {
-			metaHash = fmt.Sprintf("%x", data[16:32])
+		if len(data) >= 40 {
+			metaHash = fmt.Sprintf("%x", data[24:40])
 		} else {
 			metaHash = "unknown"
 		}
 	})
 }
 
-// CoverageHandler collects coverage data and returns it via HTTP as JSON.
+// coverageFile is a coverage data file in GOCOVERDIR format
+type coverageFile struct {
+	Name string
+	Data []byte
+}
+
+// CoverageHandler collects coverage data and returns it via HTTP as JSON, or
+// as a tar stream when the client accepts application/x-tar.
 // Pass ?nometa=1 to skip metadata collection (useful after the first fetch
 // since metadata does not change for the lifetime of the process).
 func CoverageHandler(w http.ResponseWriter, r *http.Request) {
+	defaultServer.handleCoverage(w, r)
+}
+
+func (s *Server) handleCoverage(w http.ResponseWriter, r *http.Request) {
 	skipMeta := r.URL.Query().Get("nometa") == "1"
 
 	if skipMeta {
-		log.Println("[COVERAGE] Collecting coverage counters (metadata skipped)...")
+		s.logger.Println("[COVERAGE] Collecting coverage counters (metadata skipped)...")
 	} else {
-		log.Println("[COVERAGE] Collecting coverage data...")
+		s.logger.Println("[COVERAGE] Collecting coverage data...")
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/x-tar") {
+		files, _, err := s.collectCoverageFiles(skipMeta)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		files = append(files, s.coverDirFiles()...)
+
+		w.Header().Set("Content-Type", "application/x-tar")
+		var out io.Writer = w
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+		if err := writeCoverageTar(out, files); err != nil {
+			// Headers are already sent; the client sees a truncated archive
+			s.logger.Printf("[COVERAGE] Error streaming coverage: %v", err)
+			return
+		}
+
+		s.logger.Println("[COVERAGE] Coverage data streamed successfully")
+		return
+	}
+
+	response, err := s.collectCoverage(skipMeta)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.logger.Printf("[COVERAGE] Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Println("[COVERAGE] Coverage data sent successfully")
+}
+
+// collectCoverage snapshots the coverage metadata (unless skipped) and counters
+func (s *Server) collectCoverage(skipMeta bool) (*CoverageResponse, error) {
+	files, timestamp, err := s.collectCoverageFiles(skipMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CoverageResponse{
+		Timestamp:       timestamp,
+		Format:          Format,
+		ProtocolVersion: ProtocolVersion,
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name, "covmeta.") {
+			response.MetaFilename = file.Name
+			response.MetaData = base64.StdEncoding.EncodeToString(file.Data)
+		} else {
+			response.CountersFilename = file.Name
+			response.CountersData = base64.StdEncoding.EncodeToString(file.Data)
+		}
+	}
+	for _, file := range s.coverDirFiles() {
+		response.CoverDirFiles = append(response.CoverDirFiles, CoverDirFile{
+			Name: file.Name,
+			Data: base64.StdEncoding.EncodeToString(file.Data),
+		})
 	}
 
+	return response, nil
+}
+
+// coverDirFiles returns the coverage files written to GOCOVERDIR by other
+// processes. Errors are logged, since the coverage of this process is still
+// worth returning.
+func (s *Server) coverDirFiles() []coverageFile {
+	dir := os.Getenv("GOCOVERDIR")
+	if dir == "" {
+		return nil
+	}
+
+	s.ensureMetaHash()
+	files, err := readCoverDir(dir, metaHash, os.Getpid())
+	if err != nil {
+		s.logger.Printf("[COVERAGE] Warning: could not read GOCOVERDIR: %v", err)
+		return nil
+	}
+	if len(files) > 0 {
+		s.logger.Printf("[COVERAGE] Including %d file(s) from GOCOVERDIR", len(files))
+	}
+	return files
+}
+
+// readCoverDir reads the covmeta/covcounters files of a GOCOVERDIR, skipping
+// the metadata with ownHash (already served from memory) and the counters
+// written by ownPid
+func readCoverDir(dir, ownHash string, ownPid int) ([]coverageFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pid := strconv.Itoa(ownPid)
+	var files []coverageFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, "covmeta."):
+			if strings.TrimPrefix(name, "covmeta.") == ownHash {
+				continue
+			}
+		case strings.HasPrefix(name, "covcounters."):
+			// covcounters.<hash>.<pid>.<timestamp>
+			if parts := strings.Split(name, "."); len(parts) == 4 && parts[2] == pid {
+				continue
+			}
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, coverageFile{Name: name, Data: data})
+	}
+
+	return files, nil
+}
+
+// collectCoverageFiles snapshots the coverage metadata (unless skipped) and
+// counters as GOCOVERDIR files
+func (s *Server) collectCoverageFiles(skipMeta bool) ([]coverageFile, int64, error) {
 	// Ensure the hash is primed (safe for concurrent use, runs once)
-	ensureMetaHash()
+	s.ensureMetaHash()
 
-	var metaData []byte
-	var metaFilename string
+	var files []coverageFile
+	var metaSize int
 	if !skipMeta {
 		var metaBuf bytes.Buffer
 		if err := coverage.WriteMeta(&metaBuf); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to collect metadata: %v", err), http.StatusInternalServerError)
-			return
+			return nil, 0, fmt.Errorf("Failed to collect metadata: %w", err)
 		}
-		metaData = metaBuf.Bytes()
-		metaFilename = fmt.Sprintf("covmeta.%s", metaHash)
+		metaSize = metaBuf.Len()
+		files = append(files, coverageFile{Name: fmt.Sprintf("covmeta.%s", metaHash), Data: metaBuf.Bytes()})
 	}
 
 	// Collect counters
 	var counterBuf bytes.Buffer
 	if err := coverage.WriteCounters(&counterBuf); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to collect counters: %v", err), http.StatusInternalServerError)
-		return
+		return nil, 0, fmt.Errorf("Failed to collect counters: %w", err)
 	}
-	counterData := counterBuf.Bytes()
 
 	// Generate counter filename using the cached hash
 	timestamp := time.Now().UnixNano()
 	counterFilename := fmt.Sprintf("covcounters.%s.%d.%d", metaHash, os.Getpid(), timestamp)
+	files = append(files, coverageFile{Name: counterFilename, Data: counterBuf.Bytes()})
 
-	log.Printf("[COVERAGE] Collected %d bytes metadata, %d bytes counters",
-		len(metaData), len(counterData))
+	s.logger.Printf("[COVERAGE] Collected %d bytes metadata, %d bytes counters",
+		metaSize, counterBuf.Len())
 
-	// Return coverage data as JSON
-	response := CoverageResponse{
-		MetaFilename:     metaFilename,
-		CountersFilename: counterFilename,
-		CountersData:     base64.StdEncoding.EncodeToString(counterData),
-		Timestamp:        timestamp,
-	}
-	if !skipMeta {
-		response.MetaData = base64.StdEncoding.EncodeToString(metaData)
+	return files, timestamp, nil
+}
+
+// writeCoverageTar writes the coverage files as a tar archive
+func writeCoverageTar(w io.Writer, files []coverageFile) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    file.Name,
+			Mode:    0644,
+			Size:    int64(len(file.Data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.Data); err != nil {
+			return err
+		}
 	}
+	return tw.Close()
+}
 
+// InfoHandler returns the protocol version, format and capabilities of the server
+func InfoHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("[COVERAGE] Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(InfoResponse{
+		Format:          Format,
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    Capabilities,
+		Pid:             os.Getpid(),
+		Binary:          binaryName(),
+	})
+}
+
+// ResetHandler clears the coverage counters of the running process.
+// Counters can only be cleared for binaries built with -covermode=atomic;
+// other modes make the request fail with 500.
+func ResetHandler(w http.ResponseWriter, r *http.Request) {
+	defaultServer.handleReset(w, r)
+}
+
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	if err := coverage.ClearCounters(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reset counters: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Println("[COVERAGE] Coverage counters reset")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Coverage counters reset")
+}
+
+// PushHandler dumps the current coverage to COVERAGE_DUMP_DIR and/or
+// COVERAGE_PUSH_URL. Use it as a preStop hook so coverage survives pod
+// termination.
+func PushHandler(w http.ResponseWriter, r *http.Request) {
+	defaultServer.handlePush(w, r)
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	if err := s.PushCoverage(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to push coverage: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("[COVERAGE] Coverage data sent successfully")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Coverage pushed")
+}
+
+// PushCoverage dumps the current coverage to COVERAGE_DUMP_DIR and/or POSTs it
+// to COVERAGE_PUSH_URL. It fails if neither is configured.
+func PushCoverage() error {
+	return defaultServer.PushCoverage()
+}
+
+// PushCoverage dumps the current coverage to COVERAGE_DUMP_DIR and/or POSTs it
+// to COVERAGE_PUSH_URL, logging to the server's logger
+func (s *Server) PushCoverage() error {
+	pushURL := os.Getenv("COVERAGE_PUSH_URL")
+	dumpDir := os.Getenv("COVERAGE_DUMP_DIR")
+	if pushURL == "" && dumpDir == "" {
+		return errors.New("neither COVERAGE_PUSH_URL nor COVERAGE_DUMP_DIR is set")
+	}
+
+	var errs []error
+	if dumpDir != "" {
+		if err := s.dumpToDir(dumpDir); err != nil {
+			errs = append(errs, fmt.Errorf("dump to %s: %w", dumpDir, err))
+		}
+	}
+	if pushURL != "" {
+		if err := s.pushToURL(pushURL); err != nil {
+			errs = append(errs, fmt.Errorf("push to %s: %w", pushURL, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// componentName returns the component reported with pushed coverage
+func componentName() string {
+	if component := os.Getenv("COVERAGE_COMPONENT"); component != "" {
+		return component
+	}
+	return binaryName()
+}
+
+// dumpToDir writes the coverage files to <dir>/<component>/<pod>-<pid>/, in the
+// same layout as GOCOVERDIR, so the directory can be read by go tool covdata
+func (s *Server) dumpToDir(dir string) error {
+	hostname, _ := os.Hostname()
+	target := filepath.Join(dir, componentName(), fmt.Sprintf("%s-%d", hostname, os.Getpid()))
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+
+	if err := coverage.WriteMetaDir(target); err != nil {
+		return fmt.Errorf("write metadata: %w", err)
+	}
+	if err := coverage.WriteCountersDir(target); err != nil {
+		return fmt.Errorf("write counters: %w", err)
+	}
+
+	s.logger.Printf("[COVERAGE] Coverage dumped to %s", target)
+	return nil
+}
+
+// pushToURL POSTs the coverage (same JSON as /coverage) to a collector
+func (s *Server) pushToURL(pushURL string) error {
+	response, err := s.collectCoverage(false)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("encode coverage: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, pushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for header, val := range identityHeaders() {
+		req.Header.Set(header, val)
+	}
+	req.Header.Set("X-Art-Coverage-Component", componentName())
+	if hostname, err := os.Hostname(); err == nil {
+		req.Header.Set("X-Art-Coverage-Pod", hostname)
+	}
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Art-Coverage-Namespace", namespace)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+
+	s.logger.Printf("[COVERAGE] Coverage pushed to %s", pushURL)
+	return nil
+}
+
+// pushOnSigterm dumps coverage when the process receives SIGTERM, then
+// re-raises the signal so the default behavior (or the application's own
+// handler) still applies. Applications handling SIGTERM themselves may exit
+// before the dump completes; prefer the /coverage/push preStop hook for them.
+// It stops listening when done is closed (server shutdown).
+func (s *Server) pushOnSigterm(done <-chan struct{}) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case <-sigCh:
+	case <-done:
+		return
+	}
+
+	s.logger.Println("[COVERAGE] SIGTERM received, pushing final coverage...")
+	if err := s.PushCoverage(); err != nil {
+		s.logger.Printf("[COVERAGE] ERROR: Failed to push coverage: %v", err)
+	}
+
+	signal.Stop(sigCh)
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(syscall.SIGTERM)
+	}
 }
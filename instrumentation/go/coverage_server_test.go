@@ -1,14 +1,33 @@
 package coverage
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime/coverage"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCoverageHandler_Success(t *testing.T) {
@@ -390,6 +409,86 @@ func TestHealthHandler(t *testing.T) {
 	}
 }
 
+func TestResetHandler_CoverageDisabled(t *testing.T) {
+	if isCoverageEnabled() {
+		t.Skip("Skipping test - coverage is enabled")
+	}
+
+	req, _ := http.NewRequest("GET", "/coverage/reset", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ResetHandler).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Reset without coverage should fail: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+
+	if !strings.Contains(rr.Body.String(), "Failed to reset counters") {
+		t.Errorf("Unexpected body: %s", rr.Body.String())
+	}
+}
+
+func TestPushHandler_NotConfigured(t *testing.T) {
+	t.Setenv("COVERAGE_PUSH_URL", "")
+	t.Setenv("COVERAGE_DUMP_DIR", "")
+
+	req, _ := http.NewRequest("POST", "/coverage/push", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(PushHandler).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Push without destination should fail: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+
+	if !strings.Contains(rr.Body.String(), "COVERAGE_PUSH_URL") {
+		t.Errorf("Unexpected body: %s", rr.Body.String())
+	}
+}
+
+func TestPushCoverage(t *testing.T) {
+	if !isCoverageEnabled() {
+		t.Skip("Skipping test - coverage not enabled (run with: go test -cover)")
+	}
+
+	var pushed CoverageResponse
+	var headers http.Header
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&pushed); err != nil {
+			t.Errorf("Failed to decode pushed coverage: %v", err)
+		}
+	}))
+	defer collector.Close()
+
+	dumpDir := t.TempDir()
+	t.Setenv("COVERAGE_PUSH_URL", collector.URL+"/push")
+	t.Setenv("COVERAGE_DUMP_DIR", dumpDir)
+	t.Setenv("COVERAGE_COMPONENT", "backend")
+	t.Setenv("POD_NAMESPACE", "test-ns")
+
+	if err := PushCoverage(); err != nil {
+		t.Fatalf("PushCoverage failed: %v", err)
+	}
+
+	if pushed.MetaData == "" || pushed.CountersData == "" {
+		t.Error("Pushed coverage should include metadata and counters")
+	}
+	if headers.Get("X-Art-Coverage-Component") != "backend" {
+		t.Errorf("Expected component header 'backend', got %q", headers.Get("X-Art-Coverage-Component"))
+	}
+	if headers.Get("X-Art-Coverage-Namespace") != "test-ns" {
+		t.Errorf("Expected namespace header 'test-ns', got %q", headers.Get("X-Art-Coverage-Namespace"))
+	}
+	if headers.Get("X-Art-Coverage-Pod") == "" {
+		t.Error("X-Art-Coverage-Pod header should not be empty")
+	}
+
+	metaFiles, _ := filepath.Glob(filepath.Join(dumpDir, "backend", "*", "covmeta.*"))
+	counterFiles, _ := filepath.Glob(filepath.Join(dumpDir, "backend", "*", "covcounters.*"))
+	if len(metaFiles) != 1 || len(counterFiles) != 1 {
+		t.Errorf("Expected one meta and one counters file in dump dir, got %v and %v", metaFiles, counterFiles)
+	}
+}
+
 func TestIdentityMiddleware(t *testing.T) {
 	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -414,6 +513,52 @@ func TestIdentityMiddleware(t *testing.T) {
 	}
 }
 
+func TestIdentityMiddleware_ProtocolHeaders(t *testing.T) {
+	handler := identityMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req, _ := http.NewRequest("HEAD", "/", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("X-Art-Coverage-Format"); got != "go" {
+		t.Errorf("X-Art-Coverage-Format should be 'go', got %q", got)
+	}
+	if got := rr.Header().Get("X-Art-Coverage-Protocol"); got != strconv.Itoa(ProtocolVersion) {
+		t.Errorf("X-Art-Coverage-Protocol should be %d, got %q", ProtocolVersion, got)
+	}
+	if got := rr.Header().Get("X-Art-Coverage-Capabilities"); got != "reset,nometa,push,streaming,gocoverdir" {
+		t.Errorf("X-Art-Coverage-Capabilities should be 'reset,nometa,push,streaming,gocoverdir', got %q", got)
+	}
+}
+
+func TestInfoHandler(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/coverage/info", nil)
+	rr := httptest.NewRecorder()
+	InfoHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var info InfoResponse
+	if err := json.NewDecoder(rr.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode info response: %v", err)
+	}
+
+	if info.Format != "go" {
+		t.Errorf("Expected format 'go', got %q", info.Format)
+	}
+	if info.ProtocolVersion != ProtocolVersion {
+		t.Errorf("Expected protocol version %d, got %d", ProtocolVersion, info.ProtocolVersion)
+	}
+	if !reflect.DeepEqual(info.Capabilities, []string{"reset", "nometa", "push", "streaming", "gocoverdir"}) {
+		t.Errorf("Unexpected capabilities: %v", info.Capabilities)
+	}
+	if info.Pid != os.Getpid() {
+		t.Errorf("Expected pid %d, got %d", os.Getpid(), info.Pid)
+	}
+}
+
 func TestIdentityMiddleware_GET(t *testing.T) {
 	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("ok"))
@@ -434,6 +579,261 @@ func TestIdentityMiddleware_GET(t *testing.T) {
 	}
 }
 
+func TestAuthMiddleware(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	handler := identityMiddleware(authMiddleware("secret", "", inner))
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{name: "valid token", method: "GET", path: "/coverage", authorization: "Bearer secret", expected: http.StatusOK},
+		{name: "missing token", method: "GET", path: "/coverage", expected: http.StatusUnauthorized},
+		{name: "wrong token", method: "GET", path: "/coverage/reset", authorization: "Bearer wrong", expected: http.StatusUnauthorized},
+		{name: "HEAD probe", method: "HEAD", path: "/coverage", expected: http.StatusOK},
+		{name: "HEAD reset", method: "HEAD", path: "/coverage/reset", expected: http.StatusUnauthorized},
+		{name: "HEAD push", method: "HEAD", path: "/coverage/push", expected: http.StatusUnauthorized},
+		{name: "HEAD other path", method: "HEAD", path: "/coverage/info", expected: http.StatusUnauthorized},
+		{name: "health", method: "GET", path: "/health", expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rr.Code)
+			}
+			if rr.Header().Get("X-Art-Coverage-Server") != "1" {
+				t.Error("X-Art-Coverage-Server header should be present")
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_NoToken(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	req, _ := http.NewRequest("GET", "/coverage", nil)
+	rr := httptest.NewRecorder()
+	authMiddleware("", "", inner).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 without a configured token, got %d", rr.Code)
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenFile string
+		expected  string
+		expectErr bool
+	}{
+		{name: "disabled", expected: ""},
+		{name: "env", token: "from-env", expected: "from-env"},
+		{name: "env takes precedence", token: "from-env", tokenFile: tokenFile, expected: "from-env"},
+		{name: "file", tokenFile: tokenFile, expected: "from-file"},
+		{name: "missing file", tokenFile: filepath.Join(t.TempDir(), "missing"), expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COVERAGE_TOKEN", tt.token)
+			t.Setenv("COVERAGE_TOKEN_FILE", tt.tokenFile)
+
+			token, err := loadToken()
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if token != tt.expected {
+				t.Errorf("Expected token %q, got %q", tt.expected, token)
+			}
+		})
+	}
+}
+
+// testCertificates holds PEM files generated for TLS tests
+type testCertificates struct {
+	CAFile         string
+	ServerCertFile string
+	ServerKeyFile  string
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// writeTestCertificates generates a CA with a server certificate for
+// 127.0.0.1/localhost and a client certificate, and writes them to dir
+func writeTestCertificates(t *testing.T, dir string) testCertificates {
+	t.Helper()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("Failed to create %s certificate: %v", name, err)
+		}
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		return writePEM(name+".crt", "CERTIFICATE", der), writePEM(name+".key", "EC PRIVATE KEY", keyDER)
+	}
+
+	certs := testCertificates{CAFile: writePEM("ca.crt", "CERTIFICATE", caDER)}
+	certs.ServerCertFile, certs.ServerKeyFile = issue("server", 2, x509.ExtKeyUsageServerAuth)
+	certs.ClientCertFile, certs.ClientKeyFile = issue("client", 3, x509.ExtKeyUsageClientAuth)
+	return certs
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	certs := writeTestCertificates(t, t.TempDir())
+
+	tests := []struct {
+		name             string
+		cert, key, ca    string
+		expectNil        bool
+		expectClientAuth bool
+		expectErr        bool
+	}{
+		{name: "disabled", expectNil: true},
+		{name: "TLS", cert: certs.ServerCertFile, key: certs.ServerKeyFile},
+		{name: "mTLS", cert: certs.ServerCertFile, key: certs.ServerKeyFile, ca: certs.CAFile, expectClientAuth: true},
+		{name: "missing key", cert: certs.ServerCertFile, expectErr: true},
+		{name: "client CA without certificate", ca: certs.CAFile, expectErr: true},
+		{name: "invalid client CA", cert: certs.ServerCertFile, key: certs.ServerKeyFile, ca: certs.ServerKeyFile, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COVERAGE_TLS_CERT", tt.cert)
+			t.Setenv("COVERAGE_TLS_KEY", tt.key)
+			t.Setenv("COVERAGE_TLS_CLIENT_CA", tt.ca)
+
+			config, err := loadTLSConfig()
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.expectNil {
+				if config != nil {
+					t.Error("Expected no TLS config")
+				}
+				return
+			}
+			if config == nil {
+				t.Fatal("Expected TLS config")
+			}
+			if got := config.ClientAuth == tls.RequireAndVerifyClientCert; got != tt.expectClientAuth {
+				t.Errorf("Expected client certificates required: %t, got %t", tt.expectClientAuth, got)
+			}
+		})
+	}
+}
+
+func TestTLSServer_ClientCertificate(t *testing.T) {
+	certs := writeTestCertificates(t, t.TempDir())
+	t.Setenv("COVERAGE_TLS_CERT", certs.ServerCertFile)
+	t.Setenv("COVERAGE_TLS_KEY", certs.ServerKeyFile)
+	t.Setenv("COVERAGE_TLS_CLIENT_CA", certs.CAFile)
+
+	config, err := loadTLSConfig()
+	if err != nil {
+		t.Fatalf("Failed to load TLS config: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(identityMiddleware(http.HandlerFunc(InfoHandler)))
+	server.TLS = config
+	server.StartTLS()
+	defer server.Close()
+
+	caPEM, _ := os.ReadFile(certs.CAFile)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	clientCert, err := tls.LoadX509KeyPair(certs.ClientCertFile, certs.ClientKeyFile)
+	if err != nil {
+		t.Fatalf("Failed to load client certificate: %v", err)
+	}
+
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+	resp, err := withCert.Get(server.URL + "/coverage/info")
+	if err != nil {
+		t.Fatalf("Request with client certificate failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if resp, err := withoutCert.Get(server.URL + "/coverage/info"); err == nil {
+		resp.Body.Close()
+		t.Error("Expected request without client certificate to fail")
+	}
+}
+
 func TestCoverageHandler_ConcurrentRequests(t *testing.T) {
 	if !isCoverageEnabled() {
 		t.Skip("Skipping test - coverage not enabled (run with: go test -cover)")
@@ -511,6 +911,354 @@ func TestDefaultPort(t *testing.T) {
 	}
 }
 
+func TestReadCoverDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"covmeta.own":             "own meta",
+		"covmeta.child":           "child meta",
+		"covcounters.own.100.1":   "own counters",
+		"covcounters.child.200.1": "child counters",
+		"covcounters.child.201.2": "other child counters",
+		"unrelated.txt":           "ignored",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "covmeta.dir"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	got, err := readCoverDir(dir, "own", 100)
+	if err != nil {
+		t.Fatalf("readCoverDir failed: %v", err)
+	}
+
+	names := make([]string, 0, len(got))
+	for _, file := range got {
+		names = append(names, file.Name)
+		if string(file.Data) != files[file.Name] {
+			t.Errorf("Unexpected data for %s: %q", file.Name, file.Data)
+		}
+	}
+	want := []string{"covcounters.child.200.1", "covcounters.child.201.2", "covmeta.child"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected files %v, got %v", want, names)
+	}
+
+	if _, err := readCoverDir(filepath.Join(dir, "missing"), "own", 100); err == nil {
+		t.Error("Expected error for missing directory")
+	}
+}
+
+func TestWriteCoverageTar(t *testing.T) {
+	files := []coverageFile{
+		{Name: "covmeta.abc", Data: []byte("meta")},
+		{Name: "covcounters.abc.1.2", Data: []byte("counters")},
+	}
+
+	var buf bytes.Buffer
+	if err := writeCoverageTar(&buf, files); err != nil {
+		t.Fatalf("writeCoverageTar failed: %v", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	for _, want := range files {
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatalf("Failed to read tar entry: %v", err)
+		}
+		if header.Name != want.Name {
+			t.Errorf("Expected entry %s, got %s", want.Name, header.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Failed to read tar entry data: %v", err)
+		}
+		if !bytes.Equal(data, want.Data) {
+			t.Errorf("Entry %s: expected %q, got %q", want.Name, want.Data, data)
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("Expected end of archive, got %v", err)
+	}
+}
+
+func TestCoverageHandler_Tar(t *testing.T) {
+	if !isCoverageEnabled() {
+		t.Skip("Skipping test - coverage not enabled (run with: go test -cover)")
+	}
+
+	req, _ := http.NewRequest("GET", "/coverage", nil)
+	req.Header.Set("Accept", "application/x-tar")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	CoverageHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-tar" {
+		t.Errorf("Expected Content-Type application/x-tar, got %s", ct)
+	}
+	if ce := rr.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got %s", ce)
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read tar entry: %v", err)
+		}
+		names = append(names, header.Name)
+	}
+
+	if len(names) != 2 || !strings.HasPrefix(names[0], "covmeta.") || !strings.HasPrefix(names[1], "covcounters.") {
+		t.Errorf("Unexpected tar entries: %v", names)
+	}
+}
+
+// freePort returns a TCP port that is currently free on 127.0.0.1
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestServer_StartShutdown(t *testing.T) {
+	var logs bytes.Buffer
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("app"))
+	})
+
+	srv := NewServer(Options{
+		Addr:   "127.0.0.1",
+		Port:   freePort(t),
+		Mux:    mux,
+		Logger: log.New(&logs, "", 0),
+	})
+	if srv.Addr() != nil {
+		t.Error("Addr should be nil before Start")
+	}
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := srv.Start(context.Background()); err == nil {
+		t.Error("Expected error when starting a running server")
+	}
+
+	baseURL := "http://" + srv.Addr().String()
+	tests := []struct {
+		path         string
+		expected     string
+		expectHeader bool
+	}{
+		{path: "/health", expected: "coverage server healthy", expectHeader: true},
+		{path: "/app", expected: "app", expectHeader: false}, // Application endpoints are left alone
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(baseURL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.expected {
+			t.Errorf("GET %s: expected %q, got %q", tt.path, tt.expected, body)
+		}
+		if got := resp.Header.Get("X-Art-Coverage-Server") == "1"; got != tt.expectHeader {
+			t.Errorf("GET %s: expected X-Art-Coverage-Server header: %t, got %t", tt.path, tt.expectHeader, got)
+		}
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if _, err := http.Get(baseURL + "/health"); err == nil {
+		t.Error("Expected request to fail after Shutdown")
+	}
+	if srv.Addr() != nil {
+		t.Error("Addr should be nil after Shutdown")
+	}
+	if !strings.Contains(logs.String(), "Starting coverage server") {
+		t.Errorf("Expected start message in server logger, got %q", logs.String())
+	}
+
+	// The coverage endpoints stay mounted on the mux across restarts
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+	resp, err := http.Get("http://" + srv.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("GET /health after restart failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /health after restart: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_ContextCancel(t *testing.T) {
+	srv := NewServer(Options{Addr: "127.0.0.1", Port: freePort(t), Logger: log.New(io.Discard, "", 0)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := srv.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Addr() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Server was not shut down after the context was cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer_HandlerPrefix(t *testing.T) {
+	srv := NewServer(Options{Prefix: "/debug/", Token: "secret", Logger: log.New(io.Discard, "", 0)})
+	appMux := http.NewServeMux()
+	appMux.Handle("/debug/", srv.Handler())
+	appMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("app"))
+	})
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{name: "prefixed info", path: "/debug/coverage/info", authorization: "Bearer secret", expected: http.StatusOK},
+		{name: "prefixed info without token", path: "/debug/coverage/info", expected: http.StatusUnauthorized},
+		{name: "prefixed health without token", path: "/debug/health", expected: http.StatusOK},
+		{name: "unknown prefixed path", path: "/debug/other", authorization: "Bearer secret", expected: http.StatusNotFound},
+		{name: "application path", path: "/coverage", expected: http.StatusOK},
+		{name: "prefixed HEAD probe without token", method: "HEAD", path: "/debug/coverage", expected: http.StatusOK},
+		{name: "prefixed HEAD reset without token", method: "HEAD", path: "/debug/coverage/reset", expected: http.StatusUnauthorized},
+		{name: "prefixed HEAD push without token", method: "HEAD", path: "/debug/coverage/push", expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			req, _ := http.NewRequest(method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			appMux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rr.Code)
+			}
+		})
+	}
+}
+
+func TestServer_UnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "coverage.sock")
+	// A stale socket file from a previous process must not prevent startup
+	if err := os.WriteFile(socketPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer(Options{SocketPath: socketPath, Logger: log.New(io.Discard, "", 0)})
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	resp, err := client.Get("http://localhost/coverage/info")
+	if err != nil {
+		t.Fatalf("Request over socket failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var info InfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode info: %v", err)
+	}
+	if info.Format != Format {
+		t.Errorf("Expected format %s, got %s", Format, info.Format)
+	}
+}
+
+func TestServer_PortRetry(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to bind port: %v", err)
+	}
+	defer busy.Close()
+	busyPort := busy.Addr().(*net.TCPAddr).Port
+
+	srv := NewServer(Options{Addr: "127.0.0.1", Port: busyPort, MaxRetries: 1, Logger: log.New(io.Discard, "", 0)})
+	if err := srv.Start(context.Background()); err == nil {
+		srv.Shutdown(context.Background())
+		t.Fatal("Expected error when no port in the range is free")
+	}
+
+	srv = NewServer(Options{Addr: "127.0.0.1", Port: busyPort, MaxRetries: 10, Logger: log.New(io.Discard, "", 0)})
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	if port := srv.Addr().(*net.TCPAddr).Port; port == busyPort {
+		t.Errorf("Expected a port other than the busy port %d", busyPort)
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("COVERAGE_PORT", "54000")
+	t.Setenv("COVERAGE_TOKEN", "secret")
+	t.Setenv("COVERAGE_TOKEN_FILE", "")
+	t.Setenv("COVERAGE_TLS_CERT", "")
+	t.Setenv("COVERAGE_TLS_KEY", "")
+	t.Setenv("COVERAGE_TLS_CLIENT_CA", "")
+	t.Setenv("COVERAGE_DUMP_ON_SIGTERM", "1")
+	t.Setenv("COVERAGE_SOCKET", "/tmp/coverage.sock")
+
+	opts, err := OptionsFromEnv()
+	if err != nil {
+		t.Fatalf("OptionsFromEnv failed: %v", err)
+	}
+	if opts.Port != 54000 || opts.Token != "secret" || !opts.PushOnSigterm || opts.TLSConfig != nil || opts.SocketPath != "/tmp/coverage.sock" {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	t.Setenv("COVERAGE_TLS_CERT", "/nonexistent/tls.crt")
+	if _, err := OptionsFromEnv(); err == nil {
+		t.Error("Expected error for incomplete TLS configuration")
+	}
+}
+
 func TestMaxRetries(t *testing.T) {
 	if MaxRetries != 50 {
 		t.Errorf("MaxRetries should be 50, got %d", MaxRetries)
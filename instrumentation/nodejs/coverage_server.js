@@ -14,18 +14,36 @@
  * Clients can identify a coverage server via response headers:
  *   X-Art-Coverage-Server, X-Art-Coverage-Pid, X-Art-Coverage-Binary, etc.
  *
+ * Coverage responses carry base64 Istanbul JSON in coverage_data and announce
+ * their format with a "format": "istanbul" field. All responses also carry the
+ * X-Art-Coverage-Format, X-Art-Coverage-Protocol and X-Art-Coverage-Capabilities
+ * headers; GET /coverage/info returns the same information as JSON.
+ *
+ * Coverage of pods that are rolled, evicted or scaled down is lost unless it is
+ * dumped before the process exits. GET or POST /coverage/push dumps the current
+ * coverage to the destinations below and is meant to be used as a preStop hook:
+ *     COVERAGE_PUSH_URL        - POST the /coverage JSON to a collector (e.g. coverport agent)
+ *     COVERAGE_DUMP_DIR        - write the /coverage JSON to <dir>/<component>/<pod>-<pid>/coverage.json
+ *     COVERAGE_COMPONENT       - component name sent with the dump (default: binary name)
+ *     COVERAGE_DUMP_ON_SIGTERM - set to 1 or true to also dump when the process receives SIGTERM
+ *
+ * Pushed requests carry the identification headers plus X-Art-Coverage-Component,
+ * X-Art-Coverage-Pod (hostname) and X-Art-Coverage-Namespace (if POD_NAMESPACE is set).
+ *
  * Usage:
  *     node coverage_server.js app.js
  *     node coverage_server.js path/to/script.js
  *
  * Environment Variables:
  *     COVERAGE_PORT - Starting port for coverage HTTP server (default: 53700)
+ *     COVERAGE_PUSH_URL, COVERAGE_DUMP_DIR, COVERAGE_COMPONENT, COVERAGE_DUMP_ON_SIGTERM - see above
  */
 
 import { createServer } from 'http';
 import { fileURLToPath } from 'url';
 import { dirname, resolve, basename } from 'path';
-import { existsSync, readFileSync } from 'fs';
+import { existsSync, mkdirSync, readFileSync, writeFileSync } from 'fs';
+import { hostname } from 'os';
 import inspector from 'inspector';
 import v8ToIstanbul from 'v8-to-istanbul';
 
@@ -35,11 +53,19 @@ const MAX_RETRIES = 50;
 const COVERAGE_PORT = parseInt(process.env.COVERAGE_PORT || String(DEFAULT_PORT), 10);
 const PRINT_PREFIX = '[coverage-wrapper]';
 
+// Wire protocol shared by the Go, Python, Node.js and Rust coverage servers
+const PROTOCOL_VERSION = 1;
+const COVERAGE_FORMAT = 'istanbul';
+const CAPABILITIES = ['reset', 'push'];
+
 // Identity headers (built once at startup)
 const IDENTITY_HEADERS = {
   'X-Art-Coverage-Server': '1',
   'X-Art-Coverage-Pid': String(process.pid),
   'X-Art-Coverage-Binary': basename(process.execPath),
+  'X-Art-Coverage-Format': COVERAGE_FORMAT,
+  'X-Art-Coverage-Protocol': String(PROTOCOL_VERSION),
+  'X-Art-Coverage-Capabilities': CAPABILITIES.join(','),
 };
 if (process.env.SOURCE_GIT_COMMIT) {
   IDENTITY_HEADERS['X-Art-Coverage-Source-Commit'] = process.env.SOURCE_GIT_COMMIT;
@@ -87,12 +113,17 @@ function handleRequest(req, res) {
   if (path === '/coverage') {
     console.log(`${PRINT_PREFIX} Coverage dump requested (label=${label})`);
     handleCoverageDump(req, res, label);
+  } else if (path === '/coverage/info') {
+    handleInfo(req, res);
   } else if (path === '/health') {
     console.log(`${PRINT_PREFIX} Health check requested`);
     handleHealth(req, res);
   } else if (path === '/coverage/reset') {
     console.log(`${PRINT_PREFIX} Coverage reset requested`);
     handleCoverageReset(req, res);
+  } else if (path === '/coverage/push') {
+    console.log(`${PRINT_PREFIX} Coverage push requested`);
+    handlePush(req, res);
   } else {
     res.writeHead(404, { 'Content-Type': 'text/plain' });
     res.end('Not found');
@@ -105,58 +136,58 @@ function handleRequest(req, res) {
  */
 async function handleCoverageDump(req, res, label) {
   try {
+    const body = JSON.stringify(await coveragePayload(label));
+    res.writeHead(200, {
+      'Content-Type': 'application/json',
+      'Content-Length': Buffer.byteLength(body),
+    });
+    res.end(body);
+  } catch (error) {
+    console.error(`${PRINT_PREFIX} Error dumping coverage:`, error);
+    res.writeHead(500, { 'Content-Type': 'application/json' });
+    res.end(JSON.stringify({ error: error.message }));
+  }
+}
+
+/**
+ * Take the precise coverage via the Inspector API and build the /coverage payload
+ * @param {string} label - Label reported with the coverage
+ * @returns {Promise<Object>} /coverage JSON payload
+ */
+function coveragePayload(label) {
+  return new Promise((resolvePromise, rejectPromise) => {
     if (!coverageSession) {
-      res.writeHead(500, { 'Content-Type': 'application/json' });
-      res.end(JSON.stringify({ error: 'Coverage session not initialized' }));
+      rejectPromise(new Error('Coverage session not initialized'));
       return;
     }
 
     // Use Inspector API to get precise coverage in real-time (in-memory)
-    coverageSession.post('Profiler.takePreciseCoverage', async (err, { result }) => {
+    coverageSession.post('Profiler.takePreciseCoverage', async (err, params) => {
       if (err) {
         console.error(`${PRINT_PREFIX} Error taking coverage:`, err);
-        res.writeHead(500, { 'Content-Type': 'application/json' });
-        res.end(JSON.stringify({ error: err.message }));
+        rejectPromise(err);
         return;
       }
 
+      let istanbulCoverage;
       try {
         // Convert V8 coverage to Istanbul format (purely in-memory)
-        const istanbulCoverage = await convertV8ToIstanbul(result);
-
-        const payload = {
-          label,
-          timestamp: new Date().toISOString(),
-          coverage_data: Buffer.from(JSON.stringify(istanbulCoverage)).toString('base64'),
-        };
-
-        const body = JSON.stringify(payload);
-        res.writeHead(200, {
-          'Content-Type': 'application/json',
-          'Content-Length': Buffer.byteLength(body),
-        });
-        res.end(body);
+        istanbulCoverage = await convertV8ToIstanbul(params.result);
       } catch (conversionError) {
         console.error(`${PRINT_PREFIX} Error converting coverage:`, conversionError);
         // Fall back to empty coverage
-        const payload = {
-          label,
-          timestamp: new Date().toISOString(),
-          coverage_data: Buffer.from(JSON.stringify({})).toString('base64'),
-        };
-        const body = JSON.stringify(payload);
-        res.writeHead(200, {
-          'Content-Type': 'application/json',
-          'Content-Length': Buffer.byteLength(body),
-        });
-        res.end(body);
+        istanbulCoverage = {};
       }
+
+      resolvePromise({
+        label,
+        timestamp: new Date().toISOString(),
+        format: COVERAGE_FORMAT,
+        protocol_version: PROTOCOL_VERSION,
+        coverage_data: Buffer.from(JSON.stringify(istanbulCoverage)).toString('base64'),
+      });
     });
-  } catch (error) {
-    console.error(`${PRINT_PREFIX} Error dumping coverage:`, error);
-    res.writeHead(500, { 'Content-Type': 'application/json' });
-    res.end(JSON.stringify({ error: error.message }));
-  }
+  });
 }
 
 /**
@@ -216,6 +247,26 @@ async function convertV8ToIstanbul(v8Coverage) {
   return istanbulCoverage;
 }
 
+/**
+ * Handle /coverage/info endpoint
+ */
+function handleInfo(req, res) {
+  const payload = {
+    format: COVERAGE_FORMAT,
+    protocol_version: PROTOCOL_VERSION,
+    capabilities: CAPABILITIES,
+    pid: process.pid,
+    binary: basename(process.execPath),
+  };
+
+  const body = JSON.stringify(payload);
+  res.writeHead(200, {
+    'Content-Type': 'application/json',
+    'Content-Length': Buffer.byteLength(body),
+  });
+  res.end(body);
+}
+
 /**
  * Handle /health endpoint
  */
@@ -223,6 +274,7 @@ function handleHealth(req, res) {
   const payload = {
     status: 'ok',
     coverage_enabled: true,
+    format: COVERAGE_FORMAT,
   };
 
   const body = JSON.stringify(payload);
@@ -257,6 +309,97 @@ function handleCoverageReset(req, res) {
   }
 }
 
+/**
+ * Handle /coverage/push endpoint - dump coverage to COVERAGE_DUMP_DIR and/or COVERAGE_PUSH_URL
+ */
+async function handlePush(req, res) {
+  try {
+    await pushCoverage();
+    res.writeHead(200, { 'Content-Type': 'text/plain' });
+    res.end('Coverage pushed');
+  } catch (error) {
+    console.error(`${PRINT_PREFIX} ERROR: Failed to push coverage: ${error.message}`);
+    res.writeHead(500, { 'Content-Type': 'text/plain' });
+    res.end(`Failed to push coverage: ${error.message}`);
+  }
+}
+
+/**
+ * Component name reported with pushed coverage
+ */
+function componentName() {
+  return process.env.COVERAGE_COMPONENT || basename(process.execPath);
+}
+
+/**
+ * Dump the current coverage to COVERAGE_DUMP_DIR and/or POST it to COVERAGE_PUSH_URL.
+ * Fails if neither is configured.
+ */
+async function pushCoverage() {
+  const pushURL = process.env.COVERAGE_PUSH_URL || '';
+  const dumpDir = process.env.COVERAGE_DUMP_DIR || '';
+  if (!pushURL && !dumpDir) {
+    throw new Error('neither COVERAGE_PUSH_URL nor COVERAGE_DUMP_DIR is set');
+  }
+
+  const body = JSON.stringify(await coveragePayload('push'));
+  const errors = [];
+  if (dumpDir) {
+    try {
+      dumpToDir(dumpDir, body);
+    } catch (error) {
+      errors.push(`dump to ${dumpDir}: ${error.message}`);
+    }
+  }
+  if (pushURL) {
+    try {
+      await pushToURL(pushURL, body);
+    } catch (error) {
+      errors.push(`push to ${pushURL}: ${error.message}`);
+    }
+  }
+
+  if (errors.length > 0) {
+    throw new Error(errors.join('; '));
+  }
+}
+
+/**
+ * Write the coverage payload to <dir>/<component>/<pod>-<pid>/coverage.json
+ */
+function dumpToDir(dumpDir, body) {
+  const target = resolve(dumpDir, componentName(), `${hostname()}-${process.pid}`);
+  mkdirSync(target, { recursive: true });
+  writeFileSync(resolve(target, 'coverage.json'), body);
+  console.log(`${PRINT_PREFIX} Coverage dumped to ${target}`);
+}
+
+/**
+ * POST the coverage payload (same JSON as /coverage) to a collector
+ */
+async function pushToURL(pushURL, body) {
+  const headers = {
+    ...IDENTITY_HEADERS,
+    'Content-Type': 'application/json',
+    'X-Art-Coverage-Component': componentName(),
+    'X-Art-Coverage-Pod': hostname(),
+  };
+  if (process.env.POD_NAMESPACE) {
+    headers['X-Art-Coverage-Namespace'] = process.env.POD_NAMESPACE;
+  }
+
+  const response = await fetch(pushURL, {
+    method: 'POST',
+    headers,
+    body,
+    signal: AbortSignal.timeout(30000),
+  });
+  if (response.status !== 200) {
+    throw new Error(`collector returned ${response.status}`);
+  }
+  console.log(`${PRINT_PREFIX} Coverage pushed to ${pushURL}`);
+}
+
 /**
  * Start the coverage HTTP server.
  * Tries successive ports starting from COVERAGE_PORT until one is available
@@ -284,7 +427,7 @@ function startServer() {
 
       server.listen(port, '0.0.0.0', () => {
         console.log(`${PRINT_PREFIX} HTTP server listening on port ${port} (pid ${process.pid})`);
-        console.log(`${PRINT_PREFIX} Endpoints: GET :${port}/coverage, GET :${port}/health, HEAD :${port}/*`);
+        console.log(`${PRINT_PREFIX} Endpoints: GET :${port}/coverage, GET :${port}/coverage/info, GET :${port}/health, GET|POST :${port}/coverage/push, HEAD :${port}/*`);
         resolvePromise(server);
       });
     }
@@ -359,7 +502,16 @@ async function main() {
     process.exit(0);
   });
 
-  process.on('SIGTERM', () => {
+  process.on('SIGTERM', async () => {
+    if (['1', 'true'].includes(process.env.COVERAGE_DUMP_ON_SIGTERM)) {
+      console.log(`${PRINT_PREFIX} SIGTERM received, pushing final coverage...`);
+      try {
+        await pushCoverage();
+      } catch (error) {
+        console.error(`${PRINT_PREFIX} ERROR: Failed to push coverage: ${error.message}`);
+      }
+    }
+
     console.log(`\n${PRINT_PREFIX} Shutting down...`);
     if (coverageSession) {
       coverageSession.disconnect();
@@ -23,6 +23,30 @@ Clients can identify a coverage server by checking the response headers:
     X-Art-Coverage-Binary:         <binary-name>
     X-Art-Coverage-Source-Commit:  <commit>  (if SOURCE_GIT_COMMIT is set)
     X-Art-Coverage-Source-Url:     <url>     (if SOURCE_GIT_URL is set)
+    X-Art-Coverage-Format:         python
+    X-Art-Coverage-Protocol:       <protocol version>
+    X-Art-Coverage-Capabilities:   <comma-separated capabilities>
+
+GET /coverage/info returns the format, protocol version and capabilities as JSON.
+
+GET /coverage returns the combined coverage base64-encoded in JSON. Clients
+sending "Accept: application/x-tar" receive it as a .coverage file in a tar
+stream instead, gzip-compressed if they also send "Accept-Encoding: gzip".
+
+Coverage of pods that are rolled, evicted or scaled down is lost unless it is
+dumped before the process exits. GET or POST /coverage/push dumps the current
+coverage to the destinations below and is meant to be used as a preStop hook
+(run /coverage/save first for Gunicorn workers, or rely on the on_exit hook in
+gunicorn_coverage.py, which pushes after the workers saved their data):
+    COVERAGE_PUSH_URL         POST the /coverage JSON to a collector (e.g. coverport agent)
+    COVERAGE_DUMP_DIR         write the /coverage JSON to <dir>/<component>/<pod>-<pid>/coverage.json
+    COVERAGE_COMPONENT        component name sent with the dump (default: binary name)
+    COVERAGE_DUMP_ON_SIGTERM  set to 1 or true to also dump when the process receives SIGTERM
+
+Pushed requests carry the identification headers above plus:
+    X-Art-Coverage-Component:  <component>
+    X-Art-Coverage-Pod:        <hostname>
+    X-Art-Coverage-Namespace:  <namespace>  (if POD_NAMESPACE is set)
 
 Usage:
     python coverage_server.py -m gunicorn -c gunicorn_coverage.py app:app
@@ -32,6 +56,7 @@ Environment Variables:
     COVERAGE_PORT - Starting port for coverage HTTP server (default: 53700)
     COVERAGE_PROCESS_START - Path to .coveragerc (set automatically)
     COVERAGE_DATA_DIR - Directory for coverage files (default: /dev/shm)
+    COVERAGE_PUSH_URL, COVERAGE_DUMP_DIR, COVERAGE_COMPONENT, COVERAGE_DUMP_ON_SIGTERM - see above
 """
 
 import os
@@ -40,7 +65,13 @@ import runpy
 import json
 import base64
 import glob
+import io
+import tarfile
+import time
+import signal
+import socket
 import urllib.parse
+import urllib.request
 from datetime import datetime, timezone
 from threading import Thread, Event
 from http.server import HTTPServer, BaseHTTPRequestHandler
@@ -56,6 +87,11 @@ _DEFAULT_DIR = "/dev/shm" if os.path.exists("/dev/shm") else "/tmp/coverage-test
 COVERAGE_DATA_DIR = os.getenv("COVERAGE_DATA_DIR", _DEFAULT_DIR)
 PRINT_PREFIX = "[coverage-wrapper]"
 
+# Wire protocol shared by the Go, Python, Node.js and Rust coverage servers
+PROTOCOL_VERSION = 1
+COVERAGE_FORMAT = "python"
+CAPABILITIES = ["reset", "save", "push", "streaming"]
+
 # Path to the .coveragerc file (relative to this script)
 SCRIPT_DIR = os.path.dirname(os.path.abspath(__file__))
 DEFAULT_COVERAGERC = os.path.join(SCRIPT_DIR, ".coveragerc")
@@ -67,6 +103,9 @@ def _build_identity_headers():
         "X-Art-Coverage-Server": "1",
         "X-Art-Coverage-Pid": str(os.getpid()),
         "X-Art-Coverage-Binary": os.path.basename(sys.executable),
+        "X-Art-Coverage-Format": COVERAGE_FORMAT,
+        "X-Art-Coverage-Protocol": str(PROTOCOL_VERSION),
+        "X-Art-Coverage-Capabilities": ",".join(CAPABILITIES),
     }
     for header, env_var in [
         ("X-Art-Coverage-Source-Commit", "SOURCE_GIT_COMMIT"),
@@ -89,6 +128,147 @@ def _build_identity_headers():
 _IDENTITY_HEADERS = _build_identity_headers()
 
 
+def combine_coverage_files():
+    """Combine all coverage files in memory.
+
+    Returns the serialized coverage data (CoverageData.dumps()) and the number
+    of files combined, or (None, 0) if there are no coverage files.
+    """
+    # Find all coverage files in the data directory
+    pattern = os.path.join(COVERAGE_DATA_DIR, ".coverage*")
+    coverage_files = sorted(glob.glob(pattern))
+
+    print(f"{PRINT_PREFIX} Found {len(coverage_files)} coverage file(s)", flush=True)
+
+    if not coverage_files:
+        return None, 0
+
+    # Create a combined coverage data object (in-memory, no writes)
+    combined = coverage.CoverageData(no_disk=True)
+
+    for cov_file in coverage_files:
+        try:
+            # Read each coverage file from disk (no_disk=False required for reading!)
+            file_data = coverage.CoverageData(basename=cov_file)
+            file_data.read()
+            combined.update(file_data)
+            measured = list(file_data.measured_files())
+            print(f"{PRINT_PREFIX} Combined: {os.path.basename(cov_file)} ({len(measured)} files)", flush=True)
+        except Exception as e:
+            print(f"{PRINT_PREFIX} Error reading {cov_file}: {e}", flush=True)
+
+    return combined.dumps(), len(coverage_files)
+
+
+def coverage_payload(label):
+    """Combine all coverage files into the /coverage JSON payload."""
+    data, files_combined = combine_coverage_files()
+
+    if data is None:
+        # Return empty coverage data
+        return {
+            "label": label,
+            "timestamp": datetime.now(timezone.utc).isoformat(),
+            "format": COVERAGE_FORMAT,
+            "protocol_version": PROTOCOL_VERSION,
+            "coverage_data": "",
+            "files_combined": 0,
+            "message": "No coverage files found"
+        }
+
+    return {
+        "label": label,
+        "timestamp": datetime.now(timezone.utc).isoformat(),
+        "format": COVERAGE_FORMAT,
+        "protocol_version": PROTOCOL_VERSION,
+        "coverage_data": base64.b64encode(data).decode('ascii'),
+        "files_combined": files_combined,
+    }
+
+
+def component_name():
+    """Return the component reported with pushed coverage."""
+    return os.environ.get("COVERAGE_COMPONENT") or os.path.basename(sys.executable)
+
+
+def push_coverage():
+    """Dump the current coverage to COVERAGE_DUMP_DIR and/or POST it to COVERAGE_PUSH_URL.
+
+    The coverage of this process is saved first, so scripts run by the wrapper
+    are included. Raises RuntimeError if neither destination is set or a
+    destination fails.
+    """
+    push_url = os.environ.get("COVERAGE_PUSH_URL", "")
+    dump_dir = os.environ.get("COVERAGE_DUMP_DIR", "")
+    if not push_url and not dump_dir:
+        raise RuntimeError("neither COVERAGE_PUSH_URL nor COVERAGE_DUMP_DIR is set")
+
+    cov = coverage.Coverage.current()
+    if cov:
+        cov.save()
+
+    body = json.dumps(coverage_payload("push")).encode()
+    errors = []
+    if dump_dir:
+        try:
+            _dump_to_dir(dump_dir, body)
+        except Exception as e:
+            errors.append(f"dump to {dump_dir}: {e}")
+    if push_url:
+        try:
+            _push_to_url(push_url, body)
+        except Exception as e:
+            errors.append(f"push to {push_url}: {e}")
+
+    if errors:
+        raise RuntimeError("; ".join(errors))
+
+
+def _dump_to_dir(dump_dir, body):
+    """Write the coverage payload to <dir>/<component>/<pod>-<pid>/coverage.json."""
+    target = os.path.join(dump_dir, component_name(), f"{socket.gethostname()}-{os.getpid()}")
+    os.makedirs(target, exist_ok=True)
+    with open(os.path.join(target, "coverage.json"), "wb") as f:
+        f.write(body)
+    print(f"{PRINT_PREFIX} Coverage dumped to {target}", flush=True)
+
+
+def _push_to_url(push_url, body):
+    """POST the coverage payload (same JSON as /coverage) to a collector."""
+    headers = dict(_IDENTITY_HEADERS)
+    headers["Content-Type"] = "application/json"
+    headers["X-Art-Coverage-Component"] = component_name()
+    headers["X-Art-Coverage-Pod"] = socket.gethostname()
+    namespace = os.environ.get("POD_NAMESPACE", "")
+    if namespace:
+        headers["X-Art-Coverage-Namespace"] = namespace
+
+    request = urllib.request.Request(push_url, data=body, headers=headers, method="POST")
+    with urllib.request.urlopen(request, timeout=30) as response:
+        if response.status != 200:
+            raise RuntimeError(f"collector returned {response.status}")
+    print(f"{PRINT_PREFIX} Coverage pushed to {push_url}", flush=True)
+
+
+def push_on_sigterm():
+    """Dump coverage when the process receives SIGTERM, then re-raise the signal.
+
+    Applications installing their own SIGTERM handler (e.g. the Gunicorn master)
+    replace this one; use the /coverage/push preStop hook or the Gunicorn
+    on_exit hook for them.
+    """
+    def handler(signum, frame):
+        print(f"{PRINT_PREFIX} SIGTERM received, pushing final coverage...", flush=True)
+        try:
+            push_coverage()
+        except Exception as e:
+            print(f"{PRINT_PREFIX} ERROR: Failed to push coverage: {e}", flush=True)
+        signal.signal(signal.SIGTERM, signal.SIG_DFL)
+        os.kill(os.getpid(), signal.SIGTERM)
+
+    signal.signal(signal.SIGTERM, handler)
+
+
 class CoverageHandler(BaseHTTPRequestHandler):
     """HTTP handler for coverage endpoints."""
 
@@ -113,7 +293,12 @@ class CoverageHandler(BaseHTTPRequestHandler):
         label = query.get("name", ["session"])[0]
 
         if path == "/coverage":
-            self._handle_coverage(label)
+            if "application/x-tar" in self.headers.get("Accept", ""):
+                self._handle_coverage_archive(label)
+            else:
+                self._handle_coverage(label)
+        elif path == "/coverage/info":
+            self._handle_info()
         elif path == "/health":
             self._handle_health()
         elif path == "/coverage/save":
@@ -122,58 +307,29 @@ class CoverageHandler(BaseHTTPRequestHandler):
             self._handle_reset()
         elif path == "/coverage/files":
             self._handle_list_files()
+        elif path == "/coverage/push":
+            self._handle_push()
         else:
-            self.send_response(404)
-            self.send_header("Content-Type", "text/plain")
-            self.end_headers()
-            self.wfile.write(b"Not found")
+            self._handle_not_found()
+
+    def do_POST(self):
+        if urllib.parse.urlparse(self.path).path == "/coverage/push":
+            self._handle_push()
+        else:
+            self._handle_not_found()
+
+    def _handle_not_found(self):
+        self.send_response(404)
+        self.send_header("Content-Type", "text/plain")
+        self.end_headers()
+        self.wfile.write(b"Not found")
 
     def _handle_coverage(self, label):
         """Combine all coverage files and return as JSON."""
         print(f"{PRINT_PREFIX} Coverage dump requested (label={label})", flush=True)
 
         try:
-            # Find all coverage files in the data directory
-            pattern = os.path.join(COVERAGE_DATA_DIR, ".coverage*")
-            coverage_files = sorted(glob.glob(pattern))
-
-            print(f"{PRINT_PREFIX} Found {len(coverage_files)} coverage file(s)", flush=True)
-
-            if not coverage_files:
-                # Return empty coverage data
-                payload = {
-                    "label": label,
-                    "timestamp": datetime.now(timezone.utc).isoformat(),
-                    "coverage_data": "",
-                    "files_combined": 0,
-                    "message": "No coverage files found"
-                }
-            else:
-                # Create a combined coverage data object (in-memory, no writes)
-                combined = coverage.CoverageData(no_disk=True)
-
-                for cov_file in coverage_files:
-                    try:
-                        # Read each coverage file from disk (no_disk=False required for reading!)
-                        file_data = coverage.CoverageData(basename=cov_file)
-                        file_data.read()
-                        combined.update(file_data)
-                        measured = list(file_data.measured_files())
-                        print(f"{PRINT_PREFIX} Combined: {os.path.basename(cov_file)} ({len(measured)} files)", flush=True)
-                    except Exception as e:
-                        print(f"{PRINT_PREFIX} Error reading {cov_file}: {e}", flush=True)
-
-                # Serialize to binary and encode
-                json_bytes = combined.dumps()
-                json_b64 = base64.b64encode(json_bytes).decode('ascii')
-
-                payload = {
-                    "label": label,
-                    "timestamp": datetime.now(timezone.utc).isoformat(),
-                    "coverage_data": json_b64,
-                    "files_combined": len(coverage_files),
-                }
-
+            payload = coverage_payload(label)
             body = json.dumps(payload).encode()
             self.send_response(200)
             self.send_header("Content-Type", "application/json")
@@ -188,6 +344,64 @@ class CoverageHandler(BaseHTTPRequestHandler):
             self.end_headers()
             self.wfile.write(f"Error: {e}".encode())
 
+    def _handle_coverage_archive(self, label):
+        """Combine all coverage files and stream them as a .coverage file in a tar archive."""
+        print(f"{PRINT_PREFIX} Coverage dump requested as archive (label={label})", flush=True)
+
+        try:
+            data, _ = combine_coverage_files()
+        except Exception as e:
+            print(f"{PRINT_PREFIX} Error collecting coverage: {e}", flush=True)
+            self.send_response(500)
+            self.send_header("Content-Type", "text/plain")
+            self.end_headers()
+            self.wfile.write(f"Error: {e}".encode())
+            return
+
+        if data is None:
+            # The JSON response tells the client that no coverage files exist
+            self._handle_coverage(label)
+            return
+
+        compress = "gzip" in self.headers.get("Accept-Encoding", "")
+        self.send_response(200)
+        self.send_header("Content-Type", "application/x-tar")
+        if compress:
+            self.send_header("Content-Encoding", "gzip")
+        self.end_headers()
+
+        # The connection is closed after the response (HTTP/1.0), which ends the body
+        info = tarfile.TarInfo(".coverage")
+        info.size = len(data)
+        info.mode = 0o644
+        info.mtime = int(time.time())
+        try:
+            with tarfile.open(fileobj=self.wfile, mode="w|gz" if compress else "w|") as archive:
+                archive.addfile(info, io.BytesIO(data))
+        except Exception as e:
+            # Headers are already sent; the client sees a truncated archive
+            print(f"{PRINT_PREFIX} Error streaming coverage: {e}", flush=True)
+            return
+
+        print(f"{PRINT_PREFIX} Coverage data streamed successfully", flush=True)
+
+    def _handle_info(self):
+        """Return the format, protocol version and capabilities of the server."""
+        payload = {
+            "format": COVERAGE_FORMAT,
+            "protocol_version": PROTOCOL_VERSION,
+            "capabilities": CAPABILITIES,
+            "pid": os.getpid(),
+            "binary": os.path.basename(sys.executable),
+        }
+        body = json.dumps(payload).encode()
+
+        self.send_response(200)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
     def _handle_health(self):
         """Return health status."""
         print(f"{PRINT_PREFIX} Health check requested", flush=True)
@@ -216,9 +430,6 @@ class CoverageHandler(BaseHTTPRequestHandler):
         This restarts Gunicorn workers, which triggers the worker_exit hook
         in gunicorn_coverage.py, saving each worker's coverage data to /dev/shm.
         """
-        import signal
-        import time
-
         print(f"{PRINT_PREFIX} Coverage save triggered via /coverage/save", flush=True)
 
         try:
@@ -266,6 +477,25 @@ class CoverageHandler(BaseHTTPRequestHandler):
         self.end_headers()
         self.wfile.write(f"Deleted {deleted} coverage files".encode())
 
+    def _handle_push(self):
+        """Dump the current coverage to COVERAGE_DUMP_DIR and/or COVERAGE_PUSH_URL."""
+        print(f"{PRINT_PREFIX} Coverage push requested", flush=True)
+
+        try:
+            push_coverage()
+        except Exception as e:
+            print(f"{PRINT_PREFIX} ERROR: Failed to push coverage: {e}", flush=True)
+            self.send_response(500)
+            self.send_header("Content-Type", "text/plain")
+            self.end_headers()
+            self.wfile.write(f"Failed to push coverage: {e}".encode())
+            return
+
+        self.send_response(200)
+        self.send_header("Content-Type", "text/plain")
+        self.end_headers()
+        self.wfile.write(b"Coverage pushed")
+
     def _handle_list_files(self):
         """List all coverage files (for debugging)."""
         pattern = os.path.join(COVERAGE_DATA_DIR, ".coverage*")
@@ -312,7 +542,7 @@ def run_server(ready_event=None):
         try:
             server = ThreadedHTTPServer(("0.0.0.0", port), CoverageHandler)
             print(f"{PRINT_PREFIX} HTTP server listening on port {port} (pid {os.getpid()})", flush=True)
-            print(f"{PRINT_PREFIX} Endpoints: GET :{port}/coverage, GET :{port}/health, HEAD :{port}/*", flush=True)
+            print(f"{PRINT_PREFIX} Endpoints: GET :{port}/coverage, GET :{port}/coverage/info, GET :{port}/health, GET|POST :{port}/coverage/push, HEAD :{port}/*", flush=True)
             if ready_event:
                 ready_event.set()
             server.serve_forever()
@@ -378,6 +608,9 @@ def main():
         print(f"{PRINT_PREFIX} ERROR: Coverage server failed to start within 10s", flush=True)
         sys.exit(1)
 
+    if os.environ.get("COVERAGE_DUMP_ON_SIGTERM") in ("1", "true"):
+        push_on_sigterm()
+
     # Prepare to run the target script
     script_args = sys.argv[1:]
 
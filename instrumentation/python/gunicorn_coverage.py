@@ -4,6 +4,7 @@
 # Based on Gemini research recommendations:
 # - post_fork: Log worker startup (coverage already started via sitecustomize.py)
 # - worker_exit: CRITICAL - Save coverage data before worker dies
+# - on_exit: Save the master's data and push the final coverage (COVERAGE_DUMP_ON_SIGTERM=1)
 #
 # Usage: gunicorn -c gunicorn_coverage.py app:app
 
@@ -62,3 +63,13 @@ def on_exit(server):
             server.log.info("[coverage] Master process coverage saved")
     except Exception as e:
         server.log.error(f"[coverage] Failed to save master coverage: {e}")
+
+    # Gunicorn replaces the SIGTERM handler of coverage_server.py; the workers
+    # have saved their data by now, so push the final coverage from here
+    if os.environ.get("COVERAGE_DUMP_ON_SIGTERM") in ("1", "true"):
+        try:
+            from coverage_server import push_coverage
+            push_coverage()
+            server.log.info("[coverage] Final coverage pushed")
+        except Exception as e:
+            server.log.error(f"[coverage] Failed to push coverage: {e}")
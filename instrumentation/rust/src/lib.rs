@@ -19,6 +19,34 @@
 //! This means the coverage server works on **read-only root filesystems** with no
 //! writable volumes, temp directories, or disk access needed.
 //!
+//! ## Protocol
+//!
+//! Every response carries the `X-Art-Coverage-Format` (`rust`), `X-Art-Coverage-Protocol`
+//! and `X-Art-Coverage-Capabilities` headers, and `GET /coverage/info` returns the same
+//! information as JSON so clients can negotiate optional features.
+//!
+//! `GET /coverage` returns the profraw data base64-encoded in JSON. Clients sending
+//! `Accept: application/x-tar` receive the profraw file as a tar archive instead,
+//! gzip-compressed if they also send `Accept-Encoding: gzip`, which avoids the
+//! base64 overhead. The archive is streamed in chunks as it is written, so only the
+//! profraw data is held in memory.
+//!
+//! ## Pushing coverage on termination
+//!
+//! Coverage of pods that are rolled, evicted or scaled down is lost unless it is
+//! dumped before the process exits. `GET` or `POST /coverage/push` dumps the current
+//! coverage to the destinations below and is meant to be used as a `preStop` hook:
+//!
+//! - `COVERAGE_PUSH_URL` — POST the `/coverage` JSON to a collector (e.g. `coverport agent`)
+//! - `COVERAGE_DUMP_DIR` — write the `/coverage` JSON to `<dir>/<component>/<pod>-<pid>/coverage.json`
+//! - `COVERAGE_COMPONENT` — component name sent with the dump (default: binary name)
+//! - `COVERAGE_DUMP_ON_SIGTERM` — set to `1` or `true` to also dump when the process receives
+//!   SIGTERM; the process then exits with status 143, as it would without the handler
+//!
+//! Pushed requests carry the identification headers plus `X-Art-Coverage-Component`,
+//! `X-Art-Coverage-Pod` (hostname) and `X-Art-Coverage-Namespace` (if `POD_NAMESPACE`
+//! is set).
+//!
 //! ## Usage
 //!
 //! Works with any application — async or synchronous, any runtime:
@@ -35,17 +63,37 @@
 //!
 
 use axum::{
-    http::{HeaderMap, HeaderValue, StatusCode},
+    body::Body,
+    http::{header, HeaderMap, HeaderName, HeaderValue, StatusCode},
     response::IntoResponse,
     routing::get,
     Json, Router,
 };
 use serde::Serialize;
 use std::env;
+use std::io::Write;
 use std::net::SocketAddr;
+use tokio::sync::mpsc;
 use tokio::task::JoinHandle;
+use tokio_stream::wrappers::ReceiverStream;
 use tracing::{error, info};
 
+/// Version of the coverage server wire protocol shared by the Go, Python,
+/// Node.js and Rust servers.
+pub const PROTOCOL_VERSION: u32 = 1;
+
+/// Coverage format served by this server.
+const COVERAGE_FORMAT: &str = "rust";
+
+/// Optional protocol features supported by this server.
+const CAPABILITIES: &[&str] = &["reset", "push", "streaming"];
+
+/// Size of the chunks a streamed coverage archive is sent in.
+const STREAM_CHUNK_SIZE: usize = 64 * 1024;
+
+/// Number of chunks buffered while the client reads a streamed archive.
+const STREAM_BUFFERED_CHUNKS: usize = 4;
+
 extern "C" {
     /// Returns the size in bytes needed to hold the serialized profile data.
     fn __llvm_profile_get_size_for_buffer() -> u64;
@@ -111,6 +159,19 @@ fn coverport_headers() -> HeaderMap {
         HeaderValue::from_str(&std::process::id().to_string())
             .unwrap_or_else(|_| HeaderValue::from_static("0")),
     );
+    headers.insert(
+        "X-Art-Coverage-Format",
+        HeaderValue::from_static(COVERAGE_FORMAT),
+    );
+    headers.insert(
+        "X-Art-Coverage-Protocol",
+        HeaderValue::from(PROTOCOL_VERSION),
+    );
+    headers.insert(
+        "X-Art-Coverage-Capabilities",
+        HeaderValue::from_str(&CAPABILITIES.join(","))
+            .unwrap_or_else(|_| HeaderValue::from_static("")),
+    );
 
     if let Ok(commit) = env::var("SOURCE_GIT_COMMIT") {
         if let Ok(val) = HeaderValue::from_str(&commit) {
@@ -126,6 +187,230 @@ fn coverport_headers() -> HeaderMap {
     headers
 }
 
+/// Component name reported with pushed coverage.
+fn component_name() -> String {
+    if let Ok(component) = env::var("COVERAGE_COMPONENT") {
+        if !component.is_empty() {
+            return component;
+        }
+    }
+    env::current_exe()
+        .ok()
+        .and_then(|exe| exe.file_name().map(|name| name.to_string_lossy().into_owned()))
+        .unwrap_or_else(|| "unknown".to_string())
+}
+
+/// Hostname of the pod, reported with pushed coverage.
+fn hostname() -> String {
+    env::var("HOSTNAME")
+        .ok()
+        .or_else(|| std::fs::read_to_string("/etc/hostname").ok())
+        .map(|name| name.trim().to_string())
+        .unwrap_or_default()
+}
+
+/// Current time in nanoseconds since the Unix epoch.
+fn timestamp_nanos() -> u64 {
+    std::time::SystemTime::now()
+        .duration_since(std::time::UNIX_EPOCH)
+        .unwrap_or_default()
+        .as_nanos() as u64
+}
+
+/// Name of the profraw file served for a collection at `timestamp`.
+fn profraw_filename(timestamp: u64) -> String {
+    format!("coverage.{}.{}.profraw", std::process::id(), timestamp)
+}
+
+/// Build the `/coverage` response from the in-memory profile data.
+fn coverage_response() -> Result<CoverageResponse, String> {
+    let profraw_data = collect_profraw_in_memory()?;
+    let size = profraw_data.len();
+    let encoded =
+        base64::Engine::encode(&base64::engine::general_purpose::STANDARD, &profraw_data);
+
+    let timestamp = timestamp_nanos();
+
+    Ok(CoverageResponse {
+        profraw_filename: profraw_filename(timestamp),
+        profraw_data: encoded,
+        profraw_size: size,
+        timestamp,
+        coverage_enabled: true,
+        format: COVERAGE_FORMAT,
+        protocol_version: PROTOCOL_VERSION,
+    })
+}
+
+/// Write a tar archive holding the profraw file to `writer`, gzip-compressed if
+/// `compress` is set. Blocks on the writer.
+fn write_coverage_archive<W: Write>(
+    profraw_data: &[u8],
+    writer: W,
+    compress: bool,
+) -> std::io::Result<()> {
+    let timestamp = timestamp_nanos();
+    let mut header = tar::Header::new_gnu();
+    header.set_size(profraw_data.len() as u64);
+    header.set_mode(0o644);
+    header.set_mtime(timestamp / 1_000_000_000);
+    let filename = profraw_filename(timestamp);
+
+    if compress {
+        let encoder = flate2::write::GzEncoder::new(writer, flate2::Compression::default());
+        let mut builder = tar::Builder::new(encoder);
+        builder.append_data(&mut header, filename, profraw_data)?;
+        builder.into_inner()?.finish()?.flush()
+    } else {
+        let mut builder = tar::Builder::new(writer);
+        builder.append_data(&mut header, filename, profraw_data)?;
+        builder.into_inner()?.flush()
+    }
+}
+
+/// `Write` adapter sending everything written as chunks of a streamed response
+/// body. Must be used from a blocking thread.
+struct BodyWriter {
+    tx: mpsc::Sender<std::io::Result<Vec<u8>>>,
+}
+
+impl Write for BodyWriter {
+    fn write(&mut self, buf: &[u8]) -> std::io::Result<usize> {
+        self.tx.blocking_send(Ok(buf.to_vec())).map_err(|_| {
+            std::io::Error::new(std::io::ErrorKind::BrokenPipe, "client disconnected")
+        })?;
+        Ok(buf.len())
+    }
+
+    fn flush(&mut self) -> std::io::Result<()> {
+        Ok(())
+    }
+}
+
+/// Collect the profile data and stream it as a tar archive, gzip-compressed if
+/// `compress` is set. Collection and compression run on a blocking thread.
+async fn coverage_archive_body(compress: bool) -> Result<Body, String> {
+    let profraw_data = tokio::task::spawn_blocking(collect_profraw_in_memory)
+        .await
+        .unwrap_or_else(|e| Err(e.to_string()))?;
+
+    let (tx, rx) = mpsc::channel(STREAM_BUFFERED_CHUNKS);
+    tokio::task::spawn_blocking(move || {
+        let writer =
+            std::io::BufWriter::with_capacity(STREAM_CHUNK_SIZE, BodyWriter { tx: tx.clone() });
+        if let Err(e) = write_coverage_archive(&profraw_data, writer, compress) {
+            error!("Failed to stream coverage archive: {}", e);
+            // Abort the response so the client does not take it as complete
+            let _ = tx.blocking_send(Err(e));
+        }
+    });
+
+    Ok(Body::from_stream(ReceiverStream::new(rx)))
+}
+
+/// Whether the request header `name` contains `value`.
+fn header_contains(headers: &HeaderMap, name: HeaderName, value: &str) -> bool {
+    headers
+        .get(name)
+        .and_then(|v| v.to_str().ok())
+        .map_or(false, |v| v.contains(value))
+}
+
+/// Dump the current coverage to `COVERAGE_DUMP_DIR` and/or POST it to
+/// `COVERAGE_PUSH_URL`. Fails if neither is configured. Blocks on file and
+/// network I/O.
+fn push_coverage() -> Result<(), String> {
+    let push_url = env::var("COVERAGE_PUSH_URL").unwrap_or_default();
+    let dump_dir = env::var("COVERAGE_DUMP_DIR").unwrap_or_default();
+    if push_url.is_empty() && dump_dir.is_empty() {
+        return Err("neither COVERAGE_PUSH_URL nor COVERAGE_DUMP_DIR is set".to_string());
+    }
+
+    let body = serde_json::to_vec(&coverage_response()?)
+        .map_err(|e| format!("encode coverage: {}", e))?;
+
+    let mut errors = Vec::new();
+    if !dump_dir.is_empty() {
+        if let Err(e) = dump_to_dir(&dump_dir, &body) {
+            errors.push(format!("dump to {}: {}", dump_dir, e));
+        }
+    }
+    if !push_url.is_empty() {
+        if let Err(e) = push_to_url(&push_url, &body) {
+            errors.push(format!("push to {}: {}", push_url, e));
+        }
+    }
+
+    if errors.is_empty() {
+        Ok(())
+    } else {
+        Err(errors.join("; "))
+    }
+}
+
+/// Write the coverage payload to `<dir>/<component>/<pod>-<pid>/coverage.json`.
+fn dump_to_dir(dump_dir: &str, body: &[u8]) -> Result<(), String> {
+    let target = std::path::Path::new(dump_dir)
+        .join(component_name())
+        .join(format!("{}-{}", hostname(), std::process::id()));
+    std::fs::create_dir_all(&target).map_err(|e| e.to_string())?;
+    std::fs::write(target.join("coverage.json"), body).map_err(|e| e.to_string())?;
+    info!("Coverage dumped to {}", target.display());
+    Ok(())
+}
+
+/// POST the coverage payload (same JSON as `/coverage`) to a collector.
+fn push_to_url(push_url: &str, body: &[u8]) -> Result<(), String> {
+    let mut request = ureq::post(push_url)
+        .timeout(std::time::Duration::from_secs(30))
+        .set("Content-Type", "application/json")
+        .set("X-Art-Coverage-Component", &component_name())
+        .set("X-Art-Coverage-Pod", &hostname());
+    for (name, value) in coverport_headers().iter() {
+        if let Ok(value) = value.to_str() {
+            request = request.set(name.as_str(), value);
+        }
+    }
+    if let Ok(namespace) = env::var("POD_NAMESPACE") {
+        if !namespace.is_empty() {
+            request = request.set("X-Art-Coverage-Namespace", &namespace);
+        }
+    }
+
+    let response = request.send_bytes(body).map_err(|e| e.to_string())?;
+    if response.status() != 200 {
+        return Err(format!("collector returned {}", response.status()));
+    }
+    info!("Coverage pushed to {}", push_url);
+    Ok(())
+}
+
+/// Dump coverage when the process receives SIGTERM, then exit with the status
+/// of a process terminated by SIGTERM. Listening for the signal replaces its
+/// default action, so the process has to exit itself. Applications handling
+/// SIGTERM themselves may exit before the dump completes; prefer the
+/// `/coverage/push` preStop hook for them.
+async fn push_on_sigterm() {
+    use tokio::signal::unix::{signal, SignalKind};
+
+    let mut sigterm = match signal(SignalKind::terminate()) {
+        Ok(sigterm) => sigterm,
+        Err(e) => {
+            error!("Failed to listen for SIGTERM: {}", e);
+            return;
+        }
+    };
+    sigterm.recv().await;
+
+    info!("SIGTERM received, pushing final coverage...");
+    match tokio::task::spawn_blocking(push_coverage).await {
+        Ok(Ok(())) => {}
+        Ok(Err(e)) => error!("Failed to push coverage: {}", e),
+        Err(e) => error!("Failed to push coverage: {}", e),
+    }
+    std::process::exit(128 + 15);
+}
+
 #[derive(Debug, Serialize)]
 struct CoverageResponse {
     profraw_filename: String,
@@ -133,6 +418,17 @@ struct CoverageResponse {
     profraw_size: usize,
     timestamp: u64,
     coverage_enabled: bool,
+    format: &'static str,
+    protocol_version: u32,
+}
+
+#[derive(Debug, Serialize)]
+struct InfoResponse {
+    format: &'static str,
+    protocol_version: u32,
+    capabilities: &'static [&'static str],
+    pid: u32,
+    binary: String,
 }
 
 #[derive(Debug, Serialize)]
@@ -164,12 +460,21 @@ impl CoverageServer {
 
         let app = Router::new()
             .route("/coverage", get(handle_coverage).post(handle_coverage))
+            .route("/coverage/info", get(handle_info))
             .route(
                 "/coverage/reset",
                 get(handle_reset_counters).post(handle_reset_counters),
             )
+            .route("/coverage/push", get(handle_push).post(handle_push))
             .route("/health", get(handle_health));
 
+        if matches!(
+            env::var("COVERAGE_DUMP_ON_SIGTERM").as_deref(),
+            Ok("1" | "true")
+        ) {
+            tokio::spawn(push_on_sigterm());
+        }
+
         let addr = SocketAddr::from(([0, 0, 0, 0], self.port));
         info!("Coverage server starting on {}", addr);
 
@@ -182,51 +487,84 @@ impl CoverageServer {
     }
 }
 
-async fn handle_coverage() -> impl IntoResponse {
-    let headers = coverport_headers();
+async fn handle_coverage(request_headers: HeaderMap) -> impl IntoResponse {
+    let mut headers = coverport_headers();
 
-    let result = collect_profraw_in_memory();
-    match result {
-        Ok(profraw_data) => {
-            let size = profraw_data.len();
-            let encoded = base64::Engine::encode(
-                &base64::engine::general_purpose::STANDARD,
-                &profraw_data,
-            );
-
-            let timestamp = std::time::SystemTime::now()
-                .duration_since(std::time::UNIX_EPOCH)
-                .unwrap_or_default()
-                .as_nanos() as u64;
-
-            let pid = std::process::id();
-            let filename = format!("coverage.{}.{}.profraw", pid, timestamp);
+    if header_contains(&request_headers, header::ACCEPT, "application/x-tar") {
+        let compress = header_contains(&request_headers, header::ACCEPT_ENCODING, "gzip");
+        return match coverage_archive_body(compress).await {
+            Ok(body) => {
+                headers.insert(
+                    header::CONTENT_TYPE,
+                    HeaderValue::from_static("application/x-tar"),
+                );
+                if compress {
+                    headers.insert(header::CONTENT_ENCODING, HeaderValue::from_static("gzip"));
+                }
+                (StatusCode::OK, headers, body).into_response()
+            }
+            Err(e) => {
+                error!("Failed to collect profraw data: {}", e);
+                (
+                    StatusCode::INTERNAL_SERVER_ERROR,
+                    headers,
+                    Json(ErrorResponse { error: e }),
+                )
+                    .into_response()
+            }
+        };
+    }
 
+    match coverage_response() {
+        Ok(response) => (StatusCode::OK, headers, Json(response)).into_response(),
+        Err(e) => {
+            error!("Failed to collect profraw data: {}", e);
             (
-                StatusCode::OK,
+                StatusCode::INTERNAL_SERVER_ERROR,
                 headers,
-                Json(CoverageResponse {
-                    profraw_filename: filename,
-                    profraw_data: encoded,
-                    profraw_size: size,
-                    timestamp,
-                    coverage_enabled: true,
-                }),
+                Json(ErrorResponse { error: e }),
             )
                 .into_response()
         }
+    }
+}
+
+async fn handle_push() -> impl IntoResponse {
+    let headers = coverport_headers();
+    let result = tokio::task::spawn_blocking(push_coverage)
+        .await
+        .unwrap_or_else(|e| Err(e.to_string()));
+    match result {
+        Ok(()) => (StatusCode::OK, headers, "Coverage pushed".to_string()),
         Err(e) => {
-            error!("Failed to collect profraw data: {}", e);
+            error!("Failed to push coverage: {}", e);
             (
                 StatusCode::INTERNAL_SERVER_ERROR,
                 headers,
-                Json(ErrorResponse { error: e }),
+                format!("Failed to push coverage: {}", e),
             )
-                .into_response()
         }
     }
 }
 
+async fn handle_info() -> impl IntoResponse {
+    let headers = coverport_headers();
+    (
+        StatusCode::OK,
+        headers,
+        Json(InfoResponse {
+            format: COVERAGE_FORMAT,
+            protocol_version: PROTOCOL_VERSION,
+            capabilities: CAPABILITIES,
+            pid: std::process::id(),
+            binary: env::current_exe()
+                .unwrap_or_default()
+                .display()
+                .to_string(),
+        }),
+    )
+}
+
 async fn handle_reset_counters() -> impl IntoResponse {
     let headers = coverport_headers();
     unsafe {